@@ -0,0 +1,261 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default limits of an archive
+const (
+	defaultMaxEntries = 1000
+	defaultMaxBytes   = 512 << 20
+	defaultMaxRatio   = 100
+)
+
+// ratioThreshold is the inflated size below which compression ratios are not checked,
+// small entries of repeated bytes compress far beyond any sensible ratio
+const ratioThreshold = 1 << 20
+
+// ErrLimit is returned for archives that exceed the entry, size or ratio limits
+var ErrLimit = errors.New("archive exceeds limits")
+
+// ErrUnsafeName is returned for entries that would be written outside the archive root
+var ErrUnsafeName = errors.New("archive entry name is not safe")
+
+// Limits struct bounds what an archive may inflate to
+type Limits struct {
+	MaxEntries int
+	MaxBytes   int64
+	MaxRatio   int64
+}
+
+// LoadLimits reads ARCHIVE_MAX_ENTRIES, ARCHIVE_MAX_BYTES and ARCHIVE_MAX_RATIO
+func LoadLimits() Limits {
+	read := func(name string, fallback int64) int64 {
+		value, err := strconv.ParseInt(os.Getenv(name), 0, 64)
+		if err != nil || value <= 0 {
+			return fallback
+		}
+		return value
+	}
+	return Limits{
+		MaxEntries: int(read("ARCHIVE_MAX_ENTRIES", defaultMaxEntries)),
+		MaxBytes:   read("ARCHIVE_MAX_BYTES", defaultMaxBytes),
+		MaxRatio:   read("ARCHIVE_MAX_RATIO", defaultMaxRatio),
+	}
+}
+
+// Entry struct is a file or folder of an archive
+type Entry struct {
+	Name           string
+	Size           int64
+	CompressedSize int64
+	ModifiedOn     time.Time
+	Directory      bool
+}
+
+// Supported checks if the mime type is an archive that can be inspected
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case "application/zip", "application/x-zip-compressed", "application/x-tar", "application/gzip", "application/x-gzip":
+		return true
+	}
+	return false
+}
+
+// SafeName cleans an entry name, refusing absolute names and names that climb out of the root
+func SafeName(name string) (string, bool) {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return "", false
+	}
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// Inspect lists the entries of an archive, inflating every entry so the
+// limits hold for what the archive really contains rather than its headers
+func Inspect(content []byte, mimeType string, limits Limits) ([]Entry, error) {
+	entries := []Entry{}
+	err := Walk(content, mimeType, limits, func(entry Entry, r io.Reader) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// Walk calls fn with each entry of an archive and a reader of its content.
+// Content fn leaves unread is still inflated to enforce the limits.
+func Walk(content []byte, mimeType string, limits Limits, fn func(entry Entry, r io.Reader) error) error {
+	switch mimeType {
+	case "application/zip", "application/x-zip-compressed":
+		return walkZip(content, limits, fn)
+	case "application/gzip", "application/x-gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return walkTar(reader, int64(len(content)), limits, fn)
+	default:
+		return walkTar(bytes.NewReader(content), 0, limits, fn)
+	}
+}
+
+// counter tracks the bytes inflated from an archive against its limits
+type counter struct {
+	limits Limits
+	total  int64
+}
+
+// entryReader fails as soon as an entry goes over the total size or the compression ratio
+type entryReader struct {
+	reader     io.Reader
+	counter    *counter
+	compressed int64
+	read       int64
+}
+
+// Read method
+func (e *entryReader) Read(p []byte) (int, error) {
+	n, err := e.reader.Read(p)
+	e.read += int64(n)
+	e.counter.total += int64(n)
+	if e.counter.total > e.counter.limits.MaxBytes {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrLimit, e.counter.limits.MaxBytes)
+	}
+	if e.compressed > 0 && e.read > ratioThreshold && e.read/e.compressed > e.counter.limits.MaxRatio {
+		return n, fmt.Errorf("%w: compression ratio above %d", ErrLimit, e.counter.limits.MaxRatio)
+	}
+	return n, err
+}
+
+// visit hands an entry to fn and inflates what fn did not read
+func (c *counter) visit(entry Entry, reader io.Reader, compressed int64, fn func(entry Entry, r io.Reader) error) error {
+	limited := &entryReader{reader: reader, counter: c, compressed: compressed}
+	if err := fn(entry, limited); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, limited); err != nil {
+		return err
+	}
+	return nil
+}
+
+// walkZip reads the entries of a zip archive
+func walkZip(content []byte, limits Limits, fn func(entry Entry, r io.Reader) error) error {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return err
+	}
+	if len(reader.File) > limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrLimit, limits.MaxEntries)
+	}
+
+	c := &counter{limits: limits}
+	for _, file := range reader.File {
+		name, ok := SafeName(file.Name)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnsafeName, file.Name)
+		}
+		entry := Entry{
+			Name:           name,
+			Size:           int64(file.UncompressedSize64),
+			CompressedSize: int64(file.CompressedSize64),
+			ModifiedOn:     file.Modified,
+			Directory:      file.FileInfo().IsDir(),
+		}
+		if entry.Directory {
+			if err := fn(entry, bytes.NewReader(nil)); err != nil {
+				return err
+			}
+			continue
+		}
+		if file.FileInfo().Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %q is a link", ErrUnsafeName, file.Name)
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = c.visit(entry, content, maxInt64(entry.CompressedSize, 1), fn)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTar reads the entries of a tar stream, compressed is the size of the
+// gzip stream it was inflated from or 0 for plain tar
+func walkTar(stream io.Reader, compressed int64, limits Limits, fn func(entry Entry, r io.Reader) error) error {
+	c := &counter{limits: limits}
+	// headers and padding of the whole stream count towards the limits of a compressed tar
+	var source io.Reader = stream
+	if compressed > 0 {
+		source = &entryReader{reader: stream, counter: &counter{limits: limits}, compressed: compressed}
+	}
+	reader := tar.NewReader(source)
+	for count := 0; ; count++ {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if count >= limits.MaxEntries {
+			return fmt.Errorf("%w: more than %d entries", ErrLimit, limits.MaxEntries)
+		}
+
+		name, ok := SafeName(header.Name)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnsafeName, header.Name)
+		}
+		entry := Entry{Name: name, Size: header.Size, ModifiedOn: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entry.Directory = true
+			if err := fn(entry, bytes.NewReader(nil)); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := c.visit(entry, reader, 0, fn); err != nil {
+				return err
+			}
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("%w: %q is a link", ErrUnsafeName, header.Name)
+		}
+	}
+}
+
+// maxInt64 returns the larger of a and b
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
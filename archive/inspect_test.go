@@ -0,0 +1,284 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testLimits are small enough for the archives built by the tests
+var testLimits = Limits{MaxEntries: 3, MaxBytes: 8 << 20, MaxRatio: 100}
+
+// zipOf builds a zip archive of name, content pairs
+func zipOf(t *testing.T, files ...string) []byte {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for i := 0; i < len(files); i += 2 {
+		entry, err := writer.CreateHeader(&zip.FileHeader{Name: files[i], Method: zip.Deflate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(files[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// tarOf builds a tar archive of the headers, regular files get size bytes of zeros
+func tarOf(t *testing.T, headers ...*tar.Header) []byte {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	for _, header := range headers {
+		if header.Typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := writer.Write(make([]byte, header.Size)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// gzipOf compresses content
+func gzipOf(t *testing.T, content []byte) []byte {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(buffer, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		cleaned string
+		ok      bool
+	}{
+		{"report.pdf", "report.pdf", true},
+		{"docs/2023/report.pdf", "docs/2023/report.pdf", true},
+		{"docs/./report.pdf", "docs/report.pdf", true},
+		{"docs\\report.pdf", "docs/report.pdf", true},
+		{"docs/", "docs", true},
+		{"..report.pdf", "..report.pdf", true},
+		{"", "", false},
+		{".", "", false},
+		{"./", "", false},
+		{"../report.pdf", "", false},
+		{"docs/../../report.pdf", "", false},
+		{"docs/../report.pdf", "", false},
+		{"..\\report.pdf", "", false},
+		{"docs\\..\\..\\report.pdf", "", false},
+		{"/etc/passwd", "", false},
+		{"\\windows\\system.ini", "", false},
+		{"C:\\windows\\system.ini", "", false},
+		{"c:report.pdf", "", false},
+		{"report\x00.pdf", "", false},
+	}
+	for _, tt := range tests {
+		cleaned, ok := SafeName(tt.name)
+		if cleaned != tt.cleaned || ok != tt.ok {
+			t.Errorf("SafeName(%q) = %q, %v, want %q, %v", tt.name, cleaned, ok, tt.cleaned, tt.ok)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	zeros := string(make([]byte, 4<<20))
+	tests := []struct {
+		name     string
+		mimeType string
+		content  func(t *testing.T) []byte
+		limits   Limits
+		entries  int
+		err      error
+	}{
+		{
+			name:     "zip",
+			mimeType: "application/zip",
+			content: func(t *testing.T) []byte {
+				return zipOf(t, "docs/", "", "docs/a.txt", "a", "b.txt", "b")
+			},
+			limits:  testLimits,
+			entries: 3,
+		},
+		{
+			name:     "zip over the entry limit",
+			mimeType: "application/zip",
+			content: func(t *testing.T) []byte {
+				return zipOf(t, "a", "a", "b", "b", "c", "c", "d", "d")
+			},
+			limits: testLimits,
+			err:    ErrLimit,
+		},
+		{
+			name:     "zip over the ratio limit",
+			mimeType: "application/zip",
+			content: func(t *testing.T) []byte {
+				return zipOf(t, "zeros.bin", zeros)
+			},
+			limits: testLimits,
+			err:    ErrLimit,
+		},
+		{
+			name:     "zip over the size limit",
+			mimeType: "application/zip",
+			content: func(t *testing.T) []byte {
+				return zipOf(t, "zeros.bin", zeros)
+			},
+			limits: Limits{MaxEntries: 3, MaxBytes: 1 << 20, MaxRatio: 1 << 30},
+			err:    ErrLimit,
+		},
+		{
+			name:     "zip small entry under the ratio threshold",
+			mimeType: "application/zip",
+			content: func(t *testing.T) []byte {
+				return zipOf(t, "zeros.bin", zeros[:ratioThreshold])
+			},
+			limits:  testLimits,
+			entries: 1,
+		},
+		{
+			name:     "zip climbing out of the root",
+			mimeType: "application/zip",
+			content: func(t *testing.T) []byte {
+				return zipOf(t, "../../etc/cron.d/job", "x")
+			},
+			limits: testLimits,
+			err:    ErrUnsafeName,
+		},
+		{
+			name:     "zip absolute name",
+			mimeType: "application/x-zip-compressed",
+			content: func(t *testing.T) []byte {
+				return zipOf(t, "/etc/passwd", "x")
+			},
+			limits: testLimits,
+			err:    ErrUnsafeName,
+		},
+		{
+			name:     "tar",
+			mimeType: "application/x-tar",
+			content: func(t *testing.T) []byte {
+				return tarOf(t, &tar.Header{Name: "docs/", Typeflag: tar.TypeDir}, &tar.Header{Name: "docs/a.txt", Size: 10})
+			},
+			limits:  testLimits,
+			entries: 2,
+		},
+		{
+			name:     "tar over the entry limit",
+			mimeType: "application/x-tar",
+			content: func(t *testing.T) []byte {
+				return tarOf(t, &tar.Header{Name: "a"}, &tar.Header{Name: "b"}, &tar.Header{Name: "c"}, &tar.Header{Name: "d"})
+			},
+			limits: testLimits,
+			err:    ErrLimit,
+		},
+		{
+			name:     "tar climbing out of the root",
+			mimeType: "application/x-tar",
+			content: func(t *testing.T) []byte {
+				return tarOf(t, &tar.Header{Name: "docs/../../a.txt", Size: 1})
+			},
+			limits: testLimits,
+			err:    ErrUnsafeName,
+		},
+		{
+			name:     "tar symlink",
+			mimeType: "application/x-tar",
+			content: func(t *testing.T) []byte {
+				return tarOf(t, &tar.Header{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+			},
+			limits: testLimits,
+			err:    ErrUnsafeName,
+		},
+		{
+			name:     "tar hard link",
+			mimeType: "application/x-tar",
+			content: func(t *testing.T) []byte {
+				return tarOf(t, &tar.Header{Name: "passwd", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"})
+			},
+			limits: testLimits,
+			err:    ErrUnsafeName,
+		},
+		{
+			name:     "gzip tar",
+			mimeType: "application/gzip",
+			content: func(t *testing.T) []byte {
+				return gzipOf(t, tarOf(t, &tar.Header{Name: "a.txt", Size: 10}))
+			},
+			limits:  testLimits,
+			entries: 1,
+		},
+		{
+			name:     "gzip tar over the ratio limit",
+			mimeType: "application/gzip",
+			content: func(t *testing.T) []byte {
+				return gzipOf(t, tarOf(t, &tar.Header{Name: "zeros.bin", Size: 4 << 20}))
+			},
+			limits: testLimits,
+			err:    ErrLimit,
+		},
+		{
+			name:     "gzip tar over the size limit",
+			mimeType: "application/x-gzip",
+			content: func(t *testing.T) []byte {
+				return gzipOf(t, tarOf(t, &tar.Header{Name: "zeros.bin", Size: 4 << 20}))
+			},
+			limits: Limits{MaxEntries: 3, MaxBytes: 1 << 20, MaxRatio: 1 << 30},
+			err:    ErrLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Inspect(tt.content(t), tt.mimeType, tt.limits)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Inspect() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if len(entries) != tt.entries {
+				t.Fatalf("Inspect() = %d entries, want %d", len(entries), tt.entries)
+			}
+		})
+	}
+}
+
+func TestWalkInflatesUnreadContent(t *testing.T) {
+	content := zipOf(t, "zeros.bin", string(make([]byte, 4<<20)))
+	err := Walk(content, "application/zip", testLimits, func(entry Entry, r io.Reader) error {
+		return nil
+	})
+	if !errors.Is(err, ErrLimit) {
+		t.Fatalf("Walk() error = %v, want %v", err, ErrLimit)
+	}
+}
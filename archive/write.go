@@ -0,0 +1,101 @@
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Writer streams a ZIP archive without a temp file. Sizes are only known once
+// an entry is written so entries carry data descriptors, and the archive
+// switches to ZIP64 records when an entry or the entry count needs them.
+type Writer struct {
+	zip      *zip.Writer
+	names    map[string]bool
+	reserved map[string]bool
+}
+
+// NewWriter starts an archive on w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zip: zip.NewWriter(w), names: map[string]bool{}, reserved: map[string]bool{}}
+}
+
+// Add writes an entry under a safe and unique form of name and returns the
+// name used, the size and the hex SHA-256 of the content. Reserved names are
+// never used, an entry named like one is numbered.
+func (w *Writer) Add(name string, mimeType string, modified time.Time, r io.Reader) (string, int64, string, error) {
+	return w.add(w.uniqueName(name), mimeType, modified, r)
+}
+
+// AddReserved writes the entry of a name kept by Reserve
+func (w *Writer) AddReserved(name string, mimeType string, modified time.Time, r io.Reader) (string, int64, string, error) {
+	if !w.reserved[name] {
+		return name, 0, "", fmt.Errorf("name %q is not reserved", name)
+	}
+	delete(w.reserved, name)
+	w.names[name] = true
+	return w.add(name, mimeType, modified, r)
+}
+
+// add writes an entry under name
+func (w *Writer) add(name string, mimeType string, modified time.Time, r io.Reader) (string, int64, string, error) {
+	method := zip.Deflate
+	if compressed(mimeType) {
+		method = zip.Store
+	}
+	entry, err := w.zip.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+	if err != nil {
+		return name, 0, "", err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(entry, hash), r)
+	return name, size, hex.EncodeToString(hash.Sum(nil)), err
+}
+
+// Reserve keeps names for entries added later with AddReserved, such as a
+// manifest written last
+func (w *Writer) Reserve(names ...string) {
+	for _, name := range names {
+		w.reserved[name] = true
+	}
+}
+
+// Close writes the central directory
+func (w *Writer) Close() error {
+	return w.zip.Close()
+}
+
+// uniqueName flattens name to a safe base name and numbers repeats, report.pdf, report (2).pdf
+func (w *Writer) uniqueName(name string) string {
+	name, ok := SafeName(name)
+	if !ok {
+		name = "file"
+	}
+	name = path.Base(name)
+	extension := path.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+	candidate := name
+	for i := 2; w.names[candidate] || w.reserved[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, extension)
+	}
+	w.names[candidate] = true
+	return candidate
+}
+
+// compressed checks for formats deflate cannot shrink
+func compressed(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "image/") && mimeType != "image/bmp" && mimeType != "image/tiff",
+		strings.HasPrefix(mimeType, "video/"), strings.HasPrefix(mimeType, "audio/"),
+		Supported(mimeType), strings.HasPrefix(mimeType, "application/vnd.openxmlformats"),
+		strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument"):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package blind
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// PrefixLength is the number of characters a prefix bucket is computed from
+const PrefixLength = 3
+
+// prefixBytes truncates prefix indexes so that many values share a bucket
+const prefixBytes = 4
+
+// Index struct computes HMAC blind indexes of encrypted fields so they can be
+// looked up without decrypting rows or giving plaintext to the database
+type Index struct {
+	key []byte
+}
+
+// New creates an index from a raw or base64 encoded key, an empty key disables it
+func New(key string) Index {
+	if decoded, err := base64.StdEncoding.DecodeString(key); err == nil && len(decoded) >= 16 {
+		return Index{key: decoded}
+	}
+	return Index{key: []byte(key)}
+}
+
+// Enabled checks if a key is configured
+func (i Index) Enabled() bool {
+	return len(i.key) > 0
+}
+
+// KeyID identifies the key that produced an index so rows can be re-indexed after rotation
+func (i Index) KeyID() string {
+	if !i.Enabled() {
+		return ""
+	}
+	return hex.EncodeToString(mac(i.key, "key-id")[:8])
+}
+
+// Exact returns the equality index of a field value
+func (i Index) Exact(field string, value string) string {
+	if !i.Enabled() || value == "" {
+		return ""
+	}
+	return hex.EncodeToString(mac(i.fieldKey(field, "exact"), value))
+}
+
+// Prefix returns the bucket of the first PrefixLength characters of a field value,
+// empty when the value is shorter. The bucket is truncated so it only narrows a
+// lookup down to candidates that still have to be decrypted and compared.
+func (i Index) Prefix(field string, value string) string {
+	if !i.Enabled() || utf8.RuneCountInString(value) < PrefixLength {
+		return ""
+	}
+	prefix := strings.ToLower(string([]rune(value)[:PrefixLength]))
+	return hex.EncodeToString(mac(i.fieldKey(field, "prefix"), prefix)[:prefixBytes])
+}
+
+// fieldKey derives a key per field and index kind so equal values do not
+// produce equal indexes across fields
+func (i Index) fieldKey(field string, kind string) []byte {
+	return mac(i.key, field+"\x00"+kind)
+}
+
+// mac computes HMAC-SHA256 of value
+func mac(key []byte, value string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(value))
+	return h.Sum(nil)
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/services"
+	"github.com/greatfocus/gf-sframe/server"
+)
+
+// Exit codes
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// admin struct holds what the commands work with
+type admin struct {
+	server      *server.Server
+	conn        *sql.DB
+	fileService *services.FileService
+}
+
+// command struct is a subcommand of gf-document
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, a *admin, args []string) int
+}
+
+// commands lists the subcommands in the order of the usage
+var commands = []command{
+	{"migrate", "migrate up|down [steps]|status|verify", "change or check the database schema", migrate},
+	{"verify-audit", "verify-audit", "check the audit chains of every tenant", verifyAudit},
+	{"list", "list [-tenant id] [-status s] [-older-than age] [-problem p] [-after id] [-limit n]", "list files of every tenant", list},
+	{"inspect", "inspect id", "show everything recorded about a file", inspect},
+	{"verify-checksums", "verify-checksums [-tenant id] [-status s] [-older-than age] [-all]", "hash stored content and compare it with the checksums", verifyChecksums},
+	{"rekey", "rekey", "encrypt file names with JWT_Secret instead of REKEY_OLD_SECRET", rekey},
+	{"purge", "purge -status s -older-than age [-tenant id] [-dry-run]", "delete files matching a policy", purge},
+	{"reconcile", "reconcile [-hash] [-repair] [-grace age]", "compare stored content with the files table", reconcile},
+	{"export", "export -dir path [-tenant id] [-status s] [-older-than age]", "copy files and their records to a directory", export},
+	{"import", "import -dir path", "store the files of an export", importFiles},
+	{"replay", "replay -queue name [-limit n]", "move dead lettered messages back to their queue", replay},
+}
+
+// Run runs the command named by args[0] and returns the exit code. Interrupting
+// the process cancels the command.
+func Run(s *server.Server, conn *sql.DB, args []string) int {
+	for _, cmd := range commands {
+		if len(args) == 0 || cmd.name != args[0] {
+			continue
+		}
+		a := &admin{server: s, conn: conn, fileService: &services.FileService{}}
+		a.fileService.Init(conn, s.Cache, s.JWT)
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		ctx = logging.WithRequestID(ctx, logging.NewRequestID())
+		ctx = services.WithAuditSource(ctx, "cli:"+cmd.name)
+		return cmd.run(ctx, a, args[1:])
+	}
+	usage(os.Stderr)
+	return exitUsage
+}
+
+// usage prints the commands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: gf-document [command]")
+	fmt.Fprintln(w, "without a command the service is started")
+	fmt.Fprintln(w)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-84s %s\n", cmd.usage, cmd.summary)
+	}
+}
+
+// flags returns the flag set of the command, errors are printed with its usage
+func flags(name string) *flag.FlagSet {
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.SetOutput(os.Stderr)
+	return set
+}
+
+// fileQuery adds the flags selecting files to set
+func fileQuery(set *flag.FlagSet, query *models.FileQuery, age *string) {
+	set.StringVar(&query.TenantID, "tenant", "", "only files of the tenant")
+	set.StringVar(&query.Status, "status", "", "only files with the status, such as temp, new or approved")
+	set.StringVar(age, "older-than", "", "only files created longer ago, such as 72h or 30d")
+}
+
+// parseAge reads an age such as 90m, 72h or 30d into the time before which files are older
+func parseAge(age string) (time.Time, error) {
+	if age == "" {
+		return time.Time{}, nil
+	}
+	duration, err := parseDuration(age)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-duration), nil
+}
+
+// parseDuration reads an age such as 90m, 72h or 30d
+func parseDuration(age string) (time.Duration, error) {
+	var duration time.Duration
+	if days := strings.TrimSuffix(age, "d"); days != age {
+		count, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", age)
+		}
+		duration = time.Duration(count) * 24 * time.Hour
+	} else {
+		var err error
+		if duration, err = time.ParseDuration(age); err != nil {
+			return 0, fmt.Errorf("invalid age %q", age)
+		}
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("invalid age %q", age)
+	}
+	return duration, nil
+}
+
+// printJSON writes v to stdout as a JSON line
+func printJSON(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// fail prints err and returns the failure exit code
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+	return exitFailure
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/task"
+)
+
+// list prints the files matching the flags as JSON lines, in order of id
+func list(ctx context.Context, a *admin, args []string) int {
+	query := models.FileQuery{}
+	var age string
+	set := flags("list")
+	fileQuery(set, &query, &age)
+	set.StringVar(&query.Problem, "problem", "", "only files marked by reconcile with the problem, such as missing")
+	set.StringVar(&query.AfterID, "after", "", "only files with a greater id, the last id of the previous page")
+	set.IntVar(&query.Limit, "limit", 100, "files to list, up to 500")
+	if err := set.Parse(args); err != nil {
+		return exitUsage
+	}
+	var err error
+	if query.Before, err = parseAge(age); err != nil {
+		return fail(err)
+	}
+
+	files, err := a.fileService.ListFiles(ctx, a.server.JWT.Secret(), query)
+	if err != nil {
+		return fail(err)
+	}
+	for _, file := range files {
+		if err := printJSON(file); err != nil {
+			return fail(err)
+		}
+	}
+	return exitOK
+}
+
+// inspect prints the record, grants, renditions, entries, jobs, audit events
+// and stored content of a file
+func inspect(ctx context.Context, a *admin, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gf-document inspect id")
+		return exitUsage
+	}
+	inspection, err := a.fileService.InspectFile(ctx, a.server.JWT.Secret(), args[0])
+	if err != nil {
+		return fail(err)
+	}
+	if err := printJSON(inspection); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// verifyChecksums prints a JSON line per file whose content is missing or does
+// not match its checksum, every file with -all. The exit code is 1 when one
+// does not match or is missing.
+func verifyChecksums(ctx context.Context, a *admin, args []string) int {
+	query := models.FileQuery{}
+	var age string
+	var all bool
+	set := flags("verify-checksums")
+	fileQuery(set, &query, &age)
+	set.BoolVar(&all, "all", false, "print the files that match too")
+	if err := set.Parse(args); err != nil {
+		return exitUsage
+	}
+	var err error
+	if query.Before, err = parseAge(age); err != nil {
+		return fail(err)
+	}
+
+	code := exitOK
+	err = a.fileService.VerifyChecksums(ctx, a.server.JWT.Secret(), query, func(result models.ChecksumResult) error {
+		bad := result.Status == models.ChecksumMismatch || result.Status == models.ChecksumMissing
+		if bad {
+			code = exitFailure
+		}
+		if bad || all {
+			return printJSON(result)
+		}
+		return nil
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return code
+}
+
+// purge deletes the files matching the policy and prints each as a JSON line,
+// -dry-run only prints them
+func purge(ctx context.Context, a *admin, args []string) int {
+	policy := models.FileQuery{}
+	var age string
+	var dryRun bool
+	set := flags("purge")
+	fileQuery(set, &policy, &age)
+	set.BoolVar(&dryRun, "dry-run", false, "print the files without deleting them")
+	if err := set.Parse(args); err != nil {
+		return exitUsage
+	}
+	var err error
+	if policy.Before, err = parseAge(age); err != nil {
+		return fail(err)
+	}
+	if age == "" {
+		return fail(errors.New("purge needs -older-than"))
+	}
+
+	count := 0
+	err = a.fileService.Purge(ctx, a.server.JWT.Secret(), policy, dryRun, func(file models.FileRecord) error {
+		count++
+		return printJSON(file)
+	})
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "%d files would be purged\n", count)
+	} else {
+		fmt.Fprintf(os.Stderr, "%d files purged\n", count)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// reconcile prints a JSON line per file or stored content out of step, the
+// exit code is 1 when a problem is left that -repair did not fix
+func reconcile(ctx context.Context, a *admin, args []string) int {
+	options := task.ReconcileOptions()
+	options.Repair = false
+	var grace string
+	set := flags("reconcile")
+	set.BoolVar(&options.Hash, "hash", false, "hash stored content and compare it with the checksums")
+	set.BoolVar(&options.Repair, "repair", false, "delete orphans past the grace period, move misplaced content and mark broken records")
+	set.StringVar(&grace, "grace", "", "age orphans must reach to be deleted, such as 72h or 30d, RECONCILE_GRACE_HOURS by default")
+	if err := set.Parse(args); err != nil {
+		return exitUsage
+	}
+	if grace != "" {
+		var err error
+		if options.Grace, err = parseDuration(grace); err != nil {
+			return fail(err)
+		}
+	}
+
+	report, err := a.fileService.Reconcile(ctx, a.server.JWT.Secret(), options, func(problem models.ReconcileProblem) error {
+		return printJSON(problem)
+	})
+	fmt.Fprintf(os.Stderr, "%d files and %d blobs checked, %d problems, %d repaired, %d marked\n",
+		report.Files, report.Blobs, report.Found(), report.Repaired, report.Marked)
+	if err != nil {
+		return fail(err)
+	}
+	if report.Unrepaired() > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/greatfocus/gf-document/database"
+	"github.com/greatfocus/gf-document/models"
+)
+
+// migrate runs up, down [steps], status or verify, status prints a JSON line per migration
+func migrate(ctx context.Context, a *admin, args []string) int {
+	migrator, err := database.NewMigrator(a.conn, a.server.Logger)
+	if err != nil {
+		return fail(err)
+	}
+
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "up":
+		count, err := migrator.Up(ctx)
+		fmt.Printf("%d migrations applied\n", count)
+		if err != nil {
+			return fail(err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "steps must be a positive number")
+				return exitUsage
+			}
+		}
+		count, err := migrator.Down(ctx, steps)
+		fmt.Printf("%d migrations reverted\n", count)
+		if err != nil {
+			return fail(err)
+		}
+	case "status":
+		states, err := migrator.Status(ctx)
+		if err != nil {
+			return fail(err)
+		}
+		for _, state := range states {
+			if err := printJSON(state); err != nil {
+				return fail(err)
+			}
+		}
+	case "verify":
+		if err := migrator.Verify(ctx); err != nil {
+			return fail(err)
+		}
+		fmt.Println("schema is up to date")
+	default:
+		fmt.Fprintln(os.Stderr, "usage: gf-document migrate up|down [steps]|status|verify")
+		return exitUsage
+	}
+	return exitOK
+}
+
+// verifyAudit checks the audit chains and prints a result per tenant as a
+// JSON line, the exit code is 1 when a chain is broken or cannot be read
+func verifyAudit(ctx context.Context, a *admin, args []string) int {
+	results, err := a.fileService.VerifyAudit(ctx, models.SystemActor)
+	if err != nil {
+		return fail(fmt.Errorf("audit verification failed: %w", err))
+	}
+	code := exitOK
+	for _, result := range results {
+		if !result.Valid {
+			code = exitFailure
+		}
+		if err := printJSON(result); err != nil {
+			return fail(err)
+		}
+	}
+	return code
+}
+
+// rekey encrypts the name of every file with JWT_Secret, the key the service
+// uses, instead of REKEY_OLD_SECRET. It can be run again after an interruption.
+func rekey(ctx context.Context, a *admin, args []string) int {
+	oldKey := os.Getenv("REKEY_OLD_SECRET")
+	if oldKey == "" {
+		return fail(errors.New("REKEY_OLD_SECRET is not set"))
+	}
+	result, err := a.fileService.Rekey(ctx, oldKey, a.server.JWT.Secret())
+	if perr := printJSON(result); perr != nil && err == nil {
+		err = perr
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if len(result.Failed) > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/greatfocus/gf-document/task"
+)
+
+// replay moves messages from the dead letter queue of a consumed queue back to it
+func replay(ctx context.Context, a *admin, args []string) int {
+	var queue string
+	var limit int
+	set := flags("replay")
+	set.StringVar(&queue, "queue", "", "consumed queue whose dead letters are replayed")
+	set.IntVar(&limit, "limit", 100, "messages to replay")
+	if err := set.Parse(args); err != nil {
+		return exitUsage
+	}
+	if !consumed(queue) {
+		fmt.Fprintf(os.Stderr, "queue must be one of %v\n", task.Queues)
+		return exitUsage
+	}
+	url := os.Getenv("RABBITMQ_URL")
+	if url == "" {
+		return fail(errors.New("RABBITMQ_URL is not set"))
+	}
+
+	count, err := task.ReplayDeadLetters(ctx, url, queue, limit)
+	fmt.Printf("%d messages replayed from %s\n", count, task.DeadLetterQueue(queue))
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// consumed reports if the service consumes the queue
+func consumed(queue string) bool {
+	for _, name := range task.Queues {
+		if name == queue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/greatfocus/gf-document/models"
+)
+
+// export copies the files matching the flags with their records to a directory
+// and prints the counts, the exit code is 1 when a file could not be copied
+func export(ctx context.Context, a *admin, args []string) int {
+	query := models.FileQuery{}
+	var age, dir string
+	set := flags("export")
+	fileQuery(set, &query, &age)
+	set.StringVar(&dir, "dir", "", "directory to export to, its manifest must not exist")
+	if err := set.Parse(args); err != nil {
+		return exitUsage
+	}
+	if dir == "" {
+		return fail(errors.New("export needs -dir"))
+	}
+	var err error
+	if query.Before, err = parseAge(age); err != nil {
+		return fail(err)
+	}
+
+	result, err := a.fileService.Export(ctx, a.server.JWT.Secret(), query, dir)
+	return transferred(result, err)
+}
+
+// importFiles stores the files of an export and prints the counts, the exit
+// code is 1 when a file could not be imported
+func importFiles(ctx context.Context, a *admin, args []string) int {
+	var dir string
+	set := flags("import")
+	set.StringVar(&dir, "dir", "", "directory of the export")
+	if err := set.Parse(args); err != nil {
+		return exitUsage
+	}
+	if dir == "" {
+		return fail(errors.New("import needs -dir"))
+	}
+
+	result, err := a.fileService.Import(ctx, a.server.JWT.Secret(), dir)
+	return transferred(result, err)
+}
+
+// transferred prints the result of an export or import and returns the exit code
+func transferred(result models.TransferResult, err error) int {
+	if perr := printJSON(result); perr != nil && err == nil {
+		err = perr
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if len(result.Failed) > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
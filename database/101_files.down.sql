@@ -0,0 +1 @@
+DROP TABLE IF EXISTS files;
\ No newline at end of file
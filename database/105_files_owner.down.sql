@@ -0,0 +1,2 @@
+ALTER TABLE files DROP COLUMN IF EXISTS origin;
+ALTER TABLE files DROP COLUMN IF EXISTS actorId;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS actorId BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS origin VARCHAR(255) NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS grants (
+	id VARCHAR(40) PRIMARY KEY,
+	fileId VARCHAR(40) NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	granteeType VARCHAR(10) NOT NULL,
+	granteeId VARCHAR(255) NOT NULL,
+	permission VARCHAR(10) NOT NULL,
+	grantedBy BIGINT NOT NULL,
+	createdOn TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(fileId, granteeType, granteeId, permission)
+);
@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_files_actorId;
\ No newline at end of file
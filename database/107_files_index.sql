@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_files_actorId ON files USING BTREE(actorId);
\ No newline at end of file
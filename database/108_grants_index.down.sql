@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_grants_grantee;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_grants_grantee ON grants USING BTREE(granteeType, granteeId, permission);
\ No newline at end of file
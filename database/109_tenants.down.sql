@@ -0,0 +1,2 @@
+ALTER TABLE grants DROP COLUMN IF EXISTS tenantId;
+ALTER TABLE files DROP COLUMN IF EXISTS tenantId;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS tenantId VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE grants ADD COLUMN IF NOT EXISTS tenantId VARCHAR(64) NOT NULL DEFAULT 'default';
@@ -0,0 +1,7 @@
+DROP POLICY IF EXISTS grants_tenant_isolation ON grants;
+ALTER TABLE grants NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE grants DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS files_tenant_isolation ON files;
+ALTER TABLE files NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE files DISABLE ROW LEVEL SECURITY;
\ No newline at end of file
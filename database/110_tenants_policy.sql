@@ -0,0 +1,11 @@
+ALTER TABLE files ENABLE ROW LEVEL SECURITY;
+ALTER TABLE files FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS files_tenant_isolation ON files;
+CREATE POLICY files_tenant_isolation ON files
+	USING (tenantId = current_setting('app.tenant_id', true) OR current_setting('app.tenant_id', true) = '*');
+
+ALTER TABLE grants ENABLE ROW LEVEL SECURITY;
+ALTER TABLE grants FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS grants_tenant_isolation ON grants;
+CREATE POLICY grants_tenant_isolation ON grants
+	USING (tenantId = current_setting('app.tenant_id', true) OR current_setting('app.tenant_id', true) = '*');
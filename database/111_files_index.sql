@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_files_tenantId ON files USING BTREE(tenantId, createdOn DESC);
\ No newline at end of file
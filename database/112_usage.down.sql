@@ -0,0 +1 @@
+DROP TABLE IF EXISTS storage_usage;
\ No newline at end of file
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS storage_usage (
+	tenantId VARCHAR(64) NOT NULL,
+	scope VARCHAR(10) NOT NULL,
+	actorId BIGINT NOT NULL DEFAULT 0,
+	bytes BIGINT NOT NULL DEFAULT 0,
+	files BIGINT NOT NULL DEFAULT 0,
+	updatedOn TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (tenantId, scope, actorId)
+);
+
+ALTER TABLE storage_usage ENABLE ROW LEVEL SECURITY;
+ALTER TABLE storage_usage FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS storage_usage_tenant_isolation ON storage_usage;
+CREATE POLICY storage_usage_tenant_isolation ON storage_usage
+	USING (tenantId = current_setting('app.tenant_id', true) OR current_setting('app.tenant_id', true) = '*');
+
+-- count the files stored before usage was tracked
+SELECT set_config('app.tenant_id', '*', true);
+INSERT INTO storage_usage (tenantId, scope, actorId, bytes, files)
+SELECT tenantId, 'tenant', 0, SUM(size), COUNT(1) FROM files GROUP BY tenantId
+ON CONFLICT DO NOTHING;
+INSERT INTO storage_usage (tenantId, scope, actorId, bytes, files)
+SELECT tenantId, 'actor', actorId, SUM(size), COUNT(1) FROM files GROUP BY tenantId, actorId
+ON CONFLICT DO NOTHING;
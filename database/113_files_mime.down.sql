@@ -0,0 +1 @@
+ALTER TABLE files DROP COLUMN IF EXISTS mimeType;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS mimeType VARCHAR(255) NOT NULL DEFAULT '';
\ No newline at end of file
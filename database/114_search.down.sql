@@ -0,0 +1 @@
+DROP TABLE IF EXISTS search;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS search (
+	fileId VARCHAR(40) PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+	tenantId VARCHAR(64) NOT NULL,
+	language REGCONFIG NOT NULL DEFAULT 'simple',
+	metadata TEXT NOT NULL DEFAULT '',
+	content TEXT NOT NULL DEFAULT '',
+	searchVector TSVECTOR NOT NULL,
+	updatedOn TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE search ENABLE ROW LEVEL SECURITY;
+ALTER TABLE search FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS search_tenant_isolation ON search;
+CREATE POLICY search_tenant_isolation ON search
+	USING (tenantId = current_setting('app.tenant_id', true) OR current_setting('app.tenant_id', true) = '*');
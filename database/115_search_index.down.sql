@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_search_vector;
\ No newline at end of file
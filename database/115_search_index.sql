@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_search_vector ON search USING GIN(searchVector);
\ No newline at end of file
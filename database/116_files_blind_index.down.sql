@@ -0,0 +1,3 @@
+ALTER TABLE files DROP COLUMN IF EXISTS indexKeyId;
+ALTER TABLE files DROP COLUMN IF EXISTS namePrefixIndex;
+ALTER TABLE files DROP COLUMN IF EXISTS nameIndex;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS nameIndex VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN IF NOT EXISTS namePrefixIndex VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN IF NOT EXISTS indexKeyId VARCHAR(16) NOT NULL DEFAULT '';
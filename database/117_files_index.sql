@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_files_nameIndex ON files USING BTREE(tenantId, nameIndex);
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_files_namePrefixIndex ON files USING BTREE(tenantId, namePrefixIndex);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS renditions;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS renditions (
+	id VARCHAR(40) PRIMARY KEY,
+	fileId VARCHAR(40) NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	tenantId VARCHAR(64) NOT NULL,
+	name VARCHAR(32) NOT NULL,
+	mimeType VARCHAR(255) NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	size BIGINT NOT NULL,
+	createdOn TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(fileId, name)
+);
+
+ALTER TABLE renditions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE renditions FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS renditions_tenant_isolation ON renditions;
+CREATE POLICY renditions_tenant_isolation ON renditions
+	USING (tenantId = current_setting('app.tenant_id', true) OR current_setting('app.tenant_id', true) = '*');
\ No newline at end of file
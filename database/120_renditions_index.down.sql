@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_renditions_tenant;
\ No newline at end of file
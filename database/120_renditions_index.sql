@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_renditions_tenant ON renditions USING BTREE(tenantId);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS parentId VARCHAR(40) NULL REFERENCES files(id) ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS file_entries;
\ No newline at end of file
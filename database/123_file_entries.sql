@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS file_entries (
+	id VARCHAR(40) PRIMARY KEY,
+	fileId VARCHAR(40) NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	tenantId VARCHAR(64) NOT NULL,
+	position INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	compressedSize BIGINT NOT NULL DEFAULT 0,
+	modifiedOn TIMESTAMP NULL,
+	directory BOOLEAN NOT NULL DEFAULT FALSE,
+	childId VARCHAR(40) NULL REFERENCES files(id) ON DELETE SET NULL,
+	UNIQUE(fileId, position)
+);
+
+ALTER TABLE file_entries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE file_entries FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS file_entries_tenant_isolation ON file_entries;
+CREATE POLICY file_entries_tenant_isolation ON file_entries
+	USING (tenantId = current_setting('app.tenant_id', true) OR current_setting('app.tenant_id', true) = '*');
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_files_parent;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_files_parent ON files USING BTREE(parentId) WHERE parentId IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS jobs (
+	id VARCHAR(40) PRIMARY KEY,
+	fileId VARCHAR(40) NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+	tenantId VARCHAR(64) NOT NULL,
+	type VARCHAR(32) NOT NULL,
+	payload JSONB NOT NULL DEFAULT '{}',
+	status VARCHAR(16) NOT NULL DEFAULT 'queued',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	maxAttempts INTEGER NOT NULL,
+	runAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	lockedUntil TIMESTAMP NULL,
+	lastError TEXT NULL,
+	createdOn TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updatedOn TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE jobs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE jobs FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS jobs_tenant_isolation ON jobs;
+CREATE POLICY jobs_tenant_isolation ON jobs
+	USING (tenantId = current_setting('app.tenant_id', true) OR current_setting('app.tenant_id', true) = '*');
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_jobs_due;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_jobs_due ON jobs USING BTREE(runAt) WHERE status IN ('queued', 'running');
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE files DROP COLUMN IF EXISTS checksum;
+ALTER TABLE files DROP COLUMN IF EXISTS stages;
\ No newline at end of file
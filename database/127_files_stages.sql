@@ -0,0 +1,2 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS stages JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE files ADD COLUMN IF NOT EXISTS checksum VARCHAR(64) NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- dropping the table is not stopped by the append only triggers, they go with it
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
\ No newline at end of file
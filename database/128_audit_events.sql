@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+	id VARCHAR(40) PRIMARY KEY,
+	tenantId VARCHAR(64) NOT NULL,
+	seq BIGINT NOT NULL,
+	fileId VARCHAR(40) NULL,
+	actorId BIGINT NOT NULL DEFAULT 0,
+	origin VARCHAR(64) NOT NULL DEFAULT '',
+	action VARCHAR(32) NOT NULL,
+	requestId VARCHAR(128) NOT NULL DEFAULT '',
+	detail TEXT NOT NULL DEFAULT '{}',
+	createdOn TIMESTAMP NOT NULL,
+	prevHash CHAR(64) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	UNIQUE(tenantId, seq)
+);
+
+ALTER TABLE audit_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_events FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS audit_events_tenant_isolation ON audit_events;
+CREATE POLICY audit_events_tenant_isolation ON audit_events
+	USING (tenantId = current_setting('app.tenant_id', true) OR current_setting('app.tenant_id', true) = '*');
+
+-- events are appended only, fileId has no foreign key so events outlive their files
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_audit_events_file;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_audit_events_file ON audit_events USING BTREE(tenantId, fileId, createdOn);
\ No newline at end of file
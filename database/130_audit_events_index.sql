@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_audit_events_actor ON audit_events USING BTREE(tenantId, actorId, createdOn);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE files DROP COLUMN IF EXISTS problemOn;
+ALTER TABLE files DROP COLUMN IF EXISTS problem;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS problem VARCHAR(32) NULL;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS problemOn TIMESTAMP NULL;
\ No newline at end of file
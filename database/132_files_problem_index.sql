@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_files_problem ON files USING BTREE(tenantId, problem) WHERE problem IS NOT NULL;
\ No newline at end of file
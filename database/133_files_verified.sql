@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS verifiedOn TIMESTAMP NULL;
\ No newline at end of file
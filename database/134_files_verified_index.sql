@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_files_verified ON files USING BTREE(verifiedOn NULLS FIRST, id) WHERE checksum IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS originalName TEXT NULL;
\ No newline at end of file
@@ -0,0 +1,494 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// files are the migrations built into the binary. NNN_name.sql migrates up to
+// version NNN and NNN_name.down.sql, when present, reverts it.
+//
+//go:embed *.sql
+var files embed.FS
+
+// Startup modes, MIGRATE_ON_START
+const (
+	ModeApply  = "apply"
+	ModeVerify = "verify"
+)
+
+// migrationPattern matches the migration files, for example 125_jobs.sql or 125_jobs.down.sql
+var migrationPattern = regexp.MustCompile(`^([0-9]+)_([A-Za-z0-9_]+?)(\.down)?\.sql$`)
+
+// concurrentPattern finds statements postgres refuses to run inside a transaction
+var concurrentPattern = regexp.MustCompile(`(?i)\bINDEX\s+CONCURRENTLY\b`)
+
+// concurrentIndexPattern finds the name of an index created CONCURRENTLY
+var concurrentIndexPattern = regexp.MustCompile(`(?i)\bCREATE\s+(?:UNIQUE\s+)?INDEX\s+CONCURRENTLY\s+(?:IF\s+NOT\s+EXISTS\s+)?("[^"]+"|[A-Za-z0-9_.]+)`)
+
+// ErrSchemaBehind is returned when migrations of the binary are not applied
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// schema records the migrations applied
+const schema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum CHAR(64) NOT NULL,
+	appliedOn TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Migration struct is a version of the schema
+type Migration struct {
+	Version  int64
+	Name     string
+	Checksum string
+	up       string
+	down     string
+}
+
+// transactional reports if sql can run in a transaction. Creating or dropping
+// an index CONCURRENTLY cannot, such a migration must hold that one statement.
+func transactional(sql string) bool {
+	return !concurrentPattern.MatchString(sql)
+}
+
+// concurrentIndex returns the name of the index a migration creates
+// CONCURRENTLY, empty when it creates none or leaves the name to postgres
+func concurrentIndex(sql string) string {
+	match := concurrentIndexPattern.FindStringSubmatch(sql)
+	if match == nil || strings.EqualFold(match[1], "ON") {
+		return ""
+	}
+	return match[1]
+}
+
+// MigrationState struct is a migration and whether it is applied
+type MigrationState struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedOn *time.Time `json:"appliedOn,omitempty"`
+	Changed   bool       `json:"changed,omitempty"`
+	Unknown   bool       `json:"unknown,omitempty"`
+}
+
+// applied is a row of schema_migrations
+type applied struct {
+	version   int64
+	name      string
+	checksum  string
+	appliedOn time.Time
+}
+
+// Migrations returns the migrations built into the binary in order of version
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	downs := map[int64]string{}
+	for _, entry := range entries {
+		match := migrationPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named NNN_name.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := files.ReadFile(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] != "" {
+			downs[version] = string(content)
+			continue
+		}
+		if _, ok := byVersion[version]; ok {
+			return nil, fmt.Errorf("migration %d is defined twice", version)
+		}
+		sum := sha256.Sum256(content)
+		byVersion[version] = &Migration{Version: version, Name: match[2], Checksum: hex.EncodeToString(sum[:]), up: string(content)}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, down := range downs {
+		migration, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("down migration %d has no up migration", version)
+		}
+		migration.down = down
+	}
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Latest returns the version of the newest migration built into the binary
+func Latest() int64 {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrator struct applies the migrations built into the binary. Every change
+// holds an advisory lock so replicas starting together migrate one at a time.
+type Migrator struct {
+	conn       *sql.DB
+	logger     *logrus.Logger
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator of the database behind conn
+func NewMigrator(conn *sql.DB, logger *logrus.Logger) (*Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{conn: conn, logger: logger, migrations: migrations}, nil
+}
+
+// Start prepares the schema for the service. Mode apply runs the pending
+// migrations, mode verify only checks them. Either fails when the schema is
+// behind or an applied migration was changed, the service must not serve then.
+func (m *Migrator) Start(ctx context.Context, mode string) error {
+	switch mode {
+	case "", ModeApply:
+		if _, err := m.Up(ctx); err != nil {
+			return err
+		}
+	case ModeVerify:
+	default:
+		return fmt.Errorf("unknown MIGRATE_ON_START %q, expected %s or %s", mode, ModeApply, ModeVerify)
+	}
+	return m.Verify(ctx)
+}
+
+// Up applies the pending migrations in order and returns how many ran. Each
+// runs in a transaction with its record, except those creating an index
+// CONCURRENTLY which are recorded once the index is valid.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.locked(ctx, func(c *sql.Conn) error {
+		done, err := m.applied(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := m.verifyChecksums(done); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			started := time.Now()
+			record := func(ctx context.Context, exec execer) error {
+				_, err := exec.ExecContext(ctx, "insert into schema_migrations (version, name, checksum) values ($1, $2, $3)",
+					migration.Version, migration.Name, migration.Checksum)
+				return err
+			}
+			if err := run(ctx, c, migration.up, record); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.WithFields(logrus.Fields{
+				"version":     migration.Version,
+				"name":        migration.Name,
+				"duration_ms": time.Since(started).Milliseconds(),
+			}).Info("migration applied")
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts the newest steps applied migrations and returns how many ran
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.locked(ctx, func(c *sql.Conn) error {
+		done, err := m.applied(ctx, c)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(done))
+		for version := range done {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] > versions[j]
+		})
+
+		known := m.byVersion()
+		for _, version := range versions {
+			if count == steps {
+				break
+			}
+			migration, ok := known[version]
+			if !ok {
+				return fmt.Errorf("migration %d is not known to this binary", version)
+			}
+			if migration.down == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+			record := func(ctx context.Context, exec execer) error {
+				_, err := exec.ExecContext(ctx, "delete from schema_migrations where version = $1", migration.Version)
+				return err
+			}
+			if err := run(ctx, c, migration.down, record); err != nil {
+				return fmt.Errorf("revert of %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.WithFields(logrus.Fields{
+				"version": migration.Version,
+				"name":    migration.Name,
+			}).Info("migration reverted")
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status returns the migrations of the binary and the database in order of version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationState, error) {
+	done, err := m.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := []MigrationState{}
+	for _, migration := range m.migrations {
+		state := MigrationState{Version: migration.Version, Name: migration.Name}
+		if row, ok := done[migration.Version]; ok {
+			appliedOn := row.appliedOn
+			state.Applied = true
+			state.AppliedOn = &appliedOn
+			state.Changed = row.checksum != migration.Checksum
+		}
+		states = append(states, state)
+	}
+	known := m.byVersion()
+	for version, row := range done {
+		if _, ok := known[version]; !ok {
+			appliedOn := row.appliedOn
+			states = append(states, MigrationState{Version: version, Name: row.name, Applied: true, AppliedOn: &appliedOn, Unknown: true})
+		}
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Version < states[j].Version
+	})
+	return states, nil
+}
+
+// Verify checks every migration of the binary is applied unchanged. Migrations
+// the binary does not know are left to the newer release that applied them.
+func (m *Migrator) Verify(ctx context.Context) error {
+	done, err := m.current(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verifyChecksums(done); err != nil {
+		return err
+	}
+
+	pending := []string{}
+	for _, migration := range m.migrations {
+		if _, ok := done[migration.Version]; !ok {
+			pending = append(pending, strconv.FormatInt(migration.Version, 10))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w, pending migrations %s", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// verifyChecksums fails when a file differs from the one that was applied
+func (m *Migrator) verifyChecksums(done map[int64]applied) error {
+	for _, migration := range m.migrations {
+		row, ok := done[migration.Version]
+		if ok && row.checksum != migration.Checksum {
+			return fmt.Errorf("migration %d_%s was changed after it was applied", migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// byVersion indexes the migrations of the binary
+func (m *Migrator) byVersion() map[int64]Migration {
+	known := map[int64]Migration{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	return known
+}
+
+// current reads schema_migrations without taking the lock, a database never
+// migrated has none applied
+func (m *Migrator) current(ctx context.Context) (map[int64]applied, error) {
+	var found sql.NullString
+	if err := m.conn.QueryRowContext(ctx, "select to_regclass('schema_migrations')::text").Scan(&found); err != nil {
+		return nil, err
+	}
+	if !found.Valid {
+		return map[int64]applied{}, nil
+	}
+	c, err := m.conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+	return m.applied(ctx, c)
+}
+
+// applied reads schema_migrations
+func (m *Migrator) applied(ctx context.Context, c *sql.Conn) (map[int64]applied, error) {
+	rows, err := c.QueryContext(ctx, "select version, name, checksum, appliedOn from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	done := map[int64]applied{}
+	for rows.Next() {
+		row := applied{}
+		if err := rows.Scan(&row.version, &row.name, &row.checksum, &row.appliedOn); err != nil {
+			return nil, err
+		}
+		done[row.version] = row
+	}
+	return done, rows.Err()
+}
+
+// locked runs fn on a connection holding the migration lock, creating
+// schema_migrations first. The lock belongs to the session so it covers the
+// statements that cannot run in a transaction.
+func (m *Migrator) locked(ctx context.Context, fn func(c *sql.Conn) error) error {
+	c, err := m.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	if _, err := c.ExecContext(ctx, "select pg_advisory_lock(hashtext('schema_migrations'))"); err != nil {
+		return err
+	}
+	defer func() {
+		// the connection goes back to the pool, when the lock cannot be released
+		// it is discarded so closing the session releases the lock
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := c.ExecContext(unlockCtx, "select pg_advisory_unlock(hashtext('schema_migrations'))"); err != nil {
+			_ = c.Raw(func(interface{}) error {
+				return driver.ErrBadConn
+			})
+		}
+	}()
+
+	if _, err := c.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	return fn(c)
+}
+
+// execer runs a statement in a transaction or on a connection
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// run executes the statements of a migration then record, in one transaction
+// when the statements allow it. A failed CONCURRENTLY build leaves an invalid
+// index that IF NOT EXISTS would keep, so it is dropped before a retry and the
+// index must be valid before the migration is recorded.
+func run(ctx context.Context, c *sql.Conn, statements string, record func(ctx context.Context, exec execer) error) error {
+	if !transactional(statements) {
+		index := concurrentIndex(statements)
+		if index != "" {
+			if err := dropInvalidIndex(ctx, c, index); err != nil {
+				return err
+			}
+		}
+		if _, err := c.ExecContext(ctx, statements); err != nil {
+			return err
+		}
+		if index != "" {
+			found, valid, err := indexState(ctx, c, index)
+			if err != nil {
+				return err
+			}
+			if !found || !valid {
+				return fmt.Errorf("index %s is not valid", index)
+			}
+		}
+		return record(ctx, c)
+	}
+
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if err := record(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// indexState reports if the index exists and is valid, postgres leaves an
+// index invalid when building it CONCURRENTLY failed
+func indexState(ctx context.Context, c *sql.Conn, index string) (bool, bool, error) {
+	var valid bool
+	err := c.QueryRowContext(ctx, "select indisvalid from pg_index where indexrelid = to_regclass($1)", index).Scan(&valid)
+	switch err {
+	case sql.ErrNoRows:
+		return false, false, nil
+	case nil:
+		return true, valid, nil
+	default:
+		return false, false, err
+	}
+}
+
+// dropInvalidIndex drops the index left invalid by an earlier attempt
+func dropInvalidIndex(ctx context.Context, c *sql.Conn, index string) error {
+	found, valid, err := indexState(ctx, c, index)
+	if err != nil || !found || valid {
+		return err
+	}
+	_, err = c.ExecContext(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+index)
+	return err
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMigrationPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		label   string
+		down    bool
+		ok      bool
+	}{
+		{"125_jobs.sql", "125", "jobs", false, true},
+		{"125_jobs.down.sql", "125", "jobs", true, true},
+		{"001_files_index_2.sql", "001", "files_index_2", false, true},
+		{"125_jobs.SQL", "", "", false, false},
+		{"jobs.sql", "", "", false, false},
+		{"125-jobs.sql", "", "", false, false},
+		{"125_jobs.up.sql", "", "", false, false},
+		{"125_.sql", "", "", false, false},
+		{"125_jobs.sql.bak", "", "", false, false},
+	}
+	for _, tt := range tests {
+		match := migrationPattern.FindStringSubmatch(tt.name)
+		if (match != nil) != tt.ok {
+			t.Errorf("%s matched = %v, want %v", tt.name, match != nil, tt.ok)
+			continue
+		}
+		if match == nil {
+			continue
+		}
+		if match[1] != tt.version || match[2] != tt.label || (match[3] != "") != tt.down {
+			t.Errorf("%s = %q, %q, %q", tt.name, match[1], match[2], match[3])
+		}
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Migrations() found none")
+	}
+	for i, migration := range migrations {
+		if i > 0 && migration.Version <= migrations[i-1].Version {
+			t.Errorf("migration %d follows %d", migration.Version, migrations[i-1].Version)
+		}
+		content, err := files.ReadFile(fmt.Sprintf("%03d_%s.sql", migration.Version, migration.Name))
+		if err != nil {
+			t.Errorf("migration %d: %v", migration.Version, err)
+			continue
+		}
+		sum := sha256.Sum256(content)
+		if migration.Checksum != hex.EncodeToString(sum[:]) {
+			t.Errorf("migration %d checksum = %s", migration.Version, migration.Checksum)
+		}
+		if migration.up != string(content) {
+			t.Errorf("migration %d up is not its file", migration.Version)
+		}
+	}
+	if Latest() != migrations[len(migrations)-1].Version {
+		t.Errorf("Latest() = %d", Latest())
+	}
+}
+
+func TestConcurrentMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+	for _, migration := range migrations {
+		for direction, statements := range map[string]string{"up": migration.up, "down": migration.down} {
+			if transactional(statements) {
+				continue
+			}
+			// outside a transaction a failed statement would leave the others applied
+			if count := strings.Count(strings.TrimSpace(statements), ";"); count > 1 {
+				t.Errorf("migration %d %s holds %d statements", migration.Version, direction, count)
+			}
+			if direction == "up" && strings.Contains(strings.ToUpper(statements), "CREATE") && concurrentIndex(statements) == "" {
+				t.Errorf("migration %d creates an index without a name", migration.Version)
+			}
+		}
+	}
+}
+
+func TestTransactional(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"CREATE TABLE jobs (id UUID);", true},
+		{"CREATE INDEX idx_jobs_due ON jobs (dueOn);", true},
+		{"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_jobs_due ON jobs (dueOn);", false},
+		{"create unique index\n  concurrently idx_files_id on files (id);", false},
+		{"DROP INDEX CONCURRENTLY IF EXISTS idx_jobs_due;", false},
+		{"ALTER TABLE files ADD COLUMN concurrently_edited BOOLEAN;", true},
+	}
+	for _, tt := range tests {
+		if got := transactional(tt.sql); got != tt.want {
+			t.Errorf("transactional(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestConcurrentIndex(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_files_refId ON files USING BTREE(refId);", "idx_files_refId"},
+		{"CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS idx_files_id ON files USING BTREE(id DESC);", "idx_files_id"},
+		{"create index concurrently idx_jobs_due on jobs (dueOn);", "idx_jobs_due"},
+		{"CREATE INDEX CONCURRENTLY public.idx_jobs_due ON jobs (dueOn);", "public.idx_jobs_due"},
+		{`CREATE INDEX CONCURRENTLY "idx Jobs" ON jobs (dueOn);`, `"idx Jobs"`},
+		{"CREATE INDEX CONCURRENTLY ON jobs (dueOn);", ""},
+		{"DROP INDEX CONCURRENTLY IF EXISTS idx_jobs_due;", ""},
+		{"CREATE INDEX idx_jobs_due ON jobs (dueOn);", ""},
+	}
+	for _, tt := range tests {
+		if got := concurrentIndex(tt.sql); got != tt.want {
+			t.Errorf("concurrentIndex(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	m := &Migrator{migrations: []Migration{
+		{Version: 1, Name: "files", Checksum: "a"},
+		{Version: 2, Name: "grants", Checksum: "b"},
+	}}
+	tests := []struct {
+		name    string
+		done    map[int64]applied
+		wantErr bool
+	}{
+		{"none applied", map[int64]applied{}, false},
+		{"applied unchanged", map[int64]applied{1: {checksum: "a"}, 2: {checksum: "b"}}, false},
+		{"applied by a newer release", map[int64]applied{1: {checksum: "a"}, 3: {checksum: "c"}}, false},
+		{"changed after it was applied", map[int64]applied{1: {checksum: "a"}, 2: {checksum: "x"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.verifyChecksums(tt.done); (err != nil) != tt.wantErr {
+				t.Fatalf("verifyChecksums() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
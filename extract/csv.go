@@ -0,0 +1,40 @@
+package extract
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+)
+
+func init() {
+	Register("text/csv", csvText)
+	Register("application/csv", csvText)
+}
+
+// csvText extracts the fields of a CSV file, tolerating ragged rows and stray quotes
+func csvText(ctx context.Context, r io.ReaderAt, size int64) (string, error) {
+	reader := csv.NewReader(io.LimitReader(io.NewSectionReader(r, 0, size), maxTextBytes))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+
+	text := &textBuilder{}
+	for count := 0; !text.full(); count++ {
+		if count%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return text.String(), err
+			}
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return text.String(), err
+		}
+		for _, field := range record {
+			text.add(field)
+		}
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,90 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds the time spent on one document when EXTRACT_TIMEOUT is unset
+const defaultTimeout = 10 * time.Second
+
+// errTooLarge is returned for documents that go over the extraction limits
+var errTooLarge = errors.New("document is too large to extract")
+
+// Extractor returns the text of a document of size bytes read from r.
+// Extractors stop when ctx is done and keep to the package limits.
+type Extractor func(ctx context.Context, r io.ReaderAt, size int64) (string, error)
+
+// extractors maps mime types to their extractor
+var extractors = map[string]Extractor{}
+
+// Register adds the extractor of a mime type, replacing an earlier one
+func Register(mimeType string, extractor Extractor) {
+	extractors[mimeType] = extractor
+}
+
+// Supported checks if text can be extracted from the mime type
+func Supported(mimeType string) bool {
+	_, found := extractors[mimeType]
+	return found || IsText(mimeType)
+}
+
+// timeout reads the time allowed per document from EXTRACT_TIMEOUT in seconds
+func timeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("EXTRACT_TIMEOUT"))
+	if err != nil || seconds <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Text returns the normalised text of a document, empty for formats without an extractor
+func Text(ctx context.Context, path string, mimeType string) (string, error) {
+	extractor, found := extractors[mimeType]
+	if !found && IsText(mimeType) {
+		extractor, found = plainText, true
+	}
+	if !found {
+		return "", nil
+	}
+
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout())
+	defer cancel()
+	text, err := extractor(ctx, file, info.Size())
+	return Normalise(text), err
+}
+
+// textBuilder collects text up to maxTextBytes
+type textBuilder struct {
+	strings.Builder
+}
+
+// full checks if the builder holds as much text as is kept
+func (t *textBuilder) full() bool {
+	return t.Len() >= maxTextBytes
+}
+
+// add appends text followed by a space unless the builder is full
+func (t *textBuilder) add(text string) {
+	if t.full() {
+		return
+	}
+	t.WriteString(text)
+	t.WriteByte(' ')
+}
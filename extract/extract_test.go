@@ -0,0 +1,256 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipDocument builds a zip based document of name, content pairs
+func zipDocument(t *testing.T, files ...string) []byte {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for i := 0; i < len(files); i += 2 {
+		entry, err := writer.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(files[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// writeDocument stores content in a temp file and returns its path
+func writeDocument(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "document")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		content  func(t *testing.T) []byte
+		want     string
+	}{
+		{
+			name:     "docx",
+			mimeType: mimeDOCX,
+			content: func(t *testing.T) []byte {
+				return zipDocument(t,
+					"word/document.xml", `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:t xml:space="preserve"> world</w:t></w:r></w:p><w:p><w:r><w:t>Second</w:t></w:r><w:tab/><w:r><w:t>para</w:t></w:r></w:p><w:p><w:r><w:instrText>HIDDEN</w:instrText></w:r></w:p></w:body></w:document>`,
+					"word/header1.xml", `<w:hdr xmlns:w="w"><w:p><w:r><w:t>Header</w:t></w:r></w:p></w:hdr>`,
+					"word/styles.xml", `<w:styles xmlns:w="w"><w:t>Styles</w:t></w:styles>`)
+			},
+			want: "Hello world Second para Header",
+		},
+		{
+			name:     "xlsx",
+			mimeType: mimeXLSX,
+			content: func(t *testing.T) []byte {
+				return zipDocument(t,
+					"xl/sharedStrings.xml", `<sst><si><t>Name</t></si><si><r><t>Tot</t></r><r><t>al</t></r></si></sst>`,
+					"xl/worksheets/sheet1.xml", `<worksheet><sheetData><row><c t="s"><v>0</v></c><c t="s"><v>1</v></c></row><row><c><v>42</v></c><c t="s"><v>7</v></c><c t="inlineStr"><is><t>inline</t></is></c></row></sheetData></worksheet>`)
+			},
+			want: "Name Total 42 inline",
+		},
+		{
+			name:     "pptx in slide order",
+			mimeType: mimePPTX,
+			content: func(t *testing.T) []byte {
+				slide := func(text string) string {
+					return `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p></p:sld>`
+				}
+				return zipDocument(t,
+					"ppt/slides/slide10.xml", slide("ten"),
+					"ppt/slides/slide2.xml", slide("two"),
+					"ppt/slides/slide1.xml", slide("one"),
+					"ppt/notesSlides/notesSlide1.xml", slide("notes"))
+			},
+			want: "one two ten notes",
+		},
+		{
+			name:     "odt",
+			mimeType: mimeODT,
+			content: func(t *testing.T) []byte {
+				return zipDocument(t,
+					"mimetype", mimeODT,
+					"content.xml", `<office:document-content xmlns:office="o" xmlns:text="t"><office:body><text:h>Title</text:h><text:p>First<text:s/>line<text:line-break/>next</text:p></office:body></office:document-content>`,
+					"meta.xml", `<office:document-meta xmlns:office="o"><text:p>Meta</text:p></office:document-meta>`)
+			},
+			want: "Title First line next",
+		},
+		{
+			name:     "csv with ragged rows",
+			mimeType: "text/csv",
+			content: func(t *testing.T) []byte {
+				return []byte("name,total\nalpha,1,extra\nbe\"ta,2\n")
+			},
+			want: `name total alpha 1 extra be"ta 2`,
+		},
+		{
+			name:     "html without scripts and styles",
+			mimeType: "text/html",
+			content: func(t *testing.T) []byte {
+				return []byte("<html><head><style>p{}</style><script>alert(1)</script></head><body><p>Caf&eacute; &amp; bar</p></body></html>")
+			},
+			want: "Café & bar",
+		},
+		{
+			name:     "xml",
+			mimeType: "application/xml",
+			content: func(t *testing.T) []byte {
+				return []byte(`<?xml version="1.0"?><invoice><to>ACME</to><total>12</total></invoice>`)
+			},
+			want: "ACME 12",
+		},
+		{
+			name:     "plain text",
+			mimeType: "text/plain",
+			content: func(t *testing.T) []byte {
+				return []byte("  one\x00two\n\n\tthree \xff")
+			},
+			want: "one two three",
+		},
+		{
+			name:     "no extractor",
+			mimeType: "image/png",
+			content: func(t *testing.T) []byte {
+				return []byte("\x89PNG")
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Text(context.Background(), writeDocument(t, tt.content(t)), tt.mimeType)
+			if err != nil {
+				t.Fatalf("Text() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Text() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		content  func(t *testing.T) []byte
+		ctx      func() context.Context
+		err      error
+	}{
+		{
+			name:     "too many entries",
+			mimeType: mimeDOCX,
+			content: func(t *testing.T) []byte {
+				files := make([]string, 0, 2*(maxEntries+1))
+				for i := 0; i <= maxEntries; i++ {
+					files = append(files, fmt.Sprintf("word/part%d.xml", i), "")
+				}
+				return zipDocument(t, files...)
+			},
+			err: errTooLarge,
+		},
+		{
+			name:     "entry over the size limit",
+			mimeType: mimeODT,
+			content: func(t *testing.T) []byte {
+				body := "<office:document-content><text:p>" + strings.Repeat(" ", maxEntryBytes) + "</text:p></office:document-content>"
+				return zipDocument(t, "content.xml", body)
+			},
+			err: errTooLarge,
+		},
+		{
+			name:     "cancelled",
+			mimeType: mimeDOCX,
+			content: func(t *testing.T) []byte {
+				return zipDocument(t, "word/document.xml", `<w:document><w:t>text</w:t></w:document>`)
+			},
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			err: context.Canceled,
+		},
+		{
+			name:     "not a zip",
+			mimeType: mimeXLSX,
+			content: func(t *testing.T) []byte {
+				return []byte("not a workbook")
+			},
+			err: zip.ErrFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+			_, err := Text(ctx, writeDocument(t, tt.content(t)), tt.mimeType)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Text() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTextKeepsToMaxTextBytes(t *testing.T) {
+	row := strings.Repeat("word ", 1000)
+	content := zipDocument(t, "content.xml", "<office:document-content>"+strings.Repeat("<text:p>"+row+"</text:p>", 2*maxTextBytes/len(row))+"</office:document-content>")
+	got, err := Text(context.Background(), writeDocument(t, content), mimeODT)
+	if err != nil {
+		t.Fatalf("Text() error = %v", err)
+	}
+	if len(got) > maxTextBytes {
+		t.Fatalf("Text() = %d bytes, want at most %d", len(got), maxTextBytes)
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	docx := zipDocument(t, "word/document.xml", "<w:document/>")
+	tests := []struct {
+		name     string
+		filename string
+		declared string
+		head     []byte
+		want     string
+	}{
+		{"docx by extension", "report.docx", "", docx, mimeDOCX},
+		{"zip named as text", "report.txt", "", docx, "application/zip"},
+		{"csv", "table.CSV", "", []byte("a,b\n1,2\n"), "text/csv"},
+		{"text named as image", "photo.jpg", "", []byte("a,b\n1,2\n"), "text/plain"},
+		{"pdf named as docx", "report.docx", mimeDOCX, []byte("%PDF-1.7\n"), "application/pdf"},
+		{"unknown binary with extension", "sheet.ods", "", []byte{0, 1, 2, 3}, mimeODS},
+		{"tar by extension", "data.tar", "", []byte{0, 1, 2, 3}, "application/x-tar"},
+		{"unknown binary declared", "blob", "application/x-custom; charset=binary", []byte{0, 1, 2, 3}, "application/x-custom"},
+		{"unknown binary", "blob", "", []byte{0, 1, 2, 3}, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectMimeType(tt.filename, tt.declared, tt.head); got != tt.want {
+				t.Fatalf("DetectMimeType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package extract
+
+import (
+	"context"
+	"io"
+)
+
+// OpenDocument mime types
+const (
+	mimeODT = "application/vnd.oasis.opendocument.text"
+	mimeODS = "application/vnd.oasis.opendocument.spreadsheet"
+	mimeODP = "application/vnd.oasis.opendocument.presentation"
+)
+
+func init() {
+	Register(mimeODT, odfText)
+	Register(mimeODS, odfText)
+	Register(mimeODP, odfText)
+}
+
+// odfText extracts the paragraphs and headings of the body of an OpenDocument file
+func odfText(ctx context.Context, r io.ReaderAt, size int64) (string, error) {
+	doc, err := openArchive(r, size)
+	if err != nil {
+		return "", err
+	}
+	text := &textBuilder{}
+	textTags := map[string]bool{"p": true, "h": true}
+	breakTags := map[string]bool{"p": true, "h": true, "s": true, "tab": true, "line-break": true, "table-cell": true}
+	for _, file := range doc.files("content.xml") {
+		if err := doc.elementText(ctx, text, file, textTags, breakTags); err != nil {
+			return text.String(), err
+		}
+	}
+	return text.String(), nil
+}
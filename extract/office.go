@@ -0,0 +1,137 @@
+package extract
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// Office Open XML mime types
+const (
+	mimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+)
+
+// maxSharedStrings bounds the shared strings kept from a workbook
+const maxSharedStrings = 1 << 20
+
+func init() {
+	Register(mimeDOCX, docxText)
+	Register(mimeXLSX, xlsxText)
+	Register(mimePPTX, pptxText)
+}
+
+// docxText extracts the runs of the body, headers, footers and notes of a document
+func docxText(ctx context.Context, r io.ReaderAt, size int64) (string, error) {
+	doc, err := openArchive(r, size)
+	if err != nil {
+		return "", err
+	}
+	text := &textBuilder{}
+	textTags := map[string]bool{"t": true}
+	breakTags := map[string]bool{"p": true, "tab": true, "br": true}
+	for _, pattern := range []string{"word/document.xml", "word/header*.xml", "word/footer*.xml", "word/footnotes.xml", "word/endnotes.xml"} {
+		for _, file := range doc.files(pattern) {
+			if err := doc.elementText(ctx, text, file, textTags, breakTags); err != nil {
+				return text.String(), err
+			}
+		}
+	}
+	return text.String(), nil
+}
+
+// pptxText extracts the text of the slides and their notes
+func pptxText(ctx context.Context, r io.ReaderAt, size int64) (string, error) {
+	doc, err := openArchive(r, size)
+	if err != nil {
+		return "", err
+	}
+	text := &textBuilder{}
+	textTags := map[string]bool{"t": true}
+	breakTags := map[string]bool{"p": true, "br": true}
+	for _, pattern := range []string{"ppt/slides/slide*.xml", "ppt/notesSlides/notesSlide*.xml"} {
+		for _, file := range doc.files(pattern) {
+			if err := doc.elementText(ctx, text, file, textTags, breakTags); err != nil {
+				return text.String(), err
+			}
+		}
+	}
+	return text.String(), nil
+}
+
+// xlsxText extracts the cell values of every sheet, resolving shared strings
+func xlsxText(ctx context.Context, r io.ReaderAt, size int64) (string, error) {
+	doc, err := openArchive(r, size)
+	if err != nil {
+		return "", err
+	}
+
+	shared := []string{}
+	for _, file := range doc.files("xl/sharedStrings.xml") {
+		item := &textBuilder{}
+		inText := false
+		err := doc.xmlWalk(ctx, file, func(token xml.Token) bool {
+			switch element := token.(type) {
+			case xml.StartElement:
+				inText = inText || element.Name.Local == "t"
+			case xml.EndElement:
+				switch element.Name.Local {
+				case "t":
+					inText = false
+				case "si":
+					shared = append(shared, item.String())
+					item = &textBuilder{}
+				}
+			case xml.CharData:
+				if inText && !item.full() {
+					item.Write(element)
+				}
+			}
+			return len(shared) < maxSharedStrings
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	text := &textBuilder{}
+	for _, file := range doc.files("xl/worksheets/sheet*.xml") {
+		cellType, value := "", &textBuilder{}
+		err := doc.xmlWalk(ctx, file, func(token xml.Token) bool {
+			switch element := token.(type) {
+			case xml.StartElement:
+				if element.Name.Local == "c" {
+					cellType = ""
+					value.Reset()
+					for _, attr := range element.Attr {
+						if attr.Name.Local == "t" {
+							cellType = attr.Value
+						}
+					}
+				}
+			case xml.EndElement:
+				if element.Name.Local != "c" {
+					break
+				}
+				if cellType == "s" {
+					if index, err := strconv.Atoi(value.String()); err == nil && index >= 0 && index < len(shared) {
+						text.add(shared[index])
+					}
+				} else if value.Len() > 0 {
+					text.add(value.String())
+				}
+			case xml.CharData:
+				if !value.full() {
+					value.Write(element)
+				}
+			}
+			return !text.full()
+		})
+		if err != nil {
+			return text.String(), err
+		}
+	}
+	return text.String(), nil
+}
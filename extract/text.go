@@ -0,0 +1,153 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTextBytes bounds the text kept for a document
+const maxTextBytes = 1 << 20
+
+// tagPattern matches markup tags of html documents
+var tagPattern = regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>|<[^>]*>`)
+
+// spacePattern matches runs of whitespace
+var spacePattern = regexp.MustCompile(`\s+`)
+
+// extensionTypes are registered so detection does not depend on the mime tables of the host
+var extensionTypes = map[string]string{
+	".txt":  "text/plain",
+	".csv":  "text/csv",
+	".md":   "text/markdown",
+	".docx": mimeDOCX,
+	".xlsx": mimeXLSX,
+	".pptx": mimePPTX,
+	".odt":  mimeODT,
+	".ods":  mimeODS,
+	".odp":  mimeODP,
+	".tar":  "application/x-tar",
+}
+
+func init() {
+	for extension, mimeType := range extensionTypes {
+		_ = mime.AddExtensionType(extension, mimeType)
+	}
+	Register("text/html", htmlText)
+	Register("application/xhtml+xml", htmlText)
+	Register("text/xml", xmlDocumentText)
+	Register("application/xml", xmlDocumentText)
+}
+
+// DetectMimeType works out the type of an upload from its first bytes. When
+// sniffing is generic the extension refines it if it agrees with the content,
+// and unknown binary content falls back to the declared type.
+func DetectMimeType(filename string, declared string, head []byte) string {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	byExtension, _, _ := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))))
+	declared, _, _ = mime.ParseMediaType(declared)
+
+	switch sniffed {
+	case "text/plain":
+		if IsText(byExtension) {
+			return byExtension
+		}
+	case "application/zip":
+		if strings.HasPrefix(byExtension, "application/vnd.") || strings.HasSuffix(byExtension, "+zip") {
+			return byExtension
+		}
+	case "application/octet-stream":
+		if byExtension != "" {
+			return byExtension
+		}
+		if declared != "" {
+			return declared
+		}
+	}
+	return sniffed
+}
+
+// IsText checks if the mime type carries plain or marked up text
+func IsText(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || isTextApplication(mimeType)
+}
+
+// isTextApplication checks for text formats registered under application/
+func isTextApplication(mimeType string) bool {
+	switch mimeType {
+	case "application/json", "application/xml", "application/xhtml+xml", "application/csv":
+		return true
+	}
+	return false
+}
+
+// readAll reads a document up to maxTextBytes
+func readAll(ctx context.Context, r io.ReaderAt, size int64) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(io.NewSectionReader(r, 0, size), maxTextBytes))
+}
+
+// plainText extracts text formats without markup
+func plainText(ctx context.Context, r io.ReaderAt, size int64) (string, error) {
+	data, err := readAll(ctx, r, size)
+	return string(data), err
+}
+
+// htmlText extracts html documents without their tags, scripts and styles
+func htmlText(ctx context.Context, r io.ReaderAt, size int64) (string, error) {
+	data, err := readAll(ctx, r, size)
+	if err != nil {
+		return "", err
+	}
+	return html.UnescapeString(tagPattern.ReplaceAllString(string(data), " ")), nil
+}
+
+// xmlDocumentText extracts the character data of xml documents
+func xmlDocumentText(ctx context.Context, r io.ReaderAt, size int64) (string, error) {
+	data, err := readAll(ctx, r, size)
+	if err != nil {
+		return "", err
+	}
+	return xmlText(data), nil
+}
+
+// xmlText collects the character data of an xml document
+func xmlText(data []byte) string {
+	var text strings.Builder
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if chars, ok := token.(xml.CharData); ok {
+			text.Write(chars)
+			text.WriteByte(' ')
+		}
+	}
+	return text.String()
+}
+
+// Normalise makes text valid UTF-8 without NUL bytes and with single spaces
+func Normalise(text string) string {
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, " ")
+	}
+	text = strings.ReplaceAll(text, "\x00", " ")
+	text = strings.TrimSpace(spacePattern.ReplaceAllString(text, " "))
+	if len(text) > maxTextBytes {
+		text = strings.ToValidUTF8(text[:maxTextBytes], "")
+	}
+	return text
+}
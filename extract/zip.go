@@ -0,0 +1,149 @@
+package extract
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"io"
+	"path"
+	"sort"
+)
+
+// Limits of zip based documents
+const (
+	// maxEntries bounds the entries of an archive
+	maxEntries = 10000
+	// maxEntryBytes bounds the inflated size of one entry
+	maxEntryBytes = 64 << 20
+	// maxInflatedBytes bounds the inflated size read from one archive
+	maxInflatedBytes = 256 << 20
+)
+
+// archive struct reads the XML entries of a zip based document within the limits
+type archive struct {
+	reader *zip.Reader
+	budget int64
+}
+
+// openArchive opens a zip based document, refusing archives with too many entries
+func openArchive(r io.ReaderAt, size int64) (*archive, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(reader.File) > maxEntries {
+		return nil, errTooLarge
+	}
+	return &archive{reader: reader, budget: maxInflatedBytes}, nil
+}
+
+// files returns the entries matching the pattern in natural order, so slide10 follows slide9
+func (a *archive) files(pattern string) []*zip.File {
+	files := []*zip.File{}
+	for _, file := range a.reader.File {
+		if matched, _ := path.Match(pattern, file.Name); matched {
+			files = append(files, file)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if len(files[i].Name) != len(files[j].Name) {
+			return len(files[i].Name) < len(files[j].Name)
+		}
+		return files[i].Name < files[j].Name
+	})
+	return files
+}
+
+// open returns a reader of the entry that fails once the entry or the
+// archive budget is used up, whatever the sizes in the headers claim
+func (a *archive) open(file *zip.File) (io.ReadCloser, error) {
+	if file.UncompressedSize64 > maxEntryBytes || int64(file.UncompressedSize64) > a.budget {
+		return nil, errTooLarge
+	}
+	content, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	limit := int64(maxEntryBytes)
+	if a.budget < limit {
+		limit = a.budget
+	}
+	return &limitedEntry{ReadCloser: content, archive: a, left: limit}, nil
+}
+
+// limitedEntry counts what is inflated against the archive budget
+type limitedEntry struct {
+	io.ReadCloser
+	archive *archive
+	left    int64
+}
+
+// Read method
+func (l *limitedEntry) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		return 0, errTooLarge
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.left -= int64(n)
+	l.archive.budget -= int64(n)
+	return n, err
+}
+
+// xmlWalk calls fn with every token of an XML entry until fn returns false,
+// checking ctx as it goes
+func (a *archive) xmlWalk(ctx context.Context, file *zip.File, fn func(token xml.Token) bool) error {
+	content, err := a.open(file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	decoder := xml.NewDecoder(content)
+	decoder.Strict = false
+	for count := 0; ; count++ {
+		if count%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !fn(token) {
+			return nil
+		}
+	}
+}
+
+// elementText collects the character data inside elements named by textTags
+// and separates the elements named by breakTags
+func (a *archive) elementText(ctx context.Context, text *textBuilder, file *zip.File, textTags map[string]bool, breakTags map[string]bool) error {
+	depth := 0
+	return a.xmlWalk(ctx, file, func(token xml.Token) bool {
+		switch element := token.(type) {
+		case xml.StartElement:
+			if textTags[element.Name.Local] {
+				depth++
+			}
+			if breakTags[element.Name.Local] {
+				text.add("")
+			}
+		case xml.EndElement:
+			if textTags[element.Name.Local] && depth > 0 {
+				depth--
+			}
+		case xml.CharData:
+			if depth > 0 {
+				text.WriteString(string(element))
+			}
+		}
+		return !text.full()
+	})
+}
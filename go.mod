@@ -1,25 +1,25 @@
 module github.com/greatfocus/gf-document
 
-go 1.19
+go 1.20
 
 require (
 	github.com/go-co-op/gocron v1.27.1
+	github.com/golang-jwt/jwt/v5 v5.0.0
 	github.com/google/uuid v1.3.0
 	github.com/greatfocus/gf-sframe v0.1.3
 	github.com/lib/pq v1.10.9
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/rabbitmq/amqp091-go v1.8.0
 	github.com/sirupsen/logrus v1.9.2
+	golang.org/x/image v0.18.0
 )
 
 require (
-	github.com/golang-jwt/jwt/v5 v5.0.0 // indirect
 	github.com/joho/godotenv v1.3.0 // indirect
 	github.com/robfig/cron/v3 v3.0.1 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
-
 )
 
 // replace github.com/greatfocus/gf-sframe => /home/muthurimi/go/src/github.com/greatfocus/gf-sframe
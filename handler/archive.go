@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/services"
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+// Archive struct streams many files as one ZIP
+type Archive struct {
+	fileService *services.FileService
+	server      *server.Server
+}
+
+// ServeHTTP checks if is valid method
+func (a Archive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		a.download(w, r)
+		return
+	}
+
+	// catch all
+	// if no method is satisfied return an error
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	w.Header().Add("Allow", "POST")
+}
+
+// Init method
+func (a *Archive) Init(s *server.Server, fileService *services.FileService) {
+	a.fileService = fileService
+	a.server = s
+}
+
+// download resolves and checks every file before the response starts, then streams the ZIP
+func (a *Archive) download(w http.ResponseWriter, r *http.Request) {
+	data, err := a.server.Request(w, r)
+	if err != nil {
+		return
+	}
+	request := models.ArchiveRequest{}
+	payload, _ := json.Marshal(data)
+	if err := json.Unmarshal(payload, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.server.Error(w, r, errors.New("invalid payload request"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(a.server.Timeout)*time.Second)
+	files, err := a.fileService.ArchiveFiles(ctx, a.server.JWT.Secret(), requestActor(r), request)
+	cancel()
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		a.server.Error(w, r, err)
+		return
+	}
+
+	name := "documents-" + time.Now().UTC().Format("20060102T150405Z") + ".zip"
+	if request.RefID != "" {
+		name = fmt.Sprintf("documents-%s.zip", request.RefID)
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	// the status is sent, a failure can only cut the stream short
+	out := streamWriter(w, time.Duration(a.server.Timeout)*time.Second)
+	if err := a.fileService.WriteArchive(r.Context(), out, files, request.RefID); err != nil {
+		logging.From(r.Context()).WithError(err).Error("archive stream failed")
+	}
+}
+
+// deadlineWriter struct moves the write deadline of the response forward on
+// every write, a stream goes on as long as the client keeps reading
+type deadlineWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+// streamWriter returns w with the write timeout of the server applied to
+// each write rather than to the whole response, or w when the deadline
+// cannot be moved
+func streamWriter(w http.ResponseWriter, timeout time.Duration) io.Writer {
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return w
+	}
+	return &deadlineWriter{w: w, controller: controller, timeout: timeout}
+}
+
+// Write extends the deadline then writes
+func (d *deadlineWriter) Write(data []byte) (int, error) {
+	if err := d.controller.SetWriteDeadline(time.Now().Add(d.timeout)); err != nil {
+		return 0, err
+	}
+	return d.w.Write(data)
+}
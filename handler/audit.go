@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/services"
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+// Audit struct serves the audit log to admins
+type Audit struct {
+	fileService *services.FileService
+	server      *server.Server
+}
+
+// ServeHTTP checks if is valid method
+func (a Audit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		if strings.HasSuffix(r.URL.Path, "/verify") {
+			a.verify(w, r)
+			return
+		}
+		a.getAudit(w, r)
+		return
+	}
+
+	// catch all
+	// if no method is satisfied return an error
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	w.Header().Add("Allow", "GET")
+}
+
+// Init method
+func (a *Audit) Init(s *server.Server, fileService *services.FileService) {
+	a.fileService = fileService
+	a.server = s
+}
+
+// getAudit method returns the audit events matching the filters, newest first
+func (a *Audit) getAudit(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(a.server.Timeout)*time.Second)
+	defer cancel()
+
+	query, err := auditQuery(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.server.Error(w, r, err)
+		return
+	}
+
+	page, err := a.fileService.GetAudit(ctx, requestActor(r), query)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		a.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	a.server.Success(w, r, page)
+}
+
+// verify method checks the audit chain of the tenant for gaps and tampering
+func (a *Audit) verify(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(a.server.Timeout)*time.Second)
+	defer cancel()
+
+	results, err := a.fileService.VerifyAudit(ctx, requestActor(r))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		a.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	a.server.Success(w, r, results)
+}
+
+// auditQuery reads the audit filters of the request
+func auditQuery(r *http.Request) (models.AuditQuery, error) {
+	query := models.AuditQuery{
+		FileID: r.FormValue("fileId"),
+		Action: r.FormValue("action"),
+	}
+
+	var err error
+	if value := r.FormValue("actorId"); value != "" {
+		if query.ActorID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return query, errors.New("invalid actorId")
+		}
+	}
+	if query.From, err = formTime(r, "from"); err != nil {
+		return query, errors.New("invalid from")
+	}
+	if query.To, err = formTime(r, "to"); err != nil {
+		return query, errors.New("invalid to")
+	}
+	if query.Limit, err = formInt(r, "limit"); err != nil {
+		return query, errors.New("invalid limit")
+	}
+	if query.Offset, err = formInt(r, "offset"); err != nil {
+		return query, errors.New("invalid offset")
+	}
+	return query, nil
+}
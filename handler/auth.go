@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	jwt5 "github.com/golang-jwt/jwt/v5"
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/storage"
+	"github.com/greatfocus/gf-sframe/server"
+	"github.com/sirupsen/logrus"
+)
+
+// actorKey is the context key of the authenticated actor
+type actorKey struct{}
+
+// defaultTenant owns the rows created before tenants existed
+const defaultTenant = "default"
+
+// Authenticate validates the bearer token and attaches the actor to the request.
+// gf-sframe's GetTokenInfo asserts the decoded claim straight to TokenInfo,
+// which never holds for JSON claims, so the data claim is decoded here.
+func Authenticate(jwt server.JWT) server.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor, err := tokenActor(r, jwt.Secret())
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			// continue
+			logging.AddFields(r.Context(), logrus.Fields{"actor_id": actor.ID, "tenant_id": actor.TenantID})
+			ctx := context.WithValue(r.Context(), actorKey{}, actor)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requestActor returns the actor attached by Authenticate
+func requestActor(r *http.Request) models.Actor {
+	actor, _ := r.Context().Value(actorKey{}).(models.Actor)
+	return actor
+}
+
+// tokenActor reads the actor from the data claim of the bearer token
+func tokenActor(r *http.Request, secret string) (models.Actor, error) {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 {
+		return models.Actor{}, errors.New("missing token")
+	}
+
+	claims := jwt5.MapClaims{}
+	_, err := jwt5.ParseWithClaims(parts[1], claims, func(token *jwt5.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt5.WithValidMethods([]string{jwt5.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return models.Actor{}, err
+	}
+
+	data, err := json.Marshal(claims["data"])
+	if err != nil {
+		return models.Actor{}, err
+	}
+	info := tokenData{}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return models.Actor{}, err
+	}
+	if info.ActorID == 0 {
+		return models.Actor{}, errors.New("missing actor")
+	}
+	tenantID := tokenTenant(info, claims)
+	if !storage.ValidTenant(tenantID) {
+		return models.Actor{}, errors.New("invalid tenant")
+	}
+
+	return models.Actor{
+		ID:          info.ActorID,
+		Origin:      info.Origin,
+		TenantID:    tenantID,
+		Permissions: info.Permissions,
+	}, nil
+}
+
+// tokenData is the data claim, TokenInfo with the tenant of the actor
+type tokenData struct {
+	server.TokenInfo
+	TenantID string
+}
+
+// tokenTenant reads the tenant from the data claim or a top level tenant claim.
+// Tokens issued before tenants existed belong to the default tenant.
+func tokenTenant(info tokenData, claims jwt5.MapClaims) string {
+	if info.TenantID != "" {
+		return info.TenantID
+	}
+	if tenantID, ok := claims["tenant"].(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return defaultTenant
+}
@@ -3,10 +3,11 @@ package handler
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
 	"github.com/greatfocus/gf-document/services"
 	server "github.com/greatfocus/gf-sframe/server"
 )
@@ -28,11 +29,15 @@ func (f File) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		f.upload(w, r)
 		return
 	}
+	if r.Method == http.MethodDelete {
+		f.delete(w, r)
+		return
+	}
 
 	// catch all
 	// if no method is satisfied return an error
 	w.WriteHeader(http.StatusMethodNotAllowed)
-	w.Header().Add("Allow", "GET, POST")
+	w.Header().Add("Allow", "GET, POST, DELETE")
 }
 
 // ValidateRequest checks if request is valid
@@ -51,15 +56,23 @@ func (f *File) Init(s *server.Server, fileService *services.FileService) {
 	f.server = s
 }
 
-// uploadFile upload file
+// upload stores the file and answers once it is recorded. Hashing, text
+// extraction, renditions and explode run later as jobs, but metadata stripping
+// and the PDF and archive inspections stay in the request: their policies
+// reject a file before it is stored, which a job could only report afterwards.
 func (f *File) upload(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
 	defer cancel()
 
-	doc, err := f.fileService.Upload(ctx, f.server.JWT.Secret(), r)
+	doc, err := f.fileService.Upload(ctx, f.server.JWT.Secret(), requestActor(r), r)
+	if services.IsQuotaExceeded(err) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		f.server.Error(w, r, err)
+		return
+	}
 	if err != nil {
 		derr := errors.New("invalid payload request")
-		f.server.Logger.Error(fmt.Sprintf("Error: %v\n", derr))
+		logging.From(ctx).WithError(err).Error("upload failed")
 		f.server.Error(w, r, derr)
 		return
 	}
@@ -74,11 +87,12 @@ func (f *File) getFiles(w http.ResponseWriter, r *http.Request) {
 
 	lastID := r.FormValue("lastId")
 	id := r.FormValue("id")
+	actor := requestActor(r)
 
 	if id != "" {
-		file, err := f.fileService.GetFileByID(ctx, f.server.JWT.Secret(), id)
+		file, err := f.fileService.GetFileByID(ctx, f.server.JWT.Secret(), actor, id)
 		if err != nil {
-			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.WriteHeader(errorStatus(err))
 			f.server.Error(w, r, err)
 			return
 		}
@@ -87,7 +101,21 @@ func (f *File) getFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files, err := f.fileService.GetFiles(ctx, f.server.JWT.Secret(), lastID)
+	name := r.FormValue("name")
+	namePrefix := r.FormValue("namePrefix")
+	if name != "" || namePrefix != "" {
+		files, err := f.fileService.GetFilesByName(ctx, f.server.JWT.Secret(), actor, name, namePrefix)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			f.server.Error(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		f.server.Success(w, r, files)
+		return
+	}
+
+	files, err := f.fileService.GetFiles(ctx, f.server.JWT.Secret(), actor, lastID)
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		f.server.Error(w, r, err)
@@ -96,3 +124,39 @@ func (f *File) getFiles(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	f.server.Success(w, r, files)
 }
+
+// delete method
+func (f *File) delete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	id := r.FormValue("id")
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		f.server.Error(w, r, errors.New("required ID"))
+		return
+	}
+
+	_, err := f.fileService.Delete(ctx, f.server.JWT.Secret(), requestActor(r), id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	f.server.Success(w, r, models.File{ID: id})
+}
+
+// errorStatus maps service errors to a response status
+func errorStatus(err error) int {
+	if services.IsForbidden(err) {
+		return http.StatusForbidden
+	}
+	if services.IsQuotaExceeded(err) {
+		return http.StatusRequestEntityTooLarge
+	}
+	if services.IsNotFound(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusUnprocessableEntity
+}
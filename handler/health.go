@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greatfocus/gf-document/database"
+	"github.com/greatfocus/gf-document/health"
+	"github.com/greatfocus/gf-document/lifecycle"
+	"github.com/greatfocus/gf-document/services"
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+// Health probe settings
+const (
+	defaultHealthCache   = 5 * time.Second
+	defaultHealthTimeout = 3 * time.Second
+	defaultMinFreeMB     = 512
+)
+
+// Health struct serves the liveness and readiness probes
+type Health struct {
+	server *server.Server
+	live   *health.Checker
+	ready  *health.Checker
+	gate   *lifecycle.Gate
+}
+
+// ServeHTTP checks if is valid method
+func (h Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		if strings.HasSuffix(r.URL.Path, "/live") {
+			h.write(w, h.live.Report())
+			return
+		}
+		if h.gate.Draining() {
+			h.write(w, draining())
+			return
+		}
+		h.write(w, h.ready.Report())
+		return
+	}
+
+	// catch all
+	// if no method is satisfied return an error
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	w.Header().Add("Allow", "GET, HEAD")
+}
+
+// Init method, the results of the checks are cached for HEALTH_CACHE_SECONDS
+// and each check is cut off after HEALTH_TIMEOUT_SECONDS. Readiness needs
+// HEALTH_MIN_FREE_MB free on the upload volume and the schema at the version
+// of the newest migration built into the binary. Readiness fails as soon as the gate
+// of the requests drains for a shutdown.
+func (h *Health) Init(s *server.Server, conn *sql.DB, fileService *services.FileService, gate *lifecycle.Gate) {
+	h.server = s
+	h.gate = gate
+	ttl := envSeconds("HEALTH_CACHE_SECONDS", defaultHealthCache)
+	timeout := envSeconds("HEALTH_TIMEOUT_SECONDS", defaultHealthTimeout)
+	minFree, err := strconv.ParseUint(os.Getenv("HEALTH_MIN_FREE_MB"), 10, 64)
+	if err != nil {
+		minFree = defaultMinFreeMB
+	}
+
+	// the process answering is all liveness asks, a lost dependency must not restart it
+	h.live = health.NewChecker(ttl, timeout)
+	h.ready = health.NewChecker(ttl, timeout,
+		health.Database(conn),
+		health.Storage(fileService.StorageRoot(), minFree<<20),
+		health.Broker(os.Getenv("RABBITMQ_URL"), timeout),
+		health.Migrations(conn, database.Latest()))
+}
+
+// write writes the report, 503 when a critical component is down
+func (h *Health) write(w http.ResponseWriter, report health.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(report.HTTPStatus())
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// draining is the readiness report of a service shutting down
+func draining() health.Report {
+	return health.Report{
+		Status: health.StatusDown,
+		Components: []health.Component{
+			{Name: "lifecycle", Status: health.StatusDown, Critical: true, Detail: "shutting down"},
+		},
+		CheckedOn: time.Now().UTC(),
+	}
+}
+
+// envSeconds reads a positive number of seconds from the environment
+func envSeconds(name string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/rendition"
+	"github.com/greatfocus/gf-document/services"
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+// IIIF struct serves the IIIF Image API under /{uri}/iiif/{id}/
+type IIIF struct {
+	fileService *services.FileService
+	server      *server.Server
+}
+
+// Init method
+func (i *IIIF) Init(s *server.Server, fileService *services.FileService) {
+	i.fileService = fileService
+	i.server = s
+}
+
+// ServeHTTP routes to info.json or an image request
+func (i IIIF) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Header().Add("Allow", "GET")
+		return
+	}
+
+	id, request := i.imagePath(r)
+	switch {
+	case id == "":
+		w.WriteHeader(http.StatusNotFound)
+	case request == "":
+		// the base URI of an image redirects to its description
+		http.Redirect(w, r, i.serviceID(r, id)+"/info.json", http.StatusSeeOther)
+	case request == "info.json":
+		i.info(w, r, id)
+	default:
+		i.image(w, r, id, request)
+	}
+}
+
+// imagePath splits /{uri}/iiif/{id}/{request} into its parts
+func (i *IIIF) imagePath(r *http.Request) (string, string) {
+	prefix := "/" + i.server.URI + "/iiif/"
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, prefix), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// serviceID returns the base URI of the image, IIIF_BASE_URL when the
+// service runs behind a proxy that rewrites hosts
+func (i *IIIF) serviceID(r *http.Request, id string) string {
+	base := strings.TrimSuffix(os.Getenv("IIIF_BASE_URL"), "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host + "/" + i.server.URI + "/iiif"
+	}
+	return base + "/" + id
+}
+
+// iiifStatus maps request errors to 400 as the specification asks
+func iiifStatus(err error) int {
+	if errors.Is(err, rendition.ErrInvalid) {
+		return http.StatusBadRequest
+	}
+	return errorStatus(err)
+}
+
+// info writes the info.json of the image
+func (i *IIIF) info(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(i.server.Timeout)*time.Second)
+	defer cancel()
+
+	info, err := i.fileService.IIIFInfo(ctx, i.server.JWT.Secret(), requestActor(r), id, i.serviceID(r, id))
+	if err != nil {
+		w.WriteHeader(iiifStatus(err))
+		i.server.Error(w, r, err)
+		return
+	}
+
+	contentType := "application/json"
+	if strings.Contains(r.Header.Get("Accept"), "application/ld+json") {
+		contentType = fmt.Sprintf(`application/ld+json;profile="%s"`, models.IIIFContext)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// image streams a region of the image from the tile cache
+func (i *IIIF) image(w http.ResponseWriter, r *http.Request, id string, request string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(i.server.Timeout)*time.Second)
+	defer cancel()
+
+	rendered, path, err := i.fileService.IIIFImage(ctx, i.server.JWT.Secret(), requestActor(r), id, request)
+	if err != nil {
+		w.WriteHeader(iiifStatus(err))
+		i.server.Error(w, r, err)
+		return
+	}
+
+	content, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		i.server.Error(w, r, errors.New("file content does not exist"))
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", rendered.MimeType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, rendered.Name))
+	w.Header().Set("Link", fmt.Sprintf(`<%s>;rel="profile"`, "http://iiif.io/api/image/3/level2.json"))
+	http.ServeContent(w, r, "", rendered.CreatedOn, content)
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/metrics"
+	"github.com/greatfocus/gf-document/services"
+	"github.com/greatfocus/gf-document/tracing"
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+// Request metrics
+var (
+	requestsTotal = metrics.NewCounter("http_requests_total",
+		"HTTP requests per route, method and status.", "route", "method", "status")
+	requestDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"Latency of HTTP requests per route and method.", metrics.DurationBuckets, "route", "method")
+)
+
+// statusRecorder keeps the status and size of the response. ProcessTimeout may
+// write its status while the handler still runs so both are set atomically.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int32
+	bytes  int64
+}
+
+// WriteHeader records the first status written
+func (s *statusRecorder) WriteHeader(status int) {
+	atomic.CompareAndSwapInt32(&s.status, 0, int32(status))
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 of a body written without a status
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	atomic.CompareAndSwapInt32(&s.status, 0, http.StatusOK)
+	written, err := s.ResponseWriter.Write(data)
+	atomic.AddInt64(&s.bytes, int64(written))
+	return written, err
+}
+
+// Status returns the recorded status, the implicit 200 when nothing was written
+func (s *statusRecorder) Status() int {
+	status := atomic.LoadInt32(&s.status)
+	if status == 0 {
+		return http.StatusOK
+	}
+	return int(status)
+}
+
+// Unwrap returns the response written to, for http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Flush lets event streams flush through the recorder
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Instrument counts the requests of a route, measures their latency and traces
+// them as server spans continuing the W3C trace context of the caller. route is
+// the pattern the handler is registered on, never the request path, so ids do
+// not become label values.
+func Instrument(route string) server.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			ctx := tracing.Extract(r.Context(), tracing.HeaderCarrier(r.Header))
+			ctx, span := tracing.Start(ctx, r.Method+" "+route, tracing.KindServer,
+				tracing.String("http.method", r.Method), tracing.String("http.route", route))
+			defer span.End()
+
+			recorder := &statusRecorder{ResponseWriter: w}
+			h.ServeHTTP(recorder, r.WithContext(ctx))
+
+			status := recorder.Status()
+			span.SetAttributes(tracing.Int("http.status_code", int64(status)))
+			if status >= http.StatusInternalServerError {
+				span.RecordError(errors.New(http.StatusText(status)))
+			}
+			requestsTotal.Inc(route, r.Method, strconv.Itoa(status))
+			requestDuration.Observe(time.Since(started).Seconds(), route, r.Method)
+		})
+	}
+}
+
+// Metrics struct serves the metrics in the Prometheus text format
+type Metrics struct {
+	fileService *services.FileService
+	server      *server.Server
+	token       string
+}
+
+// ServeHTTP checks if is valid method
+func (m Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		m.getMetrics(w, r)
+		return
+	}
+
+	// catch all
+	// if no method is satisfied return an error
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	w.Header().Add("Allow", "GET")
+}
+
+// Init method, scrapes must send METRICS_TOKEN as a bearer token when it is set
+func (m *Metrics) Init(s *server.Server, fileService *services.FileService) {
+	m.fileService = fileService
+	m.server = s
+	m.token = os.Getenv("METRICS_TOKEN")
+}
+
+// getMetrics refreshes the gauges read from the database and writes every metric
+func (m *Metrics) getMetrics(w http.ResponseWriter, r *http.Request) {
+	if m.token != "" {
+		expected := []byte("Bearer " + m.token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(m.server.Timeout)*time.Second)
+	defer cancel()
+
+	// the counters are still worth reporting when the database cannot be read
+	if err := m.fileService.CollectMetrics(ctx); err != nil {
+		logging.From(ctx).WithError(err).Warn("metrics collection failed")
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := metrics.Write(w); err != nil {
+		logging.From(ctx).WithError(err).Warn("metrics write failed")
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+func TestMetricsToken(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", "secret")
+	m := Metrics{}
+	m.Init(&server.Server{}, nil)
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"missing", ""},
+		{"wrong token", "Bearer other"},
+		{"token without scheme", "secret"},
+		{"token prefix", "Bearer secre"},
+		{"basic scheme", "Basic secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/document/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			m.ServeHTTP(recorder, req)
+			if recorder.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", recorder.Code, http.StatusUnauthorized)
+			}
+			if recorder.Body.Len() != 0 {
+				t.Fatalf("body = %q, want none", recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestMetricsMethod(t *testing.T) {
+	m := Metrics{}
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/document/metrics", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
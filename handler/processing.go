@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// heartbeat is how often an idle event stream sends a comment to keep proxies from closing it
+const heartbeat = 15 * time.Second
+
+// processing returns the processing state of the file. With wait it is a long
+// poll returning once the state differs from version, and an Accept of
+// text/event-stream streams every change until processing is finished.
+// Both end before the write timeout of the server, clients ask again.
+func (f *Resource) processing(w http.ResponseWriter, r *http.Request, id string) {
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		f.processingEvents(w, r, id)
+		return
+	}
+
+	wait, err := strconv.Atoi(r.FormValue("wait"))
+	if err != nil || wait < 0 {
+		wait = 0
+	}
+	timeout := time.Duration(f.server.Timeout) * time.Second
+	if wait > 0 {
+		timeout = time.Duration(wait) * time.Second
+		if limit := f.streamLimit(); timeout > limit {
+			timeout = limit
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	actor := requestActor(r)
+	state, err := f.fileService.Processing(ctx, f.server.JWT.Secret(), actor, id)
+	if err == nil && wait > 0 {
+		state, err = f.fileService.WaitProcessing(ctx, f.server.JWT.Secret(), actor, id, r.FormValue("version"))
+	}
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	f.server.Success(w, r, state)
+}
+
+// processingEvents streams the processing state as server-sent events
+func (f *Resource) processingEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusNotAcceptable)
+		f.server.Error(w, r, errors.New("event streams are not supported"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), f.streamLimit())
+	defer cancel()
+
+	// the first state is read before the stream starts so access errors get a status
+	actor := requestActor(r)
+	state, err := f.fileService.Processing(ctx, f.server.JWT.Secret(), actor, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 2000\n\n")
+
+	// a reconnecting client sends the version it saw last
+	version := r.Header.Get("Last-Event-ID")
+	for {
+		if state.Version != version {
+			data, _ := json.Marshal(state)
+			fmt.Fprintf(w, "id: %s\nevent: processing\ndata: %s\n\n", state.Version, data)
+			version = state.Version
+		} else {
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+		if state.Finished || ctx.Err() != nil {
+			return
+		}
+
+		waitCtx, waitCancel := context.WithTimeout(ctx, heartbeat)
+		state, err = f.fileService.WaitProcessing(waitCtx, f.server.JWT.Secret(), actor, id, version)
+		waitCancel()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// streamLimit is how long a response may wait, a second short of the write timeout
+func (f *Resource) streamLimit() time.Duration {
+	limit := time.Duration(f.server.Timeout)*time.Second - time.Second
+	if limit < time.Second {
+		limit = time.Second
+	}
+	return limit
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/greatfocus/gf-document/lifecycle"
+	"github.com/greatfocus/gf-document/logging"
+	server "github.com/greatfocus/gf-sframe/server"
+	"github.com/sirupsen/logrus"
+)
+
+// Correlate gives the request a correlation id, taken from X-Request-ID when the
+// caller sent a valid one, echoes it in the response and attaches a request
+// scoped log entry carrying it to the context. One access log line is written
+// per request once the handler returns. It runs inside Instrument so the lines
+// carry the trace of the request.
+func Correlate(logger *logrus.Logger, route string) server.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			id := r.Header.Get(logging.RequestIDHeader)
+			if !logging.ValidRequestID(id) {
+				id = logging.NewRequestID()
+			}
+			w.Header().Set(logging.RequestIDHeader, id)
+			w.Header().Set("Access-Control-Expose-Headers", logging.RequestIDHeader)
+
+			ctx := logging.WithRequestID(r.Context(), id)
+			ctx = logging.WithEntry(ctx, logger.WithFields(logrus.Fields{
+				"request_id": id,
+				"method":     r.Method,
+				"route":      route,
+			}))
+
+			recorder := &statusRecorder{ResponseWriter: w}
+			h.ServeHTTP(recorder, r.WithContext(ctx))
+
+			status := recorder.Status()
+			entry := logging.From(ctx).WithFields(logrus.Fields{
+				"path":        r.URL.Path,
+				"status":      status,
+				"bytes":       atomic.LoadInt64(&recorder.bytes),
+				"duration_ms": time.Since(started).Milliseconds(),
+				"remote_addr": r.RemoteAddr,
+				"user_agent":  r.UserAgent(),
+			})
+			if status >= http.StatusInternalServerError {
+				entry.Error("request")
+				return
+			}
+			entry.Info("request")
+		})
+	}
+}
+
+// Admit passes the request through the gate, once the gate is closed for the
+// shutdown new requests get 503 and are asked to retry on another connection.
+// Requests admitted before are served to the end. It runs inside ProcessTimeout,
+// which serves the request on a goroutine of its own and may return first, so
+// a request leaves the gate once its handler returned.
+func Admit(gate *lifecycle.Gate) server.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !gate.Enter() {
+				w.Header().Set("Connection", "close")
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			defer gate.Leave()
+			if gate.Draining() {
+				w.Header().Set("Connection", "close")
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
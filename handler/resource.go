@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/services"
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+// Resource struct serves the sub resources of a file under /{uri}/file/{id}/
+type Resource struct {
+	fileService *services.FileService
+	server      *server.Server
+}
+
+// Init method
+func (f *Resource) Init(s *server.Server, fileService *services.FileService) {
+	f.fileService = fileService
+	f.server = s
+}
+
+// ServeHTTP routes to the sub resource handler
+func (f Resource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, action := f.resourcePath(r)
+	if id == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "download" && r.Method == http.MethodGet:
+		f.download(w, r, id)
+		return
+	case action == "grants" && r.Method == http.MethodGet:
+		f.getGrants(w, r, id)
+		return
+	case action == "grants" && r.Method == http.MethodPost:
+		f.grant(w, r, id)
+		return
+	case action == "grants" && r.Method == http.MethodDelete:
+		f.revoke(w, r, id)
+		return
+	case action == "renditions" && r.Method == http.MethodGet:
+		f.getRenditions(w, r, id)
+		return
+	case strings.HasPrefix(action, "renditions/") && r.Method == http.MethodGet:
+		f.rendition(w, r, id, strings.TrimPrefix(action, "renditions/"))
+		return
+	case action == "transform" && r.Method == http.MethodGet:
+		f.transform(w, r, id)
+		return
+	case action == "transform" && r.Method == http.MethodPost:
+		f.signTransform(w, r, id)
+		return
+	case action == "entries" && r.Method == http.MethodGet:
+		f.getEntries(w, r, id)
+		return
+	case action == "processing" && r.Method == http.MethodGet:
+		f.processing(w, r, id)
+		return
+	}
+
+	// catch all
+	// if no method is satisfied return an error
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	w.Header().Add("Allow", "GET, POST, DELETE")
+}
+
+// resourcePath splits /{uri}/file/{id}/{action} into its parts
+func (f *Resource) resourcePath(r *http.Request) (string, string) {
+	prefix := "/" + f.server.URI + "/file/"
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, prefix), "/", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// download streams the file content
+func (f *Resource) download(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	file, path, err := f.fileService.Download(ctx, f.server.JWT.Secret(), requestActor(r), id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+
+	content, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		f.server.Error(w, r, errors.New("file content does not exist"))
+		return
+	}
+	defer content.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(file.Name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	http.ServeContent(w, r, file.Name, file.CreatedOn, content)
+}
+
+// getGrants lists the grants of the file
+func (f *Resource) getGrants(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	grants, err := f.fileService.GetGrants(ctx, f.server.JWT.Secret(), requestActor(r), id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	f.server.Success(w, r, grants)
+}
+
+// grant shares the file with an actor or group
+func (f *Resource) grant(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	data, err := f.server.Request(w, r)
+	if err != nil {
+		return
+	}
+	grant := models.Grant{}
+	payload, _ := json.Marshal(data)
+	if err := json.Unmarshal(payload, &grant); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		f.server.Error(w, r, errors.New("invalid payload request"))
+		return
+	}
+	grant.FileID = id
+
+	created, err := f.fileService.Grant(ctx, f.server.JWT.Secret(), requestActor(r), grant)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	f.server.Success(w, r, created)
+}
+
+// revoke removes a grant from the file
+func (f *Resource) revoke(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	grant := models.Grant{ID: r.FormValue("grantId"), FileID: id}
+	err := f.fileService.Revoke(ctx, f.server.JWT.Secret(), requestActor(r), grant)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	f.server.Success(w, r, grant)
+}
+
+// getRenditions lists the renditions generated for the file
+func (f *Resource) getRenditions(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	renditions, err := f.fileService.GetRenditions(ctx, f.server.JWT.Secret(), requestActor(r), id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	f.server.Success(w, r, renditions)
+}
+
+// rendition streams a rendition of the file, generating it on the first request
+func (f *Resource) rendition(w http.ResponseWriter, r *http.Request, id string, name string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	rendition, path, err := f.fileService.Rendition(ctx, f.server.JWT.Secret(), requestActor(r), id, name)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+
+	content, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		f.server.Error(w, r, errors.New("file content does not exist"))
+		return
+	}
+	defer content.Close()
+
+	// renditions are replaced rather than changed so their id and time identify the content
+	w.Header().Set("Content-Type", rendition.MimeType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%d"`, rendition.ID, rendition.CreatedOn.Unix()))
+	http.ServeContent(w, r, "", rendition.CreatedOn, content)
+}
+
+// transform streams a resized copy of the image for the signed query parameters
+func (f *Resource) transform(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	transformed, path, err := f.fileService.Transform(ctx, f.server.JWT.Secret(), requestActor(r), id, r.URL.Query())
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+
+	content, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		f.server.Error(w, r, errors.New("file content does not exist"))
+		return
+	}
+	defer content.Close()
+
+	// the signed options fix the output so it can be cached like a rendition
+	w.Header().Set("Content-Type", transformed.MimeType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, transformed.Name))
+	http.ServeContent(w, r, "", transformed.CreatedOn, content)
+}
+
+// signTransform signs the transform options in the query for the image
+func (f *Resource) signTransform(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	signature, err := f.fileService.SignTransform(ctx, f.server.JWT.Secret(), requestActor(r), id, r.URL.Query())
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	f.server.Success(w, r, signature)
+}
+
+// getEntries lists the entries of an archive
+func (f *Resource) getEntries(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(f.server.Timeout)*time.Second)
+	defer cancel()
+
+	entries, err := f.fileService.GetEntries(ctx, f.server.JWT.Secret(), requestActor(r), id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		f.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	f.server.Success(w, r, entries)
+}
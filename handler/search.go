@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/services"
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+// Search struct
+type Search struct {
+	fileService *services.FileService
+	server      *server.Server
+}
+
+// ServeHTTP checks if is valid method
+func (s Search) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.search(w, r)
+		return
+	}
+
+	// catch all
+	// if no method is satisfied return an error
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	w.Header().Add("Allow", "GET")
+}
+
+// Init method
+func (s *Search) Init(srv *server.Server, fileService *services.FileService) {
+	s.fileService = fileService
+	s.server = srv
+}
+
+// search method returns ranked files matching q
+func (s *Search) search(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(s.server.Timeout)*time.Second)
+	defer cancel()
+
+	query, err := searchQuery(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.server.Error(w, r, err)
+		return
+	}
+
+	page, err := s.fileService.Search(ctx, s.server.JWT.Secret(), requestActor(r), query)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		s.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	s.server.Success(w, r, page)
+}
+
+// searchQuery reads the search parameters of the request
+func searchQuery(r *http.Request) (models.SearchQuery, error) {
+	query := models.SearchQuery{
+		Query:     r.FormValue("q"),
+		Language:  r.FormValue("lang"),
+		Status:    r.FormValue("status"),
+		Extension: r.FormValue("extension"),
+		MimeType:  r.FormValue("mimeType"),
+		RefID:     r.FormValue("refId"),
+	}
+
+	var err error
+	if query.From, err = formTime(r, "from"); err != nil {
+		return query, errors.New("invalid from")
+	}
+	if query.To, err = formTime(r, "to"); err != nil {
+		return query, errors.New("invalid to")
+	}
+	if query.Limit, err = formInt(r, "limit"); err != nil {
+		return query, errors.New("invalid limit")
+	}
+	if query.Offset, err = formInt(r, "offset"); err != nil {
+		return query, errors.New("invalid offset")
+	}
+	return query, nil
+}
+
+// formTime reads an RFC 3339 time or a date from the request
+func formTime(r *http.Request, name string) (time.Time, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// formInt reads an integer from the request
+func formInt(r *http.Request, name string) (int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
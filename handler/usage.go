@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/services"
+	server "github.com/greatfocus/gf-sframe/server"
+)
+
+// Usage struct
+type Usage struct {
+	fileService *services.FileService
+	server      *server.Server
+}
+
+// ServeHTTP checks if is valid method
+func (u Usage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		u.getUsage(w, r)
+		return
+	}
+
+	// catch all
+	// if no method is satisfied return an error
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	w.Header().Add("Allow", "GET")
+}
+
+// Init method
+func (u *Usage) Init(s *server.Server, fileService *services.FileService) {
+	u.fileService = fileService
+	u.server = s
+}
+
+// getUsage method returns the storage used against the quotas
+func (u *Usage) getUsage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(u.server.Timeout)*time.Second)
+	defer cancel()
+
+	var actorID int64
+	if value := r.FormValue("actorId"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			u.server.Error(w, r, errors.New("invalid actorId"))
+			return
+		}
+		actorID = id
+	}
+
+	report, err := u.fileService.GetUsage(ctx, requestActor(r), actorID)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		u.server.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	u.server.Success(w, r, report)
+}
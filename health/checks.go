@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Database checks a connection of the pool answers a query
+func Database(conn *sql.DB) Check {
+	return Check{Name: "database", Critical: true, Run: func(ctx context.Context) (string, error) {
+		var one int
+		if err := conn.QueryRowContext(ctx, "select 1").Scan(&one); err != nil {
+			return "", err
+		}
+		stats := conn.Stats()
+		return fmt.Sprintf("%d open, %d in use", stats.OpenConnections, stats.InUse), nil
+	}}
+}
+
+// Storage checks a file can be written under root and at least minFree bytes are left
+func Storage(root string, minFree uint64) Check {
+	return Check{Name: "storage", Critical: true, Run: func(ctx context.Context) (string, error) {
+		probe, err := os.CreateTemp(root, ".health-*")
+		if err != nil {
+			return "", err
+		}
+		_, err = probe.Write([]byte("ok"))
+		if cerr := probe.Close(); err == nil {
+			err = cerr
+		}
+		_ = os.Remove(probe.Name())
+		if err != nil {
+			return "", err
+		}
+
+		free, err := freeBytes(root)
+		if err != nil {
+			return "", ErrUnknown{Reason: "writable, free space unknown: " + err.Error()}
+		}
+		detail := fmt.Sprintf("%d MiB free", free>>20)
+		if free < minFree {
+			return detail, fmt.Errorf("%s, below %d MiB", detail, minFree>>20)
+		}
+		return detail, nil
+	}}
+}
+
+// Broker checks the AMQP broker at url accepts a connection
+func Broker(url string, timeout time.Duration) Check {
+	return Check{Name: "broker", Run: func(ctx context.Context) (string, error) {
+		if url == "" {
+			return "", ErrDisabled{Reason: "RABBITMQ_URL is not set"}
+		}
+		conn, err := amqp.DialConfig(url, amqp.Config{Dial: amqp.DefaultDial(timeout)})
+		if err != nil {
+			return "", err
+		}
+		_ = conn.Close()
+		return "", nil
+	}}
+}
+
+// Migrations checks the database schema is at least at the expected version,
+// as recorded in schema_migrations
+func Migrations(conn *sql.DB, expected int64) Check {
+	return Check{Name: "migrations", Critical: true, Run: func(ctx context.Context) (string, error) {
+		var found sql.NullString
+		if err := conn.QueryRowContext(ctx, "select to_regclass('schema_migrations')::text").Scan(&found); err != nil {
+			return "", err
+		}
+		if !found.Valid {
+			return "", ErrUnknown{Reason: fmt.Sprintf("schema_migrations not found, expected version %d", expected)}
+		}
+
+		var current int64
+		if err := conn.QueryRowContext(ctx, "select coalesce(max(version), 0) from schema_migrations").Scan(&current); err != nil {
+			return "", err
+		}
+		detail := fmt.Sprintf("version %d, expected %d", current, expected)
+		if current < expected {
+			return detail, fmt.Errorf("schema is behind, %s", detail)
+		}
+		return detail, nil
+	}}
+}
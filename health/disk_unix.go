@@ -0,0 +1,14 @@
+//go:build !windows
+
+package health
+
+import "syscall"
+
+// freeBytes returns the bytes available to the service on the file system of path
+func freeBytes(path string) (uint64, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
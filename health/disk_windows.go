@@ -0,0 +1,8 @@
+package health
+
+import "errors"
+
+// freeBytes is not measured on windows
+func freeBytes(path string) (uint64, error) {
+	return 0, errors.New("not supported")
+}
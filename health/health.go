@@ -0,0 +1,166 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Component and report states
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+	StatusUnknown  = "unknown"
+	StatusDisabled = "disabled"
+)
+
+// ErrUnknown is returned by checks that cannot tell the state of their component
+type ErrUnknown struct {
+	Reason string
+}
+
+// Error returns the reason
+func (e ErrUnknown) Error() string {
+	return e.Reason
+}
+
+// ErrDisabled is returned by checks of components that are not configured
+type ErrDisabled struct {
+	Reason string
+}
+
+// Error returns the reason
+func (e ErrDisabled) Error() string {
+	return e.Reason
+}
+
+// Check struct tests a component the service depends on. The service is not
+// ready while a critical component is down, other components only degrade it.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) (string, error)
+}
+
+// Component struct is the result of a check
+type Component struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Report struct is the result of every check of a probe
+type Report struct {
+	Status     string      `json:"status"`
+	Components []Component `json:"components"`
+	CheckedOn  time.Time   `json:"checkedOn"`
+}
+
+// HTTPStatus returns 503 when a critical component is down
+func (r Report) HTTPStatus() int {
+	if r.Status == StatusDown {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// Checker struct runs its checks at most once per ttl. Probes arriving while
+// the checks run wait for that run rather than starting their own, so a storm
+// of probes costs one round of checks.
+type Checker struct {
+	checks  []Check
+	ttl     time.Duration
+	timeout time.Duration
+	mutex   sync.Mutex
+	report  Report
+}
+
+// NewChecker returns a checker caching reports for ttl, each check is cut off after timeout
+func NewChecker(ttl time.Duration, timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, ttl: ttl, timeout: timeout}
+}
+
+// Report returns the cached report, running the checks when it is older than ttl
+func (c *Checker) Report() Report {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.report.CheckedOn.IsZero() && time.Since(c.report.CheckedOn) < c.ttl {
+		return c.report
+	}
+	c.report = c.run()
+	return c.report
+}
+
+// run runs the checks concurrently. They do not use the context of a probe as
+// their result is shared with the probes waiting for it.
+func (c *Checker) run() Report {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	report := Report{Status: StatusUp, Components: make([]Component, len(c.checks))}
+	var wait sync.WaitGroup
+	for i, check := range c.checks {
+		wait.Add(1)
+		go func(i int, check Check) {
+			defer wait.Done()
+			report.Components[i] = runCheck(ctx, check)
+		}(i, check)
+	}
+	wait.Wait()
+
+	for _, component := range report.Components {
+		switch {
+		case component.Status != StatusDown:
+		case component.Critical:
+			report.Status = StatusDown
+		case report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+	report.CheckedOn = time.Now().UTC()
+	return report
+}
+
+// runCheck runs a check, a check still running at the timeout is reported down
+func runCheck(ctx context.Context, check Check) Component {
+	started := time.Now()
+	component := Component{Name: check.Name, Critical: check.Critical}
+
+	type result struct {
+		detail string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		detail, err := check.Run(ctx)
+		done <- result{detail, err}
+	}()
+
+	var outcome result
+	select {
+	case outcome = <-done:
+	case <-ctx.Done():
+		outcome = result{err: ctx.Err()}
+	}
+
+	component.Detail = outcome.detail
+	switch err := outcome.err.(type) {
+	case nil:
+		component.Status = StatusUp
+	case ErrUnknown:
+		component.Status = StatusUnknown
+		component.Detail = err.Reason
+	case ErrDisabled:
+		component.Status = StatusDisabled
+		component.Detail = err.Reason
+	default:
+		component.Status = StatusDown
+		component.Detail = err.Error()
+	}
+	component.DurationMs = time.Since(started).Milliseconds()
+	return component
+}
@@ -0,0 +1,79 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Gate struct counts the work in flight and turns new work away once closed.
+// Draining comes first, the readiness probe fails so traffic moves to other
+// instances while work still arriving is served.
+type Gate struct {
+	mutex    sync.Mutex
+	draining bool
+	closed   bool
+	active   int
+	idle     chan struct{}
+}
+
+// NewGate returns an open gate
+func NewGate() *Gate {
+	return &Gate{idle: make(chan struct{})}
+}
+
+// Enter admits work, false once the gate is closed. Admitted work must Leave.
+func (g *Gate) Enter() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.closed {
+		return false
+	}
+	g.active++
+	return true
+}
+
+// Leave ends work admitted by Enter
+func (g *Gate) Leave() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.active--
+	if g.closed && g.active == 0 {
+		close(g.idle)
+	}
+}
+
+// Drain marks the gate as going away while still admitting work
+func (g *Gate) Drain() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.draining = true
+}
+
+// Draining reports if the gate is going away
+func (g *Gate) Draining() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.draining || g.closed
+}
+
+// Close stops admitting work and waits for the work in flight until ctx is done
+func (g *Gate) Close(ctx context.Context) error {
+	g.mutex.Lock()
+	if !g.closed {
+		g.closed = true
+		if g.active == 0 {
+			close(g.idle)
+		}
+	}
+	g.mutex.Unlock()
+
+	select {
+	case <-g.idle:
+		return nil
+	case <-ctx.Done():
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		return fmt.Errorf("%d still in flight: %w", g.active, ctx.Err())
+	}
+}
@@ -0,0 +1,121 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Shutdown settings
+const (
+	defaultTimeout = 30 * time.Second
+	defaultDelay   = 5 * time.Second
+)
+
+// hook is a step of the shutdown
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager struct stops the service in order when it receives SIGTERM or SIGINT
+type Manager struct {
+	logger  *logrus.Logger
+	timeout time.Duration
+	delay   time.Duration
+	hooks   []hook
+}
+
+// NewManager returns a manager giving the shutdown SHUTDOWN_TIMEOUT_SECONDS to
+// complete, SHUTDOWN_DELAY_SECONDS of it are spent draining before new
+// requests are turned away
+func NewManager(logger *logrus.Logger) *Manager {
+	return &Manager{
+		logger:  logger,
+		timeout: envSeconds("SHUTDOWN_TIMEOUT_SECONDS", defaultTimeout),
+		delay:   envSeconds("SHUTDOWN_DELAY_SECONDS", defaultDelay),
+	}
+}
+
+// Delay returns how long the service keeps serving after the signal while its
+// readiness probe fails
+func (m *Manager) Delay() time.Duration {
+	return m.delay
+}
+
+// OnShutdown adds a step to the shutdown, steps run one after the other in the
+// order they were added and share the deadline of the shutdown
+func (m *Manager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Wait blocks until SIGTERM or SIGINT then runs the shutdown. A step missing
+// the deadline is logged and the next step still runs, with what time is left.
+func (m *Manager) Wait() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	received := <-signals
+	signal.Stop(signals)
+
+	m.logger.WithField("signal", received.String()).Info("shutdown started")
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), m.delay+m.timeout)
+	defer cancel()
+
+	for _, step := range m.hooks {
+		stepStarted := time.Now()
+		err := step.stop(ctx)
+		entry := m.logger.WithFields(logrus.Fields{
+			"step":        step.name,
+			"duration_ms": time.Since(stepStarted).Milliseconds(),
+		})
+		if err != nil {
+			entry.WithError(err).Warn("shutdown step incomplete")
+			continue
+		}
+		entry.Info("shutdown step done")
+	}
+	m.logger.WithField("duration_ms", time.Since(started).Milliseconds()).Info("shutdown complete")
+}
+
+// Await runs wait, a blocking stop such as WaitGroup.Wait, until ctx is done.
+// The wait carries on in the background when ctx ends first.
+func Await(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sleep pauses for d or until ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// envSeconds reads a positive number of seconds from the environment
+func envSeconds(name string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader carries the correlation id of a request in and out of the service
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey carries the correlation id in job payloads and message headers
+const requestIDKey = "x-request-id"
+
+// maxRequestID bounds the length of a correlation id taken from a caller
+const maxRequestID = 128
+
+// defaultLogger is used when ctx carries no request entry
+var defaultLogger = logrus.StandardLogger()
+
+// SetDefault sets the logger of work that does not run inside a request, such as
+// jobs, and its level from LOG_LEVEL, info when it is not set
+func SetDefault(logger *logrus.Logger) {
+	if logger == nil {
+		return
+	}
+	level, err := logrus.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	logger.SetLevel(level)
+	defaultLogger = logger
+}
+
+// requestIDCtxKey is the context key of the correlation id
+type requestIDCtxKey struct{}
+
+// entryKey is the context key of the request entry
+type entryKey struct{}
+
+// scope holds the entry of a request, middlewares running inside the one that
+// created it add their fields so the access log written at the end has them
+type scope struct {
+	mutex sync.Mutex
+	entry *logrus.Entry
+}
+
+// NewRequestID returns a new correlation id
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// ValidRequestID checks if a correlation id sent by a caller is safe to log and echo
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID attaches the correlation id to ctx
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestID returns the correlation id of ctx, empty when there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// WithEntry starts the request scoped entry of ctx
+func WithEntry(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, &scope{entry: entry})
+}
+
+// AddFields adds fields to the request entry of ctx, it does nothing outside a request
+func AddFields(ctx context.Context, fields logrus.Fields) {
+	current, ok := ctx.Value(entryKey{}).(*scope)
+	if !ok {
+		return
+	}
+	current.mutex.Lock()
+	current.entry = current.entry.WithFields(fields)
+	current.mutex.Unlock()
+}
+
+// From returns the entry to log with in ctx. Inside a request it is the request
+// entry, elsewhere the default logger with the correlation id of ctx. The trace
+// and span of ctx are added so log lines can be joined to their traces.
+func From(ctx context.Context) *logrus.Entry {
+	var entry *logrus.Entry
+	if current, ok := ctx.Value(entryKey{}).(*scope); ok {
+		current.mutex.Lock()
+		entry = current.entry
+		current.mutex.Unlock()
+	} else {
+		entry = logrus.NewEntry(defaultLogger)
+		if id := RequestID(ctx); id != "" {
+			entry = entry.WithField("request_id", id)
+		}
+	}
+
+	if span := tracing.SpanFrom(ctx).Context(); span.Valid() {
+		entry = entry.WithFields(logrus.Fields{
+			"trace_id": span.TraceID.String(),
+			"span_id":  span.SpanID.String(),
+		})
+	}
+	return entry
+}
+
+// Inject writes the correlation id of ctx to the carrier of a job or message
+func Inject(ctx context.Context, carrier tracing.Carrier) {
+	if id := RequestID(ctx); id != "" {
+		carrier.Set(requestIDKey, id)
+	}
+}
+
+// Extract reads the correlation id of a job or message into ctx. Without one
+// the id already in ctx is kept, or a new id assigned so the work can still be
+// followed.
+func Extract(ctx context.Context, carrier tracing.Carrier) context.Context {
+	id := carrier.Get(requestIDKey)
+	if ValidRequestID(id) {
+		return WithRequestID(ctx, id)
+	}
+	if RequestID(ctx) != "" {
+		return ctx
+	}
+	return WithRequestID(ctx, NewRequestID())
+}
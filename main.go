@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"os"
 	"time"
 
 	"github.com/go-co-op/gocron"
+	"github.com/greatfocus/gf-document/cli"
+	"github.com/greatfocus/gf-document/lifecycle"
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/repositories"
 	"github.com/greatfocus/gf-document/router"
 	"github.com/greatfocus/gf-document/task"
+	"github.com/greatfocus/gf-document/tracing"
 	"github.com/greatfocus/gf-sframe/server"
 	_ "github.com/lib/pq"
 )
@@ -14,15 +21,76 @@ import (
 func main() {
 
 	service := server.NewServer("gf-document", "document")
-	service.Mux = router.LoadRouter(service)
+	logging.SetDefault(service.Logger)
+	tracing.Init("gf-document")
+	conn := repositories.Connect(service.Logger)
+
+	// gf-document with a command runs an admin command and exits
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(service, conn, os.Args[1:]))
+	}
 
 	// background task
 	tasks := task.Tasks{}
-	tasks.Init(service)
+	tasks.Init(service, conn)
+
+	// the service only serves a schema at the version of the binary
+	migrateSchema(conn, service.Logger)
+
+	// requests pass the gate so the shutdown can wait for them
+	requests := lifecycle.NewGate()
+	service.Mux = router.LoadRouter(service, conn, requests)
 	schedule := gocron.NewScheduler(time.UTC)
 	schedule.Cron("0 0 * * *").Do(tasks.RemoveTemporaryFile) // every minute
+	schedule.Cron("30 * * * *").Do(tasks.ReindexFileNames)   // every hour
+	schedule.Cron("15 3 * * *").Do(tasks.PurgeJobs)          // every day
+	schedule.Cron("*/5 * * * *").Do(tasks.ExpireJobs)        // every five minutes
+	schedule.Cron("45 2 * * *").Do(tasks.VerifyAudit)        // every day
+	schedule.Cron("30 4 * * *").Do(tasks.Reconcile)          // every day
+	schedule.StartAsync()
+
+	// processing of uploads
+	workers, stopWorkers := context.WithCancel(context.Background())
+	tasks.StartWorkers(workers)
+
+	scrubber, stopScrubber := context.WithCancel(context.Background())
+	tasks.StartScrubber(scrubber)
+
+	consumers, stopConsumers := context.WithCancel(context.Background())
+	tasks.EventsListerner(consumers)
 
-	tasks.EventsListerner()
+	httpServer := router.Listen(service)
 
-	service.Start()
+	// on SIGTERM stop taking work, then finish what is in flight before exiting
+	manager := lifecycle.NewManager(service.Logger)
+	manager.OnShutdown("requests", func(ctx context.Context) error {
+		requests.Drain()
+		if err := lifecycle.Sleep(ctx, manager.Delay()); err != nil {
+			return err
+		}
+		if err := requests.Close(ctx); err != nil {
+			return err
+		}
+		return httpServer.Shutdown(ctx)
+	})
+	manager.OnShutdown("consumers", func(ctx context.Context) error {
+		stopConsumers()
+		return lifecycle.Await(ctx, tasks.WaitConsumers)
+	})
+	manager.OnShutdown("scheduler", func(ctx context.Context) error {
+		return lifecycle.Await(ctx, schedule.Stop)
+	})
+	manager.OnShutdown("workers", func(ctx context.Context) error {
+		stopWorkers()
+		return lifecycle.Await(ctx, tasks.WaitWorkers)
+	})
+	manager.OnShutdown("scrubber", func(ctx context.Context) error {
+		stopScrubber()
+		return lifecycle.Await(ctx, tasks.WaitScrubber)
+	})
+	manager.OnShutdown("tracing", tracing.Shutdown)
+	manager.OnShutdown("database", func(ctx context.Context) error {
+		return conn.Close()
+	})
+	manager.Wait()
 }
@@ -0,0 +1,203 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TIFF tags read or removed by the ingest step
+const (
+	tagImageWidth       = 0x0100
+	tagImageLength      = 0x0101
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagInteropIFD       = 0xA005
+	tagDateTimeOriginal = 0x9003
+	tagPixelXDimension  = 0xA002
+	tagPixelYDimension  = 0xA003
+)
+
+// maxIFDs bounds the IFDs followed in a file so loops in broken files end
+const maxIFDs = 16
+
+// exifTimeLayout is the layout of EXIF date and time values
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// typeSizes are the byte sizes of the TIFF field types
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4}
+
+// errInvalidTIFF is returned for TIFF structures that cannot be read
+var errInvalidTIFF = errors.New("invalid tiff structure")
+
+// tiff struct reads the IFDs of a TIFF file or an EXIF block
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// entry is a field of an IFD
+type entry struct {
+	position int
+	tag      uint16
+	kind     uint16
+	count    uint32
+}
+
+// parseTIFF reads the byte order of the header
+func parseTIFF(data []byte) (*tiff, error) {
+	if len(data) < 8 {
+		return nil, errInvalidTIFF
+	}
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errInvalidTIFF
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, errInvalidTIFF
+	}
+	return t, nil
+}
+
+// first returns the offset of the first IFD
+func (t *tiff) first() int {
+	return int(t.order.Uint32(t.data[4:]))
+}
+
+// ifd reads the entries of the IFD at offset and the offset of the next one
+func (t *tiff) ifd(offset int) ([]entry, int, error) {
+	if offset < 8 || offset+2 > len(t.data) {
+		return nil, 0, errInvalidTIFF
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	end := offset + 2 + count*12
+	if end+4 > len(t.data) {
+		return nil, 0, errInvalidTIFF
+	}
+	entries := make([]entry, count)
+	for i := range entries {
+		position := offset + 2 + i*12
+		entries[i] = entry{
+			position: position,
+			tag:      t.order.Uint16(t.data[position:]),
+			kind:     t.order.Uint16(t.data[position+2:]),
+			count:    t.order.Uint32(t.data[position+4:]),
+		}
+	}
+	return entries, int(t.order.Uint32(t.data[end:])), nil
+}
+
+// value returns the bytes of an entry, inline or out of line
+func (t *tiff) value(e entry) ([]byte, int, bool) {
+	size := typeSizes[e.kind] * int(e.count)
+	if size <= 0 || size > len(t.data) {
+		return nil, 0, false
+	}
+	if size <= 4 {
+		return t.data[e.position+8 : e.position+8+size], e.position + 8, true
+	}
+	offset := int(t.order.Uint32(t.data[e.position+8:]))
+	if offset < 0 || offset+size > len(t.data) {
+		return nil, 0, false
+	}
+	return t.data[offset : offset+size], offset, true
+}
+
+// integer returns a SHORT or LONG entry
+func (t *tiff) integer(e entry) (int, bool) {
+	value, _, ok := t.value(e)
+	if !ok || e.count < 1 {
+		return 0, false
+	}
+	switch e.kind {
+	case 3:
+		return int(t.order.Uint16(value)), true
+	case 4:
+		return int(t.order.Uint32(value)), true
+	}
+	return 0, false
+}
+
+// text returns an ASCII entry
+func (t *tiff) text(e entry) (string, bool) {
+	value, _, ok := t.value(e)
+	if !ok || e.kind != 2 {
+		return "", false
+	}
+	return strings.TrimRight(string(value), "\x00 "), true
+}
+
+// collect records the whitelisted fields of the first IFD and its EXIF IFD
+func (t *tiff) collect(fields map[string]string) {
+	entries, _, err := t.ifd(t.first())
+	if err != nil {
+		return
+	}
+	t.record(entries, fields)
+	for _, e := range entries {
+		if e.tag != tagExifIFD {
+			continue
+		}
+		if offset, ok := t.integer(e); ok {
+			if exif, _, err := t.ifd(offset); err == nil {
+				t.record(exif, fields)
+			}
+		}
+	}
+}
+
+// record copies the whitelisted fields of the entries
+func (t *tiff) record(entries []entry, fields map[string]string) {
+	for _, e := range entries {
+		switch e.tag {
+		case tagImageWidth, tagPixelXDimension:
+			if value, ok := t.integer(e); ok && value > 0 {
+				fields[FieldWidth] = strconv.Itoa(value)
+			}
+		case tagImageLength, tagPixelYDimension:
+			if value, ok := t.integer(e); ok && value > 0 {
+				fields[FieldHeight] = strconv.Itoa(value)
+			}
+		case tagOrientation:
+			if value, ok := t.integer(e); ok && value >= 1 && value <= 8 {
+				fields[FieldOrientation] = strconv.Itoa(value)
+			}
+		case tagDateTimeOriginal, tagDateTime:
+			value, ok := t.text(e)
+			if !ok {
+				continue
+			}
+			// the original capture time wins over the time the file was changed
+			if _, found := fields[FieldCapturedOn]; found && e.tag == tagDateTime {
+				continue
+			}
+			if captured, err := time.Parse(exifTimeLayout, value); err == nil {
+				fields[FieldCapturedOn] = captured.Format("2006-01-02T15:04:05")
+			}
+		}
+	}
+}
+
+// orientationExif builds an EXIF block holding only the orientation so stripped
+// images are still displayed upright
+func orientationExif(orientation int) []byte {
+	data := make([]byte, 26)
+	copy(data, "MM")
+	binary.BigEndian.PutUint16(data[2:], 42)
+	binary.BigEndian.PutUint32(data[4:], 8)
+	binary.BigEndian.PutUint16(data[8:], 1)
+	binary.BigEndian.PutUint16(data[10:], tagOrientation)
+	binary.BigEndian.PutUint16(data[12:], 3)
+	binary.BigEndian.PutUint32(data[14:], 1)
+	binary.BigEndian.PutUint16(data[18:], uint16(orientation))
+	return data
+}
@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+	"strconv"
+)
+
+// JPEG markers handled by the ingest step
+const (
+	markerSOI   = 0xD8
+	markerSOS   = 0xDA
+	markerEOI   = 0xD9
+	markerAPP0  = 0xE0
+	markerAPP1  = 0xE1
+	markerAPP2  = 0xE2
+	markerAPP14 = 0xEE
+	markerAPP15 = 0xEF
+	markerCOM   = 0xFE
+)
+
+// exifHeader starts the APP1 segment of EXIF
+var exifHeader = []byte("Exif\x00\x00")
+
+// errInvalidJPEG is returned for JPEG files whose segments cannot be read
+var errInvalidJPEG = errors.New("invalid jpeg structure")
+
+// processJPEG records the fields of the EXIF segment and drops every application
+// segment except JFIF, ICC profiles and Adobe colour information
+func processJPEG(data []byte, fields map[string]string) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, errInvalidJPEG
+	}
+	if config, err := jpeg.DecodeConfig(bytes.NewReader(data)); err == nil {
+		fields[FieldWidth] = strconv.Itoa(config.Width)
+		fields[FieldHeight] = strconv.Itoa(config.Height)
+	}
+
+	kept := [][]byte{}
+	position := 2
+	for {
+		// markers may be padded with fill bytes
+		for position+1 < len(data) && data[position] == 0xFF && data[position+1] == 0xFF {
+			position++
+		}
+		if position+4 > len(data) || data[position] != 0xFF {
+			return nil, errInvalidJPEG
+		}
+		marker := data[position+1]
+		if marker == markerSOS || marker == markerEOI {
+			kept = append(kept, data[position:])
+			break
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			kept = append(kept, data[position:position+2])
+			position += 2
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[position+2:]))
+		end := position + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errInvalidJPEG
+		}
+		segment := data[position:end]
+		payload := segment[4:]
+		position = end
+
+		switch {
+		case marker == markerAPP1 && bytes.HasPrefix(payload, exifHeader):
+			if t, err := parseTIFF(payload[len(exifHeader):]); err == nil {
+				dimensions := map[string]string{}
+				t.collect(dimensions)
+				// the frame header is more reliable than the EXIF pixel dimensions
+				delete(dimensions, FieldWidth)
+				delete(dimensions, FieldHeight)
+				for name, value := range dimensions {
+					fields[name] = value
+				}
+			}
+		case marker == markerAPP0, marker == markerAPP2, marker == markerAPP14:
+			kept = append(kept, segment)
+		case marker >= markerAPP1 && marker <= markerAPP15, marker == markerCOM:
+			// XMP, IPTC, comments and vendor segments are dropped
+		default:
+			kept = append(kept, segment)
+		}
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write([]byte{0xFF, markerSOI})
+	exif := orientation(fields) > 1
+	for _, segment := range kept {
+		// the orientation goes after JFIF which has to come first
+		if exif && segment[1] != markerAPP0 {
+			out.Write(exifSegment(orientation(fields)))
+			exif = false
+		}
+		out.Write(segment)
+	}
+	return out.Bytes(), nil
+}
+
+// exifSegment wraps an orientation only EXIF block in an APP1 segment
+func exifSegment(orientation int) []byte {
+	block := orientationExif(orientation)
+	segment := make([]byte, 4, 4+len(exifHeader)+len(block))
+	segment[0], segment[1] = 0xFF, markerAPP1
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(exifHeader)+len(block)))
+	segment = append(segment, exifHeader...)
+	return append(segment, block...)
+}
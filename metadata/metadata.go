@@ -0,0 +1,111 @@
+package metadata
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Fields recorded as document metadata, everything else is dropped
+const (
+	FieldWidth       = "width"
+	FieldHeight      = "height"
+	FieldOrientation = "orientation"
+	FieldCapturedOn  = "capturedOn"
+	// FieldStatus records what the ingest step did with the metadata
+	FieldStatus = "metadataStatus"
+)
+
+// Statuses recorded in FieldStatus
+const (
+	StatusStripped   = "stripped"
+	StatusKept       = "kept"
+	StatusUnreadable = "unreadable"
+)
+
+// Modes of the ingest step
+const (
+	// ModeStrip removes EXIF, XMP and IPTC from the stored file
+	ModeStrip = "strip"
+	// ModeKeep stores the file unchanged
+	ModeKeep = "keep"
+)
+
+// Policy maps a mime type to the mode used for it
+type Policy map[string]string
+
+// LoadPolicy reads METADATA_POLICY, a comma separated list of type:mode such as
+// image/tiff:keep. Types that are not listed are stripped.
+func LoadPolicy() Policy {
+	policy := Policy{}
+	for _, item := range strings.Split(os.Getenv("METADATA_POLICY"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		mode := strings.ToLower(strings.TrimSpace(parts[1]))
+		if mode == ModeStrip || mode == ModeKeep {
+			policy[strings.ToLower(strings.TrimSpace(parts[0]))] = mode
+		}
+	}
+	return policy
+}
+
+// Mode returns the mode of the mime type
+func (p Policy) Mode(mimeType string) string {
+	if mode, found := p[mimeType]; found {
+		return mode
+	}
+	return ModeStrip
+}
+
+// Supported checks if metadata can be read from the mime type
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/tiff":
+		return true
+	}
+	return false
+}
+
+// Process records the whitelisted fields of an image and, in ModeStrip, returns
+// the image without its EXIF, XMP and IPTC blocks. Other types are returned
+// unchanged. An image that cannot be parsed is returned unchanged in ModeKeep,
+// in ModeStrip no content is returned as its metadata may still be there.
+func Process(mimeType string, data []byte, mode string) ([]byte, map[string]string, error) {
+	fields := map[string]string{}
+	var cleaned []byte
+	var err error
+	switch mimeType {
+	case "image/jpeg":
+		cleaned, err = processJPEG(data, fields)
+	case "image/png":
+		cleaned, err = processPNG(data, fields)
+	case "image/tiff":
+		cleaned, err = processTIFF(data, fields)
+	default:
+		return data, fields, nil
+	}
+	switch {
+	case err != nil && mode == ModeKeep:
+		fields[FieldStatus] = StatusUnreadable
+		return data, fields, err
+	case err != nil:
+		fields[FieldStatus] = StatusUnreadable
+		return nil, fields, err
+	case mode == ModeKeep:
+		fields[FieldStatus] = StatusKept
+		return data, fields, nil
+	}
+	fields[FieldStatus] = StatusStripped
+	return cleaned, fields, nil
+}
+
+// orientation returns the recorded orientation, 1 when there is none
+func orientation(fields map[string]string) int {
+	value, err := strconv.Atoi(fields[FieldOrientation])
+	if err != nil || value < 1 || value > 8 {
+		return 1
+	}
+	return value
+}
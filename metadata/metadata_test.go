@@ -0,0 +1,334 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// secrets are the values the tests hide in metadata, none may be stored
+var secrets = []string{"SecretCamera", "N51.5074W0.1278", "secret xmp", "secret comment"}
+
+// field is a TIFF field of the test files, sub makes it a pointer to an IFD
+type field struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	data  []byte
+	sub   []field
+}
+
+// tiffBuilder lays out big endian TIFF structures
+type tiffBuilder struct {
+	buf []byte
+}
+
+// buildTIFF returns a TIFF structure with fields as its first IFD
+func buildTIFF(fields []field) []byte {
+	b := &tiffBuilder{buf: []byte{'M', 'M', 0, 42, 0, 0, 0, 8}}
+	b.ifd(fields)
+	return b.buf
+}
+
+// ifd appends an IFD, the data of its fields and its sub IFDs, returning its offset
+func (b *tiffBuilder) ifd(fields []field) int {
+	offset := len(b.buf)
+	b.buf = append(b.buf, make([]byte, 2+12*len(fields)+4)...)
+	binary.BigEndian.PutUint16(b.buf[offset:], uint16(len(fields)))
+	for i, f := range fields {
+		position := offset + 2 + 12*i
+		binary.BigEndian.PutUint16(b.buf[position:], f.tag)
+		switch {
+		case f.sub != nil:
+			binary.BigEndian.PutUint16(b.buf[position+2:], 4)
+			binary.BigEndian.PutUint32(b.buf[position+4:], 1)
+			pointer := b.ifd(f.sub)
+			binary.BigEndian.PutUint32(b.buf[position+8:], uint32(pointer))
+		case len(f.data) <= 4:
+			binary.BigEndian.PutUint16(b.buf[position+2:], f.kind)
+			binary.BigEndian.PutUint32(b.buf[position+4:], f.count)
+			copy(b.buf[position+8:], f.data)
+		default:
+			binary.BigEndian.PutUint16(b.buf[position+2:], f.kind)
+			binary.BigEndian.PutUint32(b.buf[position+4:], f.count)
+			binary.BigEndian.PutUint32(b.buf[position+8:], uint32(len(b.buf)))
+			b.buf = append(b.buf, f.data...)
+			if len(b.buf)%2 == 1 {
+				b.buf = append(b.buf, 0)
+			}
+		}
+	}
+	return offset
+}
+
+// short returns a SHORT field
+func short(tag uint16, value uint16) field {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, value)
+	return field{tag: tag, kind: 3, count: 1, data: data}
+}
+
+// ascii returns an ASCII field
+func ascii(tag uint16, value string) field {
+	return field{tag: tag, kind: 2, count: uint32(len(value) + 1), data: append([]byte(value), 0)}
+}
+
+// cameraFields are an IFD of a photo with its camera, capture time and location
+func cameraFields(extra ...field) []field {
+	fields := append(extra,
+		ascii(0x010F, secrets[0]),
+		short(tagOrientation, 6),
+		field{tag: tagExifIFD, sub: []field{ascii(tagDateTimeOriginal, "2023:05:06 07:08:09")}},
+		field{tag: tagGPSIFD, sub: []field{
+			ascii(0x0001, "N"),
+			ascii(0x001B, secrets[1]),
+		}},
+	)
+	return fields
+}
+
+// testImage returns a small opaque image
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 32), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// jpegSegment encodes an application segment
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(payload)))
+	return append(segment, payload...)
+}
+
+// testJPEG returns a JPEG carrying EXIF with GPS, XMP and a comment
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	encoded := &bytes.Buffer{}
+	if err := jpeg.Encode(encoded, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte{0xFF, markerSOI}
+	data = append(data, jpegSegment(markerAPP1, append(append([]byte{}, exifHeader...), buildTIFF(cameraFields())...))...)
+	data = append(data, jpegSegment(markerAPP1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>"+secrets[2]+"</x:xmpmeta>"))...)
+	data = append(data, jpegSegment(markerCOM, []byte(secrets[3]))...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+// testPNG returns a PNG carrying eXIf with GPS and text chunks
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	encoded := &bytes.Buffer{}
+	if err := png.Encode(encoded, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	raw := encoded.Bytes()
+	header := len(pngSignature) + 12 + 13
+	data := append([]byte{}, raw[:header]...)
+	data = append(data, pngChunk("eXIf", buildTIFF(cameraFields()))...)
+	data = append(data, pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"+secrets[2]))...)
+	data = append(data, pngChunk("tEXt", []byte("Comment\x00"+secrets[3]))...)
+	return append(data, raw[header:]...)
+}
+
+// testTIFF returns the structure of a TIFF photo with its camera and location
+func testTIFF() []byte {
+	return buildTIFF(cameraFields(short(tagImageWidth, 16), short(tagImageLength, 8)))
+}
+
+// assertClean fails when a secret or a GPS directory is left in data
+func assertClean(t *testing.T, data []byte) {
+	t.Helper()
+	for _, secret := range secrets {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("stored content still holds %q", secret)
+		}
+	}
+}
+
+// assertNoGPS fails when the TIFF structure still points to a GPS or EXIF directory
+func assertNoGPS(t *testing.T, structure []byte) {
+	t.Helper()
+	parsed, err := parseTIFF(structure)
+	if err != nil {
+		t.Fatalf("stored EXIF not readable: %v", err)
+	}
+	entries, _, err := parsed.ifd(parsed.first())
+	if err != nil {
+		t.Fatalf("stored IFD not readable: %v", err)
+	}
+	for _, e := range entries {
+		if e.tag == tagGPSIFD || e.tag == tagExifIFD {
+			t.Errorf("stored IFD still holds tag %#x", e.tag)
+		}
+	}
+}
+
+func TestProcessStrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		data     func(t *testing.T) []byte
+		decode   func(data []byte) error
+		exif     func(data []byte) []byte
+		want     map[string]string
+	}{
+		{
+			name:     "jpeg",
+			mimeType: "image/jpeg",
+			data:     testJPEG,
+			decode: func(data []byte) error {
+				_, err := jpeg.Decode(bytes.NewReader(data))
+				return err
+			},
+			exif: func(data []byte) []byte {
+				index := bytes.Index(data, exifHeader)
+				if index < 0 {
+					return nil
+				}
+				return data[index+len(exifHeader):]
+			},
+			want: map[string]string{
+				FieldWidth: "16", FieldHeight: "8", FieldOrientation: "6",
+				FieldCapturedOn: "2023-05-06T07:08:09", FieldStatus: StatusStripped,
+			},
+		},
+		{
+			name:     "png",
+			mimeType: "image/png",
+			data:     testPNG,
+			decode: func(data []byte) error {
+				_, err := png.Decode(bytes.NewReader(data))
+				return err
+			},
+			exif: func(data []byte) []byte {
+				index := bytes.Index(data, []byte("eXIf"))
+				if index < 0 {
+					return nil
+				}
+				return data[index+4:]
+			},
+			want: map[string]string{
+				FieldWidth: "16", FieldHeight: "8", FieldOrientation: "6",
+				FieldCapturedOn: "2023-05-06T07:08:09", FieldStatus: StatusStripped,
+			},
+		},
+		{
+			name:     "tiff",
+			mimeType: "image/tiff",
+			data:     func(t *testing.T) []byte { return testTIFF() },
+			exif:     func(data []byte) []byte { return data },
+			want: map[string]string{
+				FieldWidth: "16", FieldHeight: "8", FieldOrientation: "6",
+				FieldCapturedOn: "2023-05-06T07:08:09", FieldStatus: StatusStripped,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data(t)
+			for _, secret := range secrets[:2] {
+				if !bytes.Contains(data, []byte(secret)) {
+					t.Fatalf("test file does not hold %q", secret)
+				}
+			}
+
+			cleaned, fields, err := Process(tt.mimeType, data, ModeStrip)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			assertClean(t, cleaned)
+			if tt.decode != nil {
+				if err := tt.decode(cleaned); err != nil {
+					t.Errorf("stored image not readable: %v", err)
+				}
+			}
+			exif := tt.exif(cleaned)
+			if exif == nil {
+				t.Fatal("orientation not kept in the stored image")
+			}
+			assertNoGPS(t, exif)
+			for name, value := range tt.want {
+				if fields[name] != value {
+					t.Errorf("field %s = %q, want %q", name, fields[name], value)
+				}
+			}
+			if len(fields) != len(tt.want) {
+				t.Errorf("fields = %v, want %v", fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessKeep(t *testing.T) {
+	data := testJPEG(t)
+	kept, fields, err := Process("image/jpeg", data, ModeKeep)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if !bytes.Equal(kept, data) {
+		t.Error("kept image was changed")
+	}
+	if fields[FieldStatus] != StatusKept || fields[FieldOrientation] != "6" {
+		t.Errorf("fields = %v", fields)
+	}
+}
+
+func TestProcessUnreadable(t *testing.T) {
+	jpegData := testJPEG(t)
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+	}{
+		{"jpeg without start", "image/jpeg", jpegData[2:]},
+		{"jpeg cut in a segment", "image/jpeg", jpegData[:30]},
+		{"png without signature", "image/png", testPNG(t)[8:]},
+		{"png cut in a chunk", "image/png", testPNG(t)[:40]},
+		{"tiff without header", "image/tiff", []byte("not a tiff file")},
+		{"tiff with a bad ifd", "image/tiff", []byte{'M', 'M', 0, 42, 0, 0, 0xFF, 0xFF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, fields, err := Process(tt.mimeType, tt.data, ModeStrip)
+			if err == nil {
+				t.Fatal("Process() stripped an unreadable image")
+			}
+			if stripped != nil {
+				t.Error("Process() returned content of an unreadable image")
+			}
+			if fields[FieldStatus] != StatusUnreadable {
+				t.Errorf("status = %q, want %q", fields[FieldStatus], StatusUnreadable)
+			}
+
+			kept, _, err := Process(tt.mimeType, tt.data, ModeKeep)
+			if err == nil || !bytes.Equal(kept, tt.data) {
+				t.Error("Process() did not keep an unreadable image unchanged")
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	t.Setenv("METADATA_POLICY", "image/tiff:keep, image/PNG:Strip,image/gif:drop,broken")
+	policy := LoadPolicy()
+	tests := map[string]string{
+		"image/tiff": ModeKeep,
+		"image/png":  ModeStrip,
+		"image/gif":  ModeStrip,
+		"image/jpeg": ModeStrip,
+	}
+	for mimeType, want := range tests {
+		if got := policy.Mode(mimeType); got != want {
+			t.Errorf("Mode(%s) = %s, want %s", mimeType, got, want)
+		}
+	}
+}
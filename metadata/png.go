@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strconv"
+)
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// errInvalidPNG is returned for PNG files whose chunks cannot be read
+var errInvalidPNG = errors.New("invalid png structure")
+
+// processPNG records the fields of the header and eXIf chunks and drops the
+// text, time and EXIF chunks, which carry XMP, comments and camera data
+func processPNG(data []byte, fields map[string]string) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errInvalidPNG
+	}
+
+	kept := [][]byte{}
+	position := len(pngSignature)
+	for position < len(data) {
+		if position+12 > len(data) {
+			return nil, errInvalidPNG
+		}
+		length := int(binary.BigEndian.Uint32(data[position:]))
+		end := position + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errInvalidPNG
+		}
+		kind := string(data[position+4 : position+8])
+		chunk := data[position:end]
+		body := chunk[8 : 8+length]
+		position = end
+
+		switch kind {
+		case "IHDR":
+			if length >= 8 {
+				fields[FieldWidth] = strconv.Itoa(int(binary.BigEndian.Uint32(body)))
+				fields[FieldHeight] = strconv.Itoa(int(binary.BigEndian.Uint32(body[4:])))
+			}
+			kept = append(kept, chunk)
+		case "eXIf":
+			if t, err := parseTIFF(body); err == nil {
+				exif := map[string]string{}
+				t.collect(exif)
+				delete(exif, FieldWidth)
+				delete(exif, FieldHeight)
+				for name, value := range exif {
+					fields[name] = value
+				}
+			}
+		case "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			kept = append(kept, chunk)
+		}
+	}
+	if len(kept) == 0 || string(kept[0][4:8]) != "IHDR" {
+		return nil, errInvalidPNG
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	out.Write(kept[0])
+	// eXIf has to come before the image data so it follows the header
+	if orientation(fields) > 1 {
+		out.Write(pngChunk("eXIf", orientationExif(orientation(fields))))
+	}
+	for _, chunk := range kept[1:] {
+		out.Write(chunk)
+	}
+	return out.Bytes(), nil
+}
+
+// pngChunk encodes a chunk with its length and checksum
+func pngChunk(kind string, body []byte) []byte {
+	chunk := make([]byte, 8, 12+len(body))
+	binary.BigEndian.PutUint32(chunk, uint32(len(body)))
+	copy(chunk[4:], kind)
+	chunk = append(chunk, body...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(chunk[4:]))
+	return append(chunk, crc...)
+}
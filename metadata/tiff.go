@@ -0,0 +1,98 @@
+package metadata
+
+// strippedTags are the TIFF fields removed from stored files: device and
+// software details, free text, XMP, IPTC, Photoshop blocks and the EXIF and
+// GPS directories
+var strippedTags = map[uint16]bool{
+	0x010E:      true, // ImageDescription
+	0x010F:      true, // Make
+	0x0110:      true, // Model
+	0x0131:      true, // Software
+	tagDateTime: true,
+	0x013B:      true, // Artist
+	0x013C:      true, // HostComputer
+	0x02BC:      true, // XMP
+	0x83BB:      true, // IPTC
+	0x8649:      true, // Photoshop
+	tagExifIFD:  true,
+	tagGPSIFD:   true,
+	0x9C9B:      true, // XPTitle
+	0x9C9C:      true, // XPComment
+	0x9C9D:      true, // XPAuthor
+	0x9C9E:      true, // XPKeywords
+	0x9C9F:      true, // XPSubject
+	0xC4A5:      true, // PrintIM
+}
+
+// processTIFF records the fields of the first IFD and removes the stripped tags
+// from every IFD, zeroing the data they pointed to so it does not linger in the file
+func processTIFF(data []byte, fields map[string]string) ([]byte, error) {
+	t, err := parseTIFF(append([]byte(nil), data...))
+	if err != nil {
+		return nil, err
+	}
+	t.collect(fields)
+
+	offset := t.first()
+	for i := 0; offset != 0 && i < maxIFDs; i++ {
+		entries, next, err := t.ifd(offset)
+		if err != nil {
+			return nil, err
+		}
+
+		kept := make([]byte, 0, len(entries)*12)
+		for _, e := range entries {
+			if !strippedTags[e.tag] {
+				kept = append(kept, t.data[e.position:e.position+12]...)
+				continue
+			}
+			if e.tag == tagExifIFD || e.tag == tagGPSIFD {
+				if pointer, ok := t.integer(e); ok {
+					t.zeroIFD(pointer, 0)
+				}
+			}
+			t.zeroValue(e)
+		}
+
+		// write the kept entries back in place and clear the slack they leave
+		t.order.PutUint16(t.data[offset:], uint16(len(kept)/12))
+		copy(t.data[offset+2:], kept)
+		end := offset + 2 + len(kept)
+		t.order.PutUint32(t.data[end:], uint32(next))
+		for position := end + 4; position < offset+2+len(entries)*12+4; position++ {
+			t.data[position] = 0
+		}
+		offset = next
+	}
+	return t.data, nil
+}
+
+// zeroValue clears the out of line data of an entry
+func (t *tiff) zeroValue(e entry) {
+	value, position, ok := t.value(e)
+	if !ok || position == e.position+8 {
+		return
+	}
+	for i := range value {
+		value[i] = 0
+	}
+}
+
+// zeroIFD clears a sub IFD, the data of its entries and the sub IFDs it points to
+func (t *tiff) zeroIFD(offset int, depth int) {
+	entries, _, err := t.ifd(offset)
+	if err != nil || depth > 2 {
+		return
+	}
+	for _, e := range entries {
+		if e.tag == tagInteropIFD {
+			if pointer, ok := t.integer(e); ok {
+				t.zeroIFD(pointer, depth+1)
+			}
+		}
+		t.zeroValue(e)
+	}
+	for position := offset; position < offset+2+len(entries)*12+4; position++ {
+		t.data[position] = 0
+	}
+}
@@ -0,0 +1,287 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Namespace prefixes the name of every metric of the service
+const Namespace = "gf_document_"
+
+// ContentType of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DurationBuckets suit request and job latencies in seconds
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// SizeBuckets suit document sizes in bytes
+var SizeBuckets = []float64{1 << 10, 10 << 10, 100 << 10, 512 << 10, 1 << 20, 5 << 20, 10 << 20, 50 << 20, 100 << 20}
+
+// labelSeparator joins label values into a series key, it cannot occur in valid UTF-8
+const labelSeparator = "\xff"
+
+// metric is a family of series written by Write
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// registry holds every metric in the order they were created
+var registry = struct {
+	sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}{names: map[string]bool{}}
+
+// register adds a metric, names must be unique
+func register(name string, m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	registry.names[name] = true
+	registry.metrics = append(registry.metrics, m)
+}
+
+// Write writes every metric in the Prometheus text format
+func Write(w io.Writer) error {
+	registry.Lock()
+	metrics := append([]metric{}, registry.metrics...)
+	registry.Unlock()
+
+	buffer := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buffer)
+	}
+	return buffer.Flush()
+}
+
+// family is the name, help and labels shared by the series of a metric
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// key joins the label values of a series, missing values are empty
+func (f family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		padded := make([]string, len(f.labels))
+		copy(padded, values)
+		values = padded
+	}
+	return strings.Join(values, labelSeparator)
+}
+
+// header writes the HELP and TYPE lines
+func (f family) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+}
+
+// series writes one sample, extra is a label added to those of the key such as le
+func (f family) series(w *bufio.Writer, suffix string, key string, extra string, value float64) {
+	w.WriteString(f.name + suffix)
+	pairs := []string{}
+	if len(f.labels) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, f.labels[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+// sortedKeys returns the series keys in a stable order
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a family of values that only go up
+type Counter struct {
+	family
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates and registers a counter
+func NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{family: family{Namespace + name, help, "counter", labels}, values: map[string]float64{}}
+	register(c.name, c)
+	return c
+}
+
+// Inc adds one to the series of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds a positive value to the series of the label values
+func (c *Counter) Add(value float64, values ...string) {
+	if value < 0 {
+		return
+	}
+	key := c.key(values)
+	c.mutex.Lock()
+	c.values[key] += value
+	c.mutex.Unlock()
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.header(w)
+	for _, key := range sortedKeys(c.values) {
+		c.series(w, "", key, "", c.values[key])
+	}
+}
+
+// Gauge is a family of values that go up and down, set when scraped
+type Gauge struct {
+	family
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge creates and registers a gauge
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	g := &Gauge{family: family{Namespace + name, help, "gauge", labels}, values: map[string]float64{}}
+	register(g.name, g)
+	return g
+}
+
+// Set sets the series of the label values
+func (g *Gauge) Set(value float64, values ...string) {
+	key := g.key(values)
+	g.mutex.Lock()
+	g.values[key] = value
+	g.mutex.Unlock()
+}
+
+// Reset drops every series, so label values that went away are not reported again
+func (g *Gauge) Reset() {
+	g.mutex.Lock()
+	g.values = map[string]float64{}
+	g.mutex.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.header(w)
+	for _, key := range sortedKeys(g.values) {
+		g.series(w, "", key, "", g.values[key])
+	}
+}
+
+// Histogram is a family of distributions counted into buckets
+type Histogram struct {
+	family
+	buckets []float64
+	mutex   sync.Mutex
+	values  map[string]*distribution
+}
+
+// distribution is one series of a histogram, counts are per bucket rather than cumulative
+type distribution struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates and registers a histogram with ascending upper bounds
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		family:  family{Namespace + name, help, "histogram", labels},
+		buckets: append([]float64{}, buckets...),
+		values:  map[string]*distribution{},
+	}
+	sort.Float64s(h.buckets)
+	register(h.name, h)
+	return h
+}
+
+// Observe counts value into the series of the label values
+func (h *Histogram) Observe(value float64, values ...string) {
+	key := h.key(values)
+	bucket := sort.SearchFloat64s(h.buckets, value)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	d, found := h.values[key]
+	if !found {
+		d = &distribution{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = d
+	}
+	if bucket < len(h.buckets) {
+		d.counts[bucket]++
+	}
+	d.count++
+	d.sum += value
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.header(w)
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		d := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += d.counts[i]
+			h.series(w, "_bucket", key, `le="`+formatValue(bound)+`"`, float64(cumulative))
+		}
+		h.series(w, "_bucket", key, `le="+Inf"`, float64(d.count))
+		h.series(w, "_sum", key, "", d.sum)
+		h.series(w, "_count", key, "", float64(d.count))
+	}
+}
+
+// formatValue writes a sample value the way Prometheus parses it
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// labelEscaper escapes label values
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// helpEscaper escapes help text
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
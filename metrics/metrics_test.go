@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+// render writes one metric the way Write does
+func render(t *testing.T, m metric) string {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	w := bufio.NewWriter(buffer)
+	m.write(w)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("test_uploads_total", "Uploads by result.\nSecond \\ line.", "result", "mime")
+	c.Inc("stored", "image/png")
+	c.Add(2.5, "stored", "image/png")
+	c.Inc("rejected")
+	c.Add(-1, "stored", "image/png")
+
+	want := `# HELP gf_document_test_uploads_total Uploads by result.\nSecond \\ line.
+# TYPE gf_document_test_uploads_total counter
+gf_document_test_uploads_total{result="rejected",mime=""} 1
+gf_document_test_uploads_total{result="stored",mime="image/png"} 3.5
+`
+	if got := render(t, c); got != want {
+		t.Fatalf("counter =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	c := NewCounter("test_plain_total", "Plain counter.")
+	if got, want := render(t, c), "# HELP gf_document_test_plain_total Plain counter.\n# TYPE gf_document_test_plain_total counter\n"; got != want {
+		t.Fatalf("empty counter = %q, want %q", got, want)
+	}
+	c.Inc()
+	if got := render(t, c); !strings.HasSuffix(got, "\ngf_document_test_plain_total 1\n") {
+		t.Fatalf("counter = %q, want a sample without labels", got)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	g := NewGauge("test_escaped", "Escaped labels.", "name")
+	g.Set(1, "back\\slash \"quoted\"\nnext line")
+
+	want := `# HELP gf_document_test_escaped Escaped labels.
+# TYPE gf_document_test_escaped gauge
+gf_document_test_escaped{name="back\\slash \"quoted\"\nnext line"} 1
+`
+	if got := render(t, g); got != want {
+		t.Fatalf("gauge =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGaugeReset(t *testing.T) {
+	g := NewGauge("test_jobs", "Jobs by status.", "status")
+	g.Set(3, "queued")
+	g.Set(1, "running")
+	g.Set(2, "queued")
+	want := `# HELP gf_document_test_jobs Jobs by status.
+# TYPE gf_document_test_jobs gauge
+gf_document_test_jobs{status="queued"} 2
+gf_document_test_jobs{status="running"} 1
+`
+	if got := render(t, g); got != want {
+		t.Fatalf("gauge =\n%s\nwant\n%s", got, want)
+	}
+
+	g.Reset()
+	g.Set(4, "queued")
+	want = `# HELP gf_document_test_jobs Jobs by status.
+# TYPE gf_document_test_jobs gauge
+gf_document_test_jobs{status="queued"} 4
+`
+	if got := render(t, g); got != want {
+		t.Fatalf("gauge after Reset =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "Durations.", []float64{1, 0.1, 0.5}, "route")
+	for _, value := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		h.Observe(value, "/document/file")
+	}
+	h.Observe(0.2, "/document/search")
+
+	want := `# HELP gf_document_test_duration_seconds Durations.
+# TYPE gf_document_test_duration_seconds histogram
+gf_document_test_duration_seconds_bucket{route="/document/file",le="0.1"} 2
+gf_document_test_duration_seconds_bucket{route="/document/file",le="0.5"} 3
+gf_document_test_duration_seconds_bucket{route="/document/file",le="1"} 4
+gf_document_test_duration_seconds_bucket{route="/document/file",le="+Inf"} 5
+gf_document_test_duration_seconds_sum{route="/document/file"} 3.15
+gf_document_test_duration_seconds_count{route="/document/file"} 5
+gf_document_test_duration_seconds_bucket{route="/document/search",le="0.1"} 0
+gf_document_test_duration_seconds_bucket{route="/document/search",le="0.5"} 1
+gf_document_test_duration_seconds_bucket{route="/document/search",le="1"} 1
+gf_document_test_duration_seconds_bucket{route="/document/search",le="+Inf"} 1
+gf_document_test_duration_seconds_sum{route="/document/search"} 0.2
+gf_document_test_duration_seconds_count{route="/document/search"} 1
+`
+	if got := render(t, h); got != want {
+		t.Fatalf("histogram =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{42, "42"},
+		{0.25, "0.25"},
+		{1 << 20, "1.048576e+06"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatValue(tt.value); got != tt.want {
+			t.Errorf("formatValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	first := NewCounter("test_write_first_total", "First.")
+	second := NewGauge("test_write_second", "Second.")
+	first.Inc()
+	second.Set(2)
+
+	buffer := &bytes.Buffer{}
+	if err := Write(buffer); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got := buffer.String()
+	want := render(t, first) + render(t, second)
+	if !strings.Contains(got, want) {
+		t.Fatalf("Write() =\n%s\nwant the metrics in the order created\n%s", got, want)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	NewCounter("test_duplicate_total", "Duplicate.")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewGauge() with a used name did not panic")
+		}
+	}()
+	NewGauge("test_duplicate_total", "Duplicate.")
+}
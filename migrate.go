@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"time"
+
+	"github.com/greatfocus/gf-document/database"
+	"github.com/sirupsen/logrus"
+)
+
+// migrateTimeout bounds a migration run, building an index concurrently on a
+// large table takes a while
+const migrateTimeout = time.Hour
+
+// migrateSchema prepares the schema before the service serves, MIGRATE_ON_START
+// apply (the default) runs the pending migrations, verify only refuses to
+// start when the schema is behind
+func migrateSchema(conn *sql.DB, logger *logrus.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	migrator, err := database.NewMigrator(conn, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := migrator.Start(ctx, os.Getenv("MIGRATE_ON_START")); err != nil {
+		logger.WithError(err).Fatal("schema not ready, refusing to serve")
+	}
+}
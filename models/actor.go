@@ -0,0 +1,46 @@
+package models
+
+import "strings"
+
+// AdminPermission grants access to every file regardless of ownership
+const AdminPermission = "/document/admin"
+
+// groupPermissionPrefix marks token permissions that name a group membership
+const groupPermissionPrefix = "group:"
+
+// Actor struct is the caller of a request as read from the JWT
+type Actor struct {
+	ID          int64
+	Origin      string
+	TenantID    string
+	Permissions []string
+}
+
+// SystemActor is used by scheduled jobs and event consumers
+// and may reach the files of every tenant
+var SystemActor = Actor{
+	Origin:      "system",
+	TenantID:    "*",
+	Permissions: []string{AdminPermission},
+}
+
+// IsAdmin checks if actor overrides file access rules
+func (a Actor) IsAdmin() bool {
+	for _, permission := range a.Permissions {
+		if permission == AdminPermission {
+			return true
+		}
+	}
+	return false
+}
+
+// Groups returns the groups the actor belongs to
+func (a Actor) Groups() []string {
+	groups := []string{}
+	for _, permission := range a.Permissions {
+		if strings.HasPrefix(permission, groupPermissionPrefix) {
+			groups = append(groups, strings.TrimPrefix(permission, groupPermissionPrefix))
+		}
+	}
+	return groups
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Checksum verification results
+const (
+	ChecksumOK       = "ok"
+	ChecksumMismatch = "mismatch"
+	ChecksumMissing  = "missing"
+	ChecksumUnhashed = "unhashed"
+)
+
+// FileRecord struct is a file as seen by operators, with its tenant and creation time
+type FileRecord struct {
+	File
+	TenantID   string     `json:"tenantId"`
+	CreatedOn  time.Time  `json:"createdOn"`
+	VerifiedOn *time.Time `json:"verifiedOn,omitempty"`
+}
+
+// NewFileRecord returns the record of a file
+func NewFileRecord(file File) FileRecord {
+	record := FileRecord{File: file, TenantID: file.TenantID, CreatedOn: file.CreatedOn}
+	if !file.VerifiedOn.IsZero() {
+		record.VerifiedOn = &file.VerifiedOn
+	}
+	return record
+}
+
+// FileQuery struct selects files across tenants for the admin commands, in
+// order of id from AfterID
+type FileQuery struct {
+	TenantID string
+	Status   string
+	Problem  string
+	Before   time.Time
+	AfterID  string
+	Limit    int
+}
+
+// ValidatePurge check if the query is a valid purge policy, a purge needs a
+// status and an age so it never selects every file
+func (q *FileQuery) ValidatePurge() error {
+	if q.Status == "" {
+		return errors.New("required Status")
+	}
+	if q.Before.IsZero() {
+		return errors.New("required age")
+	}
+	if q.Before.After(time.Now()) {
+		return errors.New("invalid age")
+	}
+	return nil
+}
+
+// FileInspection struct is everything recorded about a file
+type FileInspection struct {
+	File       FileRecord   `json:"file"`
+	Path       string       `json:"path,omitempty"`
+	Stored     bool         `json:"stored"`
+	StoredSize int64        `json:"storedSize,omitempty"`
+	Grants     []Grant      `json:"grants"`
+	Renditions []Rendition  `json:"renditions"`
+	Entries    []Entry      `json:"entries"`
+	Jobs       []Job        `json:"jobs"`
+	Audit      []AuditEvent `json:"audit"`
+}
+
+// ChecksumResult struct compares the stored content of a file with its checksum
+type ChecksumResult struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenantId"`
+	Status   string `json:"status"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RekeyResult struct counts the names moved to a new encryption key
+type RekeyResult struct {
+	Rekeyed   int      `json:"rekeyed"`
+	Skipped   int      `json:"skipped"`
+	Failed    []string `json:"failed,omitempty"`
+	Reindexed int      `json:"reindexed"`
+}
+
+// ExportRecord struct is a line of the manifest of an export, the content of
+// the file is stored at Content relative to the manifest
+type ExportRecord struct {
+	FileRecord
+	Content string  `json:"content"`
+	Grants  []Grant `json:"grants,omitempty"`
+}
+
+// TransferResult struct counts the files of an export or import
+type TransferResult struct {
+	Files   int      `json:"files"`
+	Skipped int      `json:"skipped"`
+	Failed  []string `json:"failed,omitempty"`
+}
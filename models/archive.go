@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ArchiveRequest struct selects the files of a bulk download by id or by refId
+type ArchiveRequest struct {
+	IDs   []string `json:"ids,omitempty"`
+	RefID string   `json:"refId,omitempty"`
+}
+
+// ValidateArchive check if request is valid
+func (a *ArchiveRequest) ValidateArchive() error {
+	if len(a.IDs) == 0 && a.RefID == "" {
+		return errors.New("required ids or refId")
+	}
+	if len(a.IDs) > 0 && a.RefID != "" {
+		return errors.New("ids and refId cannot be combined")
+	}
+	return nil
+}
+
+// ManifestFile struct describes a file written to a bulk download
+type ManifestFile struct {
+	ID       string `json:"id"`
+	RefID    string `json:"refId,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Path     string `json:"path,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Manifest struct is the manifest.json closing a bulk download
+type Manifest struct {
+	CreatedOn time.Time      `json:"createdOn"`
+	RefID     string         `json:"refId,omitempty"`
+	Files     []ManifestFile `json:"files"`
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditUpload   = "upload"
+	AuditView     = "view"
+	AuditDownload = "download"
+	AuditApprove  = "approve"
+	AuditDelete   = "delete"
+	AuditGrant    = "grant"
+	AuditRevoke   = "revoke"
+	AuditCorrupt  = "corrupt"
+	AuditRestore  = "restore"
+)
+
+// AuditGenesis is the previous hash of the first event of a tenant
+var AuditGenesis = strings.Repeat("0", 64)
+
+// maxAuditProblems bounds the problems a verification reports
+const maxAuditProblems = 100
+
+// AuditEvent struct is an entry of the audit log of a tenant. Every event holds
+// the hash of the one before it so changed, removed or reordered events break
+// the chain.
+type AuditEvent struct {
+	ID        string            `json:"id"`
+	TenantID  string            `json:"tenantId"`
+	Seq       int64             `json:"seq"`
+	FileID    string            `json:"fileId,omitempty"`
+	ActorID   int64             `json:"actorId"`
+	Origin    string            `json:"origin,omitempty"`
+	Action    string            `json:"action"`
+	RequestID string            `json:"requestId,omitempty"`
+	Detail    map[string]string `json:"detail,omitempty"`
+	CreatedOn time.Time         `json:"createdOn"`
+	PrevHash  string            `json:"prevHash"`
+	Hash      string            `json:"hash"`
+}
+
+// ComputeHash returns the SHA-256 of the previous hash and the fields of the event.
+// The fields are encoded as a JSON array so no two events share an input, times
+// are hashed in UTC at the microsecond precision of the database.
+func (e AuditEvent) ComputeHash() string {
+	detail := e.Detail
+	if detail == nil {
+		detail = map[string]string{}
+	}
+	input, _ := json.Marshal([]interface{}{
+		e.PrevHash, e.TenantID, e.Seq, e.FileID, e.ActorID, e.Origin, e.Action, e.RequestID, detail,
+		e.CreatedOn.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(input)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditQuery struct holds the filters of an audit log query
+type AuditQuery struct {
+	FileID  string
+	ActorID int64
+	Action  string
+	From    time.Time
+	To      time.Time
+	Limit   int
+	Offset  int
+}
+
+// ValidateAudit check if request is valid
+func (q *AuditQuery) ValidateAudit() error {
+	if q.Limit < 0 || q.Offset < 0 {
+		return errors.New("invalid pagination")
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return errors.New("invalid date range")
+	}
+	return nil
+}
+
+// AuditPage struct is a page of audit events, newest first
+type AuditPage struct {
+	Events []AuditEvent `json:"events"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
+
+// AuditProblem struct is a break found in the chain of a tenant
+type AuditProblem struct {
+	Seq     int64  `json:"seq"`
+	Problem string `json:"problem"`
+}
+
+// AuditVerification struct is the result of checking the chain of a tenant.
+// Events removed from the end cannot be told from events never written, the
+// head is reported so it can be kept elsewhere and compared later.
+type AuditVerification struct {
+	TenantID string         `json:"tenantId"`
+	Events   int64          `json:"events"`
+	HeadSeq  int64          `json:"headSeq"`
+	HeadHash string         `json:"headHash"`
+	Valid    bool           `json:"valid"`
+	Problems []AuditProblem `json:"problems,omitempty"`
+}
+
+// NewAuditVerification starts the verification of the chain of a tenant
+func NewAuditVerification(tenantID string) AuditVerification {
+	return AuditVerification{TenantID: tenantID, HeadHash: AuditGenesis, Valid: true}
+}
+
+// Check verifies the next event of the chain, events must be passed in order of seq
+func (v *AuditVerification) Check(event AuditEvent) {
+	switch {
+	case event.Seq > v.HeadSeq+1:
+		v.problem(event.Seq, fmt.Sprintf("gap, events %d to %d are missing", v.HeadSeq+1, event.Seq-1))
+	case event.Seq <= v.HeadSeq:
+		v.problem(event.Seq, "event is out of order")
+	}
+	if event.PrevHash != v.HeadHash {
+		v.problem(event.Seq, "previous hash does not match the event before it")
+	}
+	if event.ComputeHash() != event.Hash {
+		v.problem(event.Seq, "hash does not match the content of the event")
+	}
+	v.Events++
+	v.HeadSeq = event.Seq
+	v.HeadHash = event.Hash
+}
+
+// problem records a break in the chain
+func (v *AuditVerification) problem(seq int64, problem string) {
+	v.Valid = false
+	if len(v.Problems) < maxAuditProblems {
+		v.Problems = append(v.Problems, AuditProblem{Seq: seq, Problem: problem})
+	}
+}
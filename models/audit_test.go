@@ -0,0 +1,213 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// auditChain builds count events of a tenant chained from the genesis hash
+func auditChain(count int) []AuditEvent {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 123456000, time.UTC)
+	events := make([]AuditEvent, count)
+	prevHash := AuditGenesis
+	for i := range events {
+		events[i] = AuditEvent{
+			ID:        "event",
+			TenantID:  "tenant",
+			Seq:       int64(i + 1),
+			FileID:    "file",
+			ActorID:   7,
+			Origin:    "test",
+			Action:    AuditView,
+			RequestID: "request",
+			Detail:    map[string]string{"filename": "report.pdf"},
+			CreatedOn: created.Add(time.Duration(i) * time.Second),
+			PrevHash:  prevHash,
+		}
+		events[i].Hash = events[i].ComputeHash()
+		prevHash = events[i].Hash
+	}
+	return events
+}
+
+// verify checks the events in the order given
+func verify(events []AuditEvent) AuditVerification {
+	verification := NewAuditVerification("tenant")
+	for _, event := range events {
+		verification.Check(event)
+	}
+	return verification
+}
+
+func TestAuditVerificationValid(t *testing.T) {
+	events := auditChain(3)
+	got := verify(events)
+	if !got.Valid || len(got.Problems) != 0 {
+		t.Fatalf("verification = %+v, want valid", got)
+	}
+	if got.Events != 3 || got.HeadSeq != 3 || got.HeadHash != events[2].Hash {
+		t.Fatalf("head = %d events seq %d %s, want 3 events seq 3 %s", got.Events, got.HeadSeq, got.HeadHash, events[2].Hash)
+	}
+
+	empty := NewAuditVerification("tenant")
+	if !empty.Valid || empty.HeadSeq != 0 || empty.HeadHash != AuditGenesis {
+		t.Fatalf("empty verification = %+v", empty)
+	}
+}
+
+func TestAuditVerificationEditedField(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(event *AuditEvent)
+		problems int
+	}{
+		{"tenant", func(event *AuditEvent) { event.TenantID = "other" }, 1},
+		{"file", func(event *AuditEvent) { event.FileID = "other" }, 1},
+		{"actor", func(event *AuditEvent) { event.ActorID = 8 }, 1},
+		{"origin", func(event *AuditEvent) { event.Origin = "other" }, 1},
+		{"action", func(event *AuditEvent) { event.Action = AuditDelete }, 1},
+		{"request", func(event *AuditEvent) { event.RequestID = "other" }, 1},
+		{"detail", func(event *AuditEvent) { event.Detail["filename"] = "other.pdf" }, 1},
+		{"detail removed", func(event *AuditEvent) { event.Detail = nil }, 1},
+		{"created", func(event *AuditEvent) { event.CreatedOn = event.CreatedOn.Add(time.Microsecond) }, 1},
+		{"fields shifted", func(event *AuditEvent) { event.FileID, event.Origin = "", event.FileID+event.Origin }, 1},
+		// an edited seq also breaks the order, at the event and the one after it
+		{"seq", func(event *AuditEvent) { event.Seq = 5 }, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := auditChain(3)
+			tt.edit(&events[1])
+			got := verify(events)
+			want := AuditProblem{Seq: events[1].Seq, Problem: "hash does not match the content of the event"}
+			if got.Valid || !containsProblem(got.Problems, want) || len(got.Problems) != tt.problems {
+				t.Fatalf("problems = %+v, want %d with %+v", got.Problems, tt.problems, want)
+			}
+		})
+	}
+}
+
+func TestAuditVerificationRemovedEvent(t *testing.T) {
+	events := auditChain(4)
+	got := verify(append(events[:1:1], events[2:]...))
+	want := []AuditProblem{
+		{Seq: 3, Problem: "gap, events 2 to 2 are missing"},
+		{Seq: 3, Problem: "previous hash does not match the event before it"},
+	}
+	if got.Valid || !reflect.DeepEqual(got.Problems, want) {
+		t.Fatalf("problems = %+v, want %+v", got.Problems, want)
+	}
+	if got.Events != 3 || got.HeadSeq != 4 {
+		t.Fatalf("head = %d events seq %d, want 3 events seq 4", got.Events, got.HeadSeq)
+	}
+}
+
+func TestAuditVerificationReordered(t *testing.T) {
+	events := auditChain(3)
+	got := verify([]AuditEvent{events[0], events[2], events[1]})
+	want := []AuditProblem{
+		{Seq: 3, Problem: "gap, events 2 to 2 are missing"},
+		{Seq: 3, Problem: "previous hash does not match the event before it"},
+		{Seq: 2, Problem: "event is out of order"},
+		{Seq: 2, Problem: "previous hash does not match the event before it"},
+	}
+	if got.Valid || !reflect.DeepEqual(got.Problems, want) {
+		t.Fatalf("problems = %+v, want %+v", got.Problems, want)
+	}
+}
+
+func TestAuditVerificationWrongPrevHash(t *testing.T) {
+	tests := []struct {
+		name   string
+		rehash bool
+		want   []AuditProblem
+	}{
+		{
+			name: "prevHash edited",
+			want: []AuditProblem{
+				{Seq: 2, Problem: "previous hash does not match the event before it"},
+				{Seq: 2, Problem: "hash does not match the content of the event"},
+			},
+		},
+		{
+			// an event forged with a consistent hash still breaks the link
+			name:   "event rehashed",
+			rehash: true,
+			want: []AuditProblem{
+				{Seq: 2, Problem: "previous hash does not match the event before it"},
+				{Seq: 3, Problem: "previous hash does not match the event before it"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := auditChain(3)
+			events[1].PrevHash = AuditGenesis
+			if tt.rehash {
+				events[1].Hash = events[1].ComputeHash()
+			}
+			got := verify(events)
+			if got.Valid || !reflect.DeepEqual(got.Problems, tt.want) {
+				t.Fatalf("problems = %+v, want %+v", got.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditVerificationProblemsBounded(t *testing.T) {
+	events := auditChain(maxAuditProblems + 10)
+	for i := range events {
+		events[i].Action = AuditDelete
+	}
+	got := verify(events)
+	if got.Valid || len(got.Problems) != maxAuditProblems || got.Events != int64(len(events)) {
+		t.Fatalf("%d problems over %d events, want %d over %d", len(got.Problems), got.Events, maxAuditProblems, len(events))
+	}
+}
+
+func TestAuditComputeHashTime(t *testing.T) {
+	event := auditChain(1)[0]
+	event.CreatedOn = time.Date(2024, 3, 1, 9, 30, 0, 123456789, time.UTC)
+	hash := event.ComputeHash()
+
+	// the database keeps microseconds, so an event read back hashes the same
+	stored := event
+	stored.CreatedOn = event.CreatedOn.Truncate(time.Microsecond)
+	if stored.ComputeHash() != hash {
+		t.Fatal("hash changed when the time lost its nanoseconds")
+	}
+	zoned := event
+	zoned.CreatedOn = event.CreatedOn.In(time.FixedZone("EAT", 3*60*60))
+	if zoned.ComputeHash() != hash {
+		t.Fatal("hash changed with the time zone")
+	}
+	later := event
+	later.CreatedOn = event.CreatedOn.Add(time.Microsecond)
+	if later.ComputeHash() == hash {
+		t.Fatal("hash ignored a microsecond")
+	}
+}
+
+func TestAuditComputeHashDetail(t *testing.T) {
+	event := auditChain(1)[0]
+	event.Detail = nil
+	empty := event
+	empty.Detail = map[string]string{}
+	if event.ComputeHash() != empty.ComputeHash() {
+		t.Fatal("nil and empty detail hash differently")
+	}
+	if len(event.ComputeHash()) != 64 || event.ComputeHash() != event.ComputeHash() {
+		t.Fatalf("ComputeHash() = %q, want a stable SHA-256", event.ComputeHash())
+	}
+}
+
+// containsProblem checks the problems hold want
+func containsProblem(problems []AuditProblem, want AuditProblem) bool {
+	for _, problem := range problems {
+		if problem == want {
+			return true
+		}
+	}
+	return false
+}
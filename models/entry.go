@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// Entry struct is a file or folder of an uploaded archive
+type Entry struct {
+	ID             string    `json:"id,omitempty"`
+	FileID         string    `json:"fileId,omitempty"`
+	Position       int       `json:"position"`
+	Name           string    `json:"name,omitempty"`
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressedSize,omitempty"`
+	ModifiedOn     time.Time `json:"modifiedOn,omitempty"`
+	Directory      bool      `json:"directory,omitempty"`
+	ChildID        string    `json:"childId,omitempty"`
+}
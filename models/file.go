@@ -8,13 +8,25 @@ import (
 
 // File struct
 type File struct {
-	ID        string    `json:"id,omitempty"`
-	RefID     string    `json:"refId,omitempty"`
-	Name      string    `json:"name,omitempty"`
-	Extension string    `json:"extension,omitempty"`
-	Size      int64     `json:"size,omitempty"`
-	Status    string    `json:"status,omitempty"`
-	CreatedOn time.Time `json:"-"`
+	ID           string            `json:"id,omitempty"`
+	RefID        string            `json:"refId,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	OriginalName string            `json:"originalName,omitempty"`
+	Extension    string            `json:"extension,omitempty"`
+	Size         int64             `json:"size,omitempty"`
+	MimeType     string            `json:"mimeType,omitempty"`
+	Status       string            `json:"status,omitempty"`
+	ActorID      int64             `json:"actorId,omitempty"`
+	Origin       string            `json:"origin,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	ParentID     string            `json:"parentId,omitempty"`
+	Stages       map[string]string `json:"stages,omitempty"`
+	Checksum     string            `json:"checksum,omitempty"`
+	Problem      string            `json:"problem,omitempty"`
+	Ready        bool              `json:"ready"`
+	TenantID     string            `json:"-"`
+	CreatedOn    time.Time         `json:"-"`
+	VerifiedOn   time.Time         `json:"-"`
 }
 
 // ValidateFile check if request is valid
@@ -49,4 +61,12 @@ func (f *File) PrepareFileOutput(file File) {
 	f.ID = file.ID
 	f.Status = file.Status
 	f.Name = file.Name
+	f.OriginalName = file.OriginalName
+	f.ActorID = file.ActorID
+	f.Metadata = file.Metadata
+	f.ParentID = file.ParentID
+	f.Stages = file.Stages
+	f.Checksum = file.Checksum
+	f.Problem = file.Problem
+	f.Ready = file.Ready
 }
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Grant permissions
+const (
+	GrantRead   = "read"
+	GrantWrite  = "write"
+	GrantDelete = "delete"
+	GrantShare  = "share"
+)
+
+// Grantee types
+const (
+	GranteeActor = "actor"
+	GranteeGroup = "group"
+)
+
+// Grant struct gives an actor or group a permission on a file
+type Grant struct {
+	ID          string    `json:"id,omitempty"`
+	FileID      string    `json:"fileId,omitempty"`
+	GranteeType string    `json:"granteeType,omitempty"`
+	GranteeID   string    `json:"granteeId,omitempty"`
+	Permission  string    `json:"permission,omitempty"`
+	GrantedBy   int64     `json:"grantedBy,omitempty"`
+	CreatedOn   time.Time `json:"createdOn,omitempty"`
+}
+
+// ValidateGrant check if request is valid
+func (g *Grant) ValidateGrant(action string) error {
+	switch strings.ToLower(action) {
+	case "add":
+		if g.FileID == "" {
+			return errors.New("required FileID")
+		}
+		if g.GranteeType != GranteeActor && g.GranteeType != GranteeGroup {
+			return errors.New("invalid GranteeType")
+		}
+		if g.GranteeID == "" {
+			return errors.New("required GranteeID")
+		}
+		switch g.Permission {
+		case GrantRead, GrantWrite, GrantDelete, GrantShare:
+			return nil
+		default:
+			return errors.New("invalid Permission")
+		}
+	case "delete":
+		if g.FileID == "" {
+			return errors.New("required FileID")
+		}
+		if g.ID == "" {
+			return errors.New("required ID")
+		}
+		return nil
+	default:
+		return errors.New("invalid validation operation")
+	}
+}
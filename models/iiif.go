@@ -0,0 +1,39 @@
+package models
+
+// IIIF Image API 3.0 identifiers
+const (
+	IIIFContext  = "http://iiif.io/api/image/3/context.json"
+	IIIFProtocol = "http://iiif.io/api/image"
+	IIIFProfile  = "level2"
+)
+
+// IIIFTile struct describes the tiles a viewer may request
+type IIIFTile struct {
+	Width        int   `json:"width"`
+	ScaleFactors []int `json:"scaleFactors"`
+}
+
+// IIIFSize struct is a size of the full image a viewer may request
+type IIIFSize struct {
+	Type   string `json:"type"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// IIIFInfo struct is the info.json of an image
+type IIIFInfo struct {
+	Context        string     `json:"@context"`
+	ID             string     `json:"id"`
+	Type           string     `json:"type"`
+	Protocol       string     `json:"protocol"`
+	Profile        string     `json:"profile"`
+	Width          int        `json:"width"`
+	Height         int        `json:"height"`
+	MaxWidth       int        `json:"maxWidth"`
+	MaxHeight      int        `json:"maxHeight"`
+	Sizes          []IIIFSize `json:"sizes"`
+	Tiles          []IIIFTile `json:"tiles"`
+	ExtraQualities []string   `json:"extraQualities"`
+	ExtraFormats   []string   `json:"extraFormats"`
+	ExtraFeatures  []string   `json:"extraFeatures"`
+}
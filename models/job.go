@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Stage statuses recorded on a file for each background job
+const (
+	StageQueued  = "queued"
+	StageRunning = "running"
+	StageDone    = "done"
+	StageFailed  = "failed"
+)
+
+// Job struct is a unit of background processing of a file
+type Job struct {
+	ID          string            `json:"id"`
+	FileID      string            `json:"fileId"`
+	TenantID    string            `json:"-"`
+	Type        string            `json:"type"`
+	Payload     map[string]string `json:"-"`
+	Status      string            `json:"status"`
+	Attempts    int               `json:"attempts"`
+	MaxAttempts int               `json:"maxAttempts"`
+	RunAt       time.Time         `json:"runAt"`
+	LastError   string            `json:"lastError,omitempty"`
+	CreatedOn   time.Time         `json:"createdOn,omitempty"`
+	UpdatedOn   time.Time         `json:"updatedOn,omitempty"`
+}
+
+// JobStats struct counts the queued or running jobs of a type
+type JobStats struct {
+	Type          string
+	Status        string
+	Count         int64
+	OldestSeconds float64
+}
+
+// Processing struct is the state of the background processing of a file
+type Processing struct {
+	FileID   string `json:"fileId"`
+	Ready    bool   `json:"ready"`
+	Finished bool   `json:"finished"`
+	Version  string `json:"version"`
+	Stages   []Job  `json:"stages"`
+}
+
+// StagesReady checks if every stage of a file is done, files without stages were
+// stored before processing moved to the background and are ready
+func StagesReady(stages map[string]string) bool {
+	for _, status := range stages {
+		if status != StageDone {
+			return false
+		}
+	}
+	return true
+}
+
+// StagesFinished checks if no stage of a file is still to run
+func StagesFinished(stages map[string]string) bool {
+	for _, status := range stages {
+		if status == StageQueued || status == StageRunning {
+			return false
+		}
+	}
+	return true
+}
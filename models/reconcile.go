@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Problems found by reconciling storage with the files table
+const (
+	ProblemMissing          = "missing"
+	ProblemSize             = "size_mismatch"
+	ProblemHash             = "hash_mismatch"
+	ProblemMisplaced        = "misplaced"
+	ProblemOrphan           = "orphan"
+	ProblemOrphanRenditions = "orphan_renditions"
+)
+
+// Repairs made by a reconcile, a marked record keeps its problem until its
+// content is restored
+const (
+	RepairDeleted = "deleted"
+	RepairMoved   = "moved"
+	RepairMarked  = "marked"
+)
+
+// ReconcileOptions struct selects what a reconcile checks and repairs. Orphans
+// younger than Grace are reported but kept, an upload may not be recorded yet.
+type ReconcileOptions struct {
+	Hash   bool
+	Repair bool
+	Grace  time.Duration
+}
+
+// ReconcileProblem struct is a file or stored content that do not agree
+type ReconcileProblem struct {
+	Problem    string     `json:"problem"`
+	FileID     string     `json:"fileId,omitempty"`
+	TenantID   string     `json:"tenantId,omitempty"`
+	Path       string     `json:"path,omitempty"`
+	Expected   string     `json:"expected,omitempty"`
+	Actual     string     `json:"actual,omitempty"`
+	ModifiedOn *time.Time `json:"modifiedOn,omitempty"`
+	Repair     string     `json:"repair,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ReconcileReport struct counts what a reconcile went through and found
+type ReconcileReport struct {
+	Files      int            `json:"files"`
+	Blobs      int            `json:"blobs"`
+	Renditions int            `json:"renditions"`
+	Problems   map[string]int `json:"problems"`
+	Repaired   int            `json:"repaired"`
+	Marked     int            `json:"marked"`
+	Cleared    int            `json:"cleared"`
+	DurationMs int64          `json:"durationMs"`
+}
+
+// Found returns how many problems were found
+func (r ReconcileReport) Found() int {
+	count := 0
+	for _, found := range r.Problems {
+		count += found
+	}
+	return count
+}
+
+// Unrepaired returns how many problems were not fixed, marked records included
+func (r ReconcileReport) Unrepaired() int {
+	return r.Found() - r.Repaired
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Rendition struct is a resized copy of an image file
+type Rendition struct {
+	ID        string    `json:"id,omitempty"`
+	FileID    string    `json:"fileId,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	MimeType  string    `json:"mimeType,omitempty"`
+	Width     int       `json:"width,omitempty"`
+	Height    int       `json:"height,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	CreatedOn time.Time `json:"createdOn,omitempty"`
+}
+
+// TransformSignature struct is a signed set of transform options, Query is
+// the query of /document/file/{id}/transform serving them
+type TransformSignature struct {
+	FileID    string `json:"fileId"`
+	Canonical string `json:"canonical"`
+	Signature string `json:"signature"`
+	Query     string `json:"query"`
+}
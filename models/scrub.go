@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Results of scrubbing a file
+const (
+	ScrubOK       = "ok"
+	ScrubCorrupt  = "corrupt"
+	ScrubMissing  = "missing"
+	ScrubRestored = "restored"
+)
+
+// ScrubOptions struct paces the scrubber, files are verified again once
+// Interval passed and content is read at BytesPerSecond at most
+type ScrubOptions struct {
+	Interval       time.Duration
+	BytesPerSecond int64
+}
+
+// ScrubReport struct counts what a scrub went through
+type ScrubReport struct {
+	Files    int   `json:"files"`
+	Bytes    int64 `json:"bytes"`
+	Corrupt  int   `json:"corrupt"`
+	Missing  int   `json:"missing"`
+	Restored int   `json:"restored"`
+}
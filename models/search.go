@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// SearchQuery struct holds the text and filters of a search
+type SearchQuery struct {
+	Query     string
+	Language  string
+	Status    string
+	Extension string
+	MimeType  string
+	RefID     string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// ValidateSearch check if request is valid
+func (q *SearchQuery) ValidateSearch() error {
+	if q.Query == "" {
+		return errors.New("required q")
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return errors.New("invalid pagination")
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return errors.New("invalid date range")
+	}
+	return nil
+}
+
+// SearchResult struct is a ranked file with highlighted snippets
+type SearchResult struct {
+	File    File    `json:"file"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// SearchPage struct is a page of search results
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	Total   int64          `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+}
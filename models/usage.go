@@ -0,0 +1,57 @@
+package models
+
+// Usage scopes
+const (
+	UsageTenant = "tenant"
+	UsageActor  = "actor"
+)
+
+// Quota struct limits the bytes and files held by a tenant or actor, zero is unlimited
+type Quota struct {
+	SoftBytes int64 `json:"softBytes,omitempty"`
+	HardBytes int64 `json:"hardBytes,omitempty"`
+	SoftFiles int64 `json:"softFiles,omitempty"`
+	HardFiles int64 `json:"hardFiles,omitempty"`
+}
+
+// Usage struct is the storage held by a tenant or actor
+type Usage struct {
+	Scope        string `json:"scope,omitempty"`
+	TenantID     string `json:"tenantId,omitempty"`
+	ActorID      int64  `json:"actorId,omitempty"`
+	Bytes        int64  `json:"bytes"`
+	Files        int64  `json:"files"`
+	Quota        Quota  `json:"quota"`
+	SoftExceeded bool   `json:"softExceeded"`
+	HardExceeded bool   `json:"hardExceeded"`
+}
+
+// ApplyQuota sets the quota and flags the limits the usage is over
+func (u *Usage) ApplyQuota(quota Quota) {
+	u.Quota = quota
+	u.SoftExceeded = over(u.Bytes, quota.SoftBytes) || over(u.Files, quota.SoftFiles)
+	u.HardExceeded = over(u.Bytes, quota.HardBytes) || over(u.Files, quota.HardFiles)
+}
+
+// RemainingBytes returns how many bytes fit under the hard quota, -1 is unlimited
+func (u *Usage) RemainingBytes() int64 {
+	if u.Quota.HardBytes == 0 {
+		return -1
+	}
+	if u.Bytes >= u.Quota.HardBytes {
+		return 0
+	}
+	return u.Quota.HardBytes - u.Bytes
+}
+
+// over checks if value is beyond a limit, zero is unlimited
+func over(value int64, limit int64) bool {
+	return limit > 0 && value > limit
+}
+
+// UsageReport struct is the response of the usage endpoint
+type UsageReport struct {
+	Tenant Usage   `json:"tenant"`
+	Actor  Usage   `json:"actor"`
+	Actors []Usage `json:"actors,omitempty"`
+}
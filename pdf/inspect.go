@@ -0,0 +1,237 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// maxInflatedBytes bounds the data inflated from object streams of one document
+const maxInflatedBytes = 32 << 20
+
+// errNotPDF is returned for content without a PDF header
+var errNotPDF = errors.New("not a pdf document")
+
+var (
+	headerPattern  = regexp.MustCompile(`%PDF-(\d\.\d)`)
+	objectPattern  = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	namePattern    = regexp.MustCompile(`/[^\s/<>\[\]()%{}]+`)
+	escapePattern  = regexp.MustCompile(`#([0-9A-Fa-f]{2})`)
+	infoPattern    = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+	encryptPattern = regexp.MustCompile(`/Encrypt\s*(\d+\s+\d+\s+R|<<)`)
+	xrefPattern    = regexp.MustCompile(`/Type\s*/XRef\b`)
+	jsPattern      = regexp.MustCompile(`/(JavaScript|JS)\b`)
+	embedPattern   = regexp.MustCompile(`/EmbeddedFiles?\b`)
+	launchPattern  = regexp.MustCompile(`/Launch\b`)
+	pagesPattern   = regexp.MustCompile(`/Type\s*/Pages\b`)
+	pagePattern    = regexp.MustCompile(`/Type\s*/Page\b`)
+	parentPattern  = regexp.MustCompile(`/Parent\s`)
+	countPattern   = regexp.MustCompile(`/Count\s+(\d+)`)
+	integerPattern = regexp.MustCompile(`/(N|First|Length)\s+(\d+)`)
+)
+
+// Report struct is what the inspector found in a document
+type Report struct {
+	Version       string
+	Pages         int
+	Title         string
+	Author        string
+	CreatedOn     time.Time
+	ModifiedOn    time.Time
+	Encrypted     bool
+	JavaScript    bool
+	EmbeddedFiles bool
+	Launch        bool
+}
+
+// Inspect reads the structure of a PDF without rendering it. Objects held in
+// compressed object streams are read too so nothing is hidden from the checks.
+func Inspect(data []byte) (Report, error) {
+	report := Report{}
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	match := headerPattern.FindSubmatch(head)
+	if match == nil {
+		return report, errNotPDF
+	}
+	report.Version = string(match[1])
+	objects := parseObjects(data)
+	trailer := trailers(data, objects)
+	report.Encrypted = encryptPattern.Match(trailer)
+	if !report.Encrypted {
+		budget := maxInflatedBytes
+		for _, body := range objects {
+			for number, object := range objectStream(body, &budget) {
+				if _, found := objects[number]; !found {
+					objects[number] = object
+				}
+			}
+		}
+	}
+
+	pages := 0
+	for _, body := range objects {
+		names := normaliseNames(dictionary(body))
+		report.JavaScript = report.JavaScript || jsPattern.Match(names)
+		report.EmbeddedFiles = report.EmbeddedFiles || embedPattern.Match(names)
+		report.Launch = report.Launch || launchPattern.Match(names)
+
+		switch {
+		case pagesPattern.Match(names) && !parentPattern.Match(names):
+			if count := countPattern.FindSubmatch(names); count != nil {
+				if value, err := strconv.Atoi(string(count[1])); err == nil && value > report.Pages {
+					report.Pages = value
+				}
+			}
+		case pagePattern.Match(names):
+			pages++
+		}
+	}
+	if report.Pages == 0 {
+		report.Pages = pages
+	}
+
+	if info := infoPattern.FindAllSubmatch(trailer, -1); len(info) > 0 && !report.Encrypted {
+		number, _ := strconv.Atoi(string(info[len(info)-1][1]))
+		if body, found := objects[number]; found {
+			report.Title = stringValue(body, "/Title")
+			report.Author = stringValue(body, "/Author")
+			report.CreatedOn = parseDate(stringValue(body, "/CreationDate"))
+			report.ModifiedOn = parseDate(stringValue(body, "/ModDate"))
+		}
+	}
+	return report, nil
+}
+
+// trailers returns the trailer dictionaries, or the dictionaries of the
+// cross reference streams that replace them since PDF 1.5
+func trailers(data []byte, objects map[int][]byte) []byte {
+	var out []byte
+	for offset := 0; ; {
+		index := bytes.Index(data[offset:], []byte("trailer"))
+		if index < 0 {
+			break
+		}
+		offset += index + len("trailer")
+		end := offset + 4096
+		if end > len(data) {
+			end = len(data)
+		}
+		out = append(out, data[offset:end]...)
+	}
+	for _, body := range objects {
+		if dict := dictionary(body); xrefPattern.Match(dict) {
+			out = append(out, dict...)
+		}
+	}
+	return out
+}
+
+// parseObjects maps the numbers of the uncompressed objects to their bodies,
+// later definitions of a number replace earlier ones as incremental updates do
+func parseObjects(data []byte) map[int][]byte {
+	objects := map[int][]byte{}
+	matches := objectPattern.FindAllSubmatchIndex(data, -1)
+	for i, match := range matches {
+		end := len(data)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		body := data[match[1]:end]
+		if index := bytes.Index(body, []byte("endobj")); index >= 0 {
+			body = body[:index]
+		}
+		number, err := strconv.Atoi(string(data[match[2]:match[3]]))
+		if err == nil {
+			objects[number] = body
+		}
+	}
+	return objects
+}
+
+// dictionary returns the part of an object before its stream data
+func dictionary(body []byte) []byte {
+	if index := bytes.Index(body, []byte("stream")); index >= 0 {
+		return body[:index]
+	}
+	return body
+}
+
+// streamData returns the raw data of a stream object
+func streamData(body []byte) []byte {
+	index := bytes.Index(body, []byte("stream"))
+	if index < 0 {
+		return nil
+	}
+	data := body[index+len("stream"):]
+	data = bytes.TrimLeft(data, "\r")
+	data = bytes.TrimPrefix(data, []byte("\n"))
+	if end := bytes.LastIndex(data, []byte("endstream")); end >= 0 {
+		data = data[:end]
+	}
+	return data
+}
+
+// objectStream inflates an object stream and maps the numbers of the objects it holds to their bodies
+func objectStream(body []byte, budget *int) map[int][]byte {
+	dict := dictionary(body)
+	if !bytes.Contains(dict, []byte("/ObjStm")) || !bytes.Contains(dict, []byte("/FlateDecode")) || *budget <= 0 {
+		return nil
+	}
+	values := map[string]int{}
+	for _, match := range integerPattern.FindAllSubmatch(dict, -1) {
+		values[string(match[1])], _ = strconv.Atoi(string(match[2]))
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(streamData(body)))
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	inflated, _ := io.ReadAll(io.LimitReader(reader, int64(*budget)))
+	*budget -= len(inflated)
+
+	first := values["First"]
+	if first <= 0 || first > len(inflated) {
+		return nil
+	}
+	header := bytes.Fields(inflated[:first])
+	objects := map[int][]byte{}
+	for i := 0; i+1 < len(header) && i/2 < values["N"]; i += 2 {
+		number, err := strconv.Atoi(string(header[i]))
+		if err != nil {
+			return objects
+		}
+		start, err := strconv.Atoi(string(header[i+1]))
+		if err != nil || first+start > len(inflated) {
+			return objects
+		}
+		end := len(inflated)
+		if i+3 < len(header) {
+			if next, err := strconv.Atoi(string(header[i+3])); err == nil && first+next <= len(inflated) && next >= start {
+				end = first + next
+			}
+		}
+		objects[number] = inflated[first+start : end]
+	}
+	return objects
+}
+
+// normaliseNames decodes #xx escapes in names so /J#61vaScript is seen as /JavaScript
+func normaliseNames(data []byte) []byte {
+	if !bytes.Contains(data, []byte("#")) {
+		return data
+	}
+	return namePattern.ReplaceAllFunc(data, func(name []byte) []byte {
+		return escapePattern.ReplaceAllFunc(name, func(escape []byte) []byte {
+			value, _ := strconv.ParseUint(string(escape[1:]), 16, 8)
+			return []byte{byte(value)}
+		})
+	})
+}
@@ -0,0 +1,164 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// document builds a PDF of the object bodies, numbered from 1, and the trailer
+func document(trailer string, objects ...string) []byte {
+	out := &bytes.Buffer{}
+	out.WriteString("%PDF-1.7\n")
+	for i, object := range objects {
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	fmt.Fprintf(out, "trailer\n%s\n%%%%EOF\n", trailer)
+	return out.Bytes()
+}
+
+// objectStreamOf builds a compressed object stream holding the objects from number first
+func objectStreamOf(first int, objects ...string) string {
+	header := &bytes.Buffer{}
+	body := &bytes.Buffer{}
+	for i, object := range objects {
+		fmt.Fprintf(header, "%d %d ", first+i, body.Len())
+		body.WriteString(object + "\n")
+	}
+	compressed := &bytes.Buffer{}
+	writer := zlib.NewWriter(compressed)
+	writer.Write(header.Bytes())
+	writer.Write(body.Bytes())
+	writer.Close()
+	return fmt.Sprintf("<< /Type /ObjStm /N %d /First %d /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+		len(objects), header.Len(), compressed.Len(), compressed.String())
+}
+
+// pages are the objects of a two page document, catalog first
+var pages = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+	"<< /Type /Page /Parent 2 0 R >>",
+	"<< /Type /Page /Parent 2 0 R >>",
+}
+
+// with returns the objects of the two page document followed by more
+func with(objects ...string) []string {
+	return append(append([]string{}, pages...), objects...)
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Report
+		wantErr bool
+	}{
+		{
+			name: "plain",
+			data: document("<< /Root 1 0 R /Info 5 0 R >>",
+				with("<< /Title (Annual report) /Author (Finance) /CreationDate (D:20230102030405Z) >>")...),
+			want: Report{Version: "1.7", Pages: 2, Title: "Annual report", Author: "Finance",
+				CreatedOn: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+		{
+			name: "javascript open action",
+			data: document("<< /Root 1 0 R >>",
+				"<< /Type /Catalog /Pages 2 0 R /OpenAction 5 0 R >>", pages[1], pages[2], pages[3],
+				"<< /S /JavaScript /JS (app.alert\\(1\\)) >>"),
+			want: Report{Version: "1.7", Pages: 2, JavaScript: true},
+		},
+		{
+			name: "javascript key only",
+			data: document("<< /Root 1 0 R >>", with("<< /JS 6 0 R >>")...),
+			want: Report{Version: "1.7", Pages: 2, JavaScript: true},
+		},
+		{
+			name: "javascript escaped name",
+			data: document("<< /Root 1 0 R >>", with("<< /S /J#61vaScript /J#53 (x) >>")...),
+			want: Report{Version: "1.7", Pages: 2, JavaScript: true},
+		},
+		{
+			name: "javascript in an object stream",
+			data: document("<< /Root 1 0 R >>", with(objectStreamOf(6, "<< /S /JavaScript /JS (x) >>"))...),
+			want: Report{Version: "1.7", Pages: 2, JavaScript: true},
+		},
+		{
+			name: "javascript named in stream data",
+			data: document("<< /Root 1 0 R >>", with("<< /Length 20 >>\nstream\n/JavaScript /Launch\nendstream")...),
+			want: Report{Version: "1.7", Pages: 2},
+		},
+		{
+			name: "launch action",
+			data: document("<< /Root 1 0 R >>", with("<< /S /Launch /F (cmd.exe) >>")...),
+			want: Report{Version: "1.7", Pages: 2, Launch: true},
+		},
+		{
+			name: "launch escaped name",
+			data: document("<< /Root 1 0 R >>", with("<< /S /L#61unch /F (cmd.exe) >>")...),
+			want: Report{Version: "1.7", Pages: 2, Launch: true},
+		},
+		{
+			name: "launch in an object stream",
+			data: document("<< /Root 1 0 R >>", with(objectStreamOf(6, "<< /Type /Page /Parent 2 0 R >>", "<< /S /Launch /F (cmd.exe) >>"))...),
+			want: Report{Version: "1.7", Pages: 2, Launch: true},
+		},
+		{
+			name: "embedded files",
+			data: document("<< /Root 1 0 R >>", with("<< /EmbeddedFiles 6 0 R >>")...),
+			want: Report{Version: "1.7", Pages: 2, EmbeddedFiles: true},
+		},
+		{
+			name: "encrypted",
+			data: document("<< /Root 1 0 R /Encrypt 5 0 R /Info 6 0 R >>",
+				with("<< /Filter /Standard /V 2 >>", "<< /Title (hidden) >>")...),
+			want: Report{Version: "1.7", Pages: 2, Encrypted: true},
+		},
+		{
+			name:    "not a pdf",
+			data:    []byte("PK\x03\x04 /JavaScript"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Inspect(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Inspect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Inspect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyRejected(t *testing.T) {
+	report := Report{JavaScript: true, Launch: true, EmbeddedFiles: true}
+	tests := []struct {
+		reject string
+		want   []string
+	}{
+		{"", []string{}},
+		{"javascript", []string{RiskJavaScript}},
+		{"javascript, launch", []string{RiskJavaScript, RiskLaunch}},
+		{"encrypted", []string{}},
+		{"launch,unknown", []string{RiskLaunch}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reject, func(t *testing.T) {
+			t.Setenv("PDF_REJECT", tt.reject)
+			got := LoadPolicy().Rejected(report)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("Rejected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package pdf
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Risks a policy may reject documents for
+const (
+	RiskEncrypted     = "encrypted"
+	RiskJavaScript    = "javascript"
+	RiskEmbeddedFiles = "embeddedFiles"
+	RiskLaunch        = "launch"
+)
+
+// Policy holds the risks documents are rejected for
+type Policy map[string]bool
+
+// LoadPolicy reads PDF_REJECT, a comma separated list of risks such as javascript,launch
+func LoadPolicy() Policy {
+	policy := Policy{}
+	for _, risk := range strings.Split(os.Getenv("PDF_REJECT"), ",") {
+		switch risk = strings.TrimSpace(risk); risk {
+		case RiskEncrypted, RiskJavaScript, RiskEmbeddedFiles, RiskLaunch:
+			policy[risk] = true
+		}
+	}
+	return policy
+}
+
+// Rejected returns the risks of the report the policy rejects
+func (p Policy) Rejected(report Report) []string {
+	rejected := []string{}
+	for _, risk := range report.Risks() {
+		if p[risk] {
+			rejected = append(rejected, risk)
+		}
+	}
+	return rejected
+}
+
+// Risks returns the risks found in the document
+func (r Report) Risks() []string {
+	risks := []string{}
+	if r.Encrypted {
+		risks = append(risks, RiskEncrypted)
+	}
+	if r.JavaScript {
+		risks = append(risks, RiskJavaScript)
+	}
+	if r.EmbeddedFiles {
+		risks = append(risks, RiskEmbeddedFiles)
+	}
+	if r.Launch {
+		risks = append(risks, RiskLaunch)
+	}
+	return risks
+}
+
+// Fields returns the report as document metadata
+func (r Report) Fields() map[string]string {
+	fields := map[string]string{
+		"pdfVersion":    r.Version,
+		"pages":         strconv.Itoa(r.Pages),
+		"encrypted":     strconv.FormatBool(r.Encrypted),
+		"javascript":    strconv.FormatBool(r.JavaScript),
+		"embeddedFiles": strconv.FormatBool(r.EmbeddedFiles),
+		"launch":        strconv.FormatBool(r.Launch),
+	}
+	if r.Title != "" {
+		fields["title"] = r.Title
+	}
+	if r.Author != "" {
+		fields["author"] = r.Author
+	}
+	if !r.CreatedOn.IsZero() {
+		fields["createdOn"] = r.CreatedOn.Format(time.RFC3339)
+	}
+	if !r.ModifiedOn.IsZero() {
+		fields["modifiedOn"] = r.ModifiedOn.Format(time.RFC3339)
+	}
+	return fields
+}
@@ -0,0 +1,151 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// maxStringBytes bounds the metadata strings kept from a document
+const maxStringBytes = 1024
+
+// datePattern matches D:YYYYMMDDHHmmSS with an optional zone, later parts may be missing
+var datePattern = regexp.MustCompile(`^D?:?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?([Zz+-])?(\d{2})?'?(\d{2})?`)
+
+// stringValue returns the literal or hex string of a key of a dictionary
+func stringValue(body []byte, key string) string {
+	index := bytes.Index(body, []byte(key))
+	if index < 0 {
+		return ""
+	}
+	value := bytes.TrimLeft(body[index+len(key):], " \t\r\n")
+	switch {
+	case bytes.HasPrefix(value, []byte("(")):
+		return decodeText(literalString(value[1:]))
+	case bytes.HasPrefix(value, []byte("<")) && !bytes.HasPrefix(value, []byte("<<")):
+		end := bytes.IndexByte(value, '>')
+		if end < 0 {
+			return ""
+		}
+		digits := strings.Join(strings.Fields(string(value[1:end])), "")
+		if len(digits)%2 == 1 {
+			digits += "0"
+		}
+		decoded, err := hex.DecodeString(digits)
+		if err != nil {
+			return ""
+		}
+		return decodeText(decoded)
+	}
+	return ""
+}
+
+// literalString reads a string up to its closing parenthesis, resolving escapes
+func literalString(data []byte) []byte {
+	out := []byte{}
+	depth := 0
+	for i := 0; i < len(data) && len(out) < maxStringBytes*2; i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch next := data[i]; next {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n':
+				// line continuation
+			default:
+				if next >= '0' && next <= '7' {
+					end := i + 1
+					for end < len(data) && end < i+3 && data[end] >= '0' && data[end] <= '7' {
+						end++
+					}
+					value, _ := strconv.ParseUint(string(data[i:end]), 8, 8)
+					out = append(out, byte(value))
+					i = end - 1
+				} else {
+					out = append(out, next)
+				}
+			}
+		case c == '(':
+			depth++
+			out = append(out, c)
+		case c == ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// decodeText turns UTF-16BE strings with a byte order mark, or PDFDocEncoding
+// read as Latin-1, into trimmed UTF-8
+func decodeText(data []byte) string {
+	var text string
+	if len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF {
+		units := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		}
+		text = string(utf16.Decode(units))
+	} else {
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+	text = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 {
+			return ' '
+		}
+		return r
+	}, text))
+	if len(text) > maxStringBytes {
+		text = strings.ToValidUTF8(text[:maxStringBytes], "")
+	}
+	return text
+}
+
+// parseDate reads a PDF date such as D:20230506070809+02'00', zero when it cannot be read
+func parseDate(value string) time.Time {
+	match := datePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return time.Time{}
+	}
+	number := func(index int, fallback int) int {
+		if match[index] == "" {
+			return fallback
+		}
+		value, _ := strconv.Atoi(match[index])
+		return value
+	}
+	location := time.UTC
+	if match[7] == "+" || match[7] == "-" {
+		offset := number(8, 0)*3600 + number(9, 0)*60
+		if match[7] == "-" {
+			offset = -offset
+		}
+		location = time.FixedZone("", offset)
+	}
+	date := time.Date(number(1, 0), time.Month(number(2, 1)), number(3, 1),
+		number(4, 0), number(5, 0), number(6, 0), 0, location)
+	return date.UTC()
+}
@@ -0,0 +1,301 @@
+package rendition
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// IIIFTileSize is the width and height of the tiles advertised in info.json
+const IIIFTileSize = 512
+
+// iiifFormats maps the IIIF format extensions to the encoded mime type
+var iiifFormats = map[string]string{
+	"jpg": "image/jpeg",
+	"png": "image/png",
+	"gif": "image/gif",
+}
+
+// ErrInvalid is returned for IIIF requests that do not follow the grammar or exceed the image
+var ErrInvalid = errors.New("invalid image request")
+
+// invalid wraps ErrInvalid with the offending part of the request
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// IIIFRequest struct is a parsed {region}/{size}/{rotation}/{quality}.{format} request
+type IIIFRequest struct {
+	Region   string
+	Size     string
+	Rotation string
+	Quality  string
+	Format   string
+}
+
+// ParseIIIF splits an image request into its parameters
+func ParseIIIF(path string) (IIIFRequest, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 4 {
+		return IIIFRequest{}, invalid("expected region/size/rotation/quality.format")
+	}
+	dot := strings.LastIndex(parts[3], ".")
+	if dot < 0 {
+		return IIIFRequest{}, invalid("missing format")
+	}
+	request := IIIFRequest{
+		Region:   parts[0],
+		Size:     parts[1],
+		Rotation: parts[2],
+		Quality:  parts[3][:dot],
+		Format:   parts[3][dot+1:],
+	}
+	if _, found := iiifFormats[request.Format]; !found {
+		return request, invalid("unsupported format %s", request.Format)
+	}
+	switch request.Quality {
+	case "default", "color", "gray", "bitonal":
+	default:
+		return request, invalid("unsupported quality %s", request.Quality)
+	}
+	return request, nil
+}
+
+// Canonical returns the request as a path so equal requests are cached alike
+func (r IIIFRequest) Canonical() string {
+	return r.Region + "/" + r.Size + "/" + r.Rotation + "/" + r.Quality + "." + r.Format
+}
+
+// Extension returns the file extension of the requested format
+func (r IIIFRequest) Extension() string {
+	return Extension(iiifFormats[r.Format])
+}
+
+// Apply extracts the region of the upright image, scales, mirrors, rotates and encodes it
+func (r IIIFRequest) Apply(img image.Image, orientation int) (Result, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if swapsAxes(orientation) {
+		width, height = height, width
+	}
+
+	region, err := r.region(width, height)
+	if err != nil {
+		return Result{}, err
+	}
+	outWidth, outHeight, err := r.size(region.Dx(), region.Dy())
+	if err != nil {
+		return Result{}, err
+	}
+	mirror, degrees, err := r.rotation()
+	if err != nil {
+		return Result{}, err
+	}
+
+	out := resample(img, orientation, region, outWidth, outHeight)
+	if mirror {
+		out = orient(out, 2)
+	}
+	switch degrees {
+	case 90:
+		out = orient(out, 6)
+	case 180:
+		out = orient(out, 3)
+	case 270:
+		out = orient(out, 8)
+	}
+
+	var encoded image.Image = out
+	switch r.Quality {
+	case "gray":
+		encoded = gray(out, false)
+	case "bitonal":
+		encoded = gray(out, true)
+	}
+	mimeType := iiifFormats[r.Format]
+	data, err := Encode(encoded, mimeType, jpegQuality)
+	if err != nil {
+		return Result{}, err
+	}
+	bounds = out.Bounds()
+	return Result{Data: data, MimeType: mimeType, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// region parses full, square, x,y,w,h and pct:x,y,w,h and clips it to the image
+func (r IIIFRequest) region(width int, height int) (image.Rectangle, error) {
+	full := image.Rect(0, 0, width, height)
+	switch {
+	case r.Region == "full":
+		return full, nil
+	case r.Region == "square":
+		side := width
+		if height < side {
+			side = height
+		}
+		x, y := (width-side)/2, (height-side)/2
+		return image.Rect(x, y, x+side, y+side), nil
+	}
+
+	percent := strings.HasPrefix(r.Region, "pct:")
+	values, err := numbers(strings.TrimPrefix(r.Region, "pct:"), 4, percent)
+	if err != nil {
+		return image.Rectangle{}, invalid("region %s", r.Region)
+	}
+	if percent {
+		values[0] = values[0] * float64(width) / 100
+		values[1] = values[1] * float64(height) / 100
+		values[2] = values[2] * float64(width) / 100
+		values[3] = values[3] * float64(height) / 100
+	}
+	x, y := int(math.Round(values[0])), int(math.Round(values[1]))
+	region := image.Rect(x, y, x+int(math.Round(values[2])), y+int(math.Round(values[3]))).Intersect(full)
+	if region.Empty() {
+		return image.Rectangle{}, invalid("region %s is outside the image", r.Region)
+	}
+	return region, nil
+}
+
+// size parses the size forms of IIIF 3.0, upscaling only when prefixed by ^
+func (r IIIFRequest) size(width int, height int) (int, int, error) {
+	value := r.Size
+	upscale := strings.HasPrefix(value, "^")
+	value = strings.TrimPrefix(value, "^")
+	max := MaxDimension()
+
+	var w, h int
+	switch {
+	case value == "max":
+		limit := max
+		if !upscale {
+			limit = maxInt(width, height)
+		}
+		w, h = scaleInto(width, height, minInt(limit, max), minInt(limit, max))
+	case strings.HasPrefix(value, "pct:"):
+		values, err := numbers(strings.TrimPrefix(value, "pct:"), 1, true)
+		if err != nil || values[0] <= 0 {
+			return 0, 0, invalid("size %s", r.Size)
+		}
+		w = int(math.Round(float64(width) * values[0] / 100))
+		h = int(math.Round(float64(height) * values[0] / 100))
+	case strings.HasPrefix(value, "!"):
+		values, err := numbers(strings.TrimPrefix(value, "!"), 2, false)
+		if err != nil || values[0] < 1 || values[1] < 1 {
+			return 0, 0, invalid("size %s", r.Size)
+		}
+		w, h = scaleInto(width, height, int(values[0]), int(values[1]))
+	default:
+		parts := strings.Split(value, ",")
+		if len(parts) != 2 || (parts[0] == "" && parts[1] == "") {
+			return 0, 0, invalid("size %s", r.Size)
+		}
+		var err error
+		if parts[0] != "" {
+			if w, err = strconv.Atoi(parts[0]); err != nil || w < 1 {
+				return 0, 0, invalid("size %s", r.Size)
+			}
+		}
+		if parts[1] != "" {
+			if h, err = strconv.Atoi(parts[1]); err != nil || h < 1 {
+				return 0, 0, invalid("size %s", r.Size)
+			}
+		}
+		if w == 0 {
+			w = int(math.Round(float64(width) * float64(h) / float64(height)))
+		}
+		if h == 0 {
+			h = int(math.Round(float64(height) * float64(w) / float64(width)))
+		}
+	}
+
+	w, h = maxInt(w, 1), maxInt(h, 1)
+	if !upscale && (w > width || h > height) {
+		return 0, 0, invalid("size %s is larger than the region, use ^ to upscale", r.Size)
+	}
+	if w > max || h > max {
+		return 0, 0, invalid("size %s is larger than %d", r.Size, max)
+	}
+	return w, h, nil
+}
+
+// rotation parses n and !n where n is a multiple of 90
+func (r IIIFRequest) rotation() (bool, int, error) {
+	mirror := strings.HasPrefix(r.Rotation, "!")
+	degrees, err := strconv.ParseFloat(strings.TrimPrefix(r.Rotation, "!"), 64)
+	if err != nil || degrees < 0 || degrees > 360 || math.Mod(degrees, 90) != 0 {
+		return false, 0, invalid("rotation %s, only multiples of 90 are supported", r.Rotation)
+	}
+	return mirror, int(degrees) % 360, nil
+}
+
+// scaleInto fits width x height into the box keeping the aspect ratio, enlarging it if needed
+func scaleInto(width int, height int, boxWidth int, boxHeight int) (int, int) {
+	scale := math.Min(float64(boxWidth)/float64(width), float64(boxHeight)/float64(height))
+	return maxInt(int(math.Round(float64(width)*scale)), 1), maxInt(int(math.Round(float64(height)*scale)), 1)
+}
+
+// numbers parses count comma separated numbers, decimals only when allowed
+func numbers(value string, count int, decimals bool) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, ErrInvalid
+	}
+	values := make([]float64, count)
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 || (!decimals && number != math.Trunc(number)) {
+			return nil, ErrInvalid
+		}
+		values[i] = number
+	}
+	return values, nil
+}
+
+// gray converts the image to grayscale, or to black and white when bitonal
+func gray(img *image.RGBA, bitonal bool) *image.Gray {
+	bounds := img.Bounds()
+	out := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			value := color.GrayModel.Convert(img.At(x, y)).(color.Gray)
+			if bitonal {
+				if value.Y >= 128 {
+					value.Y = 255
+				} else {
+					value.Y = 0
+				}
+			}
+			out.SetGray(x, y, value)
+		}
+	}
+	return out
+}
+
+// maxInt returns the larger of a and b
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// minInt returns the smaller of a and b
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ScaleFactors returns the tile scale factors of an image, halving until a tile covers it
+func ScaleFactors(width int, height int) []int {
+	factors := []int{1}
+	for factor := 1; (maxInt(width, height)+factor-1)/factor > IIIFTileSize; {
+		factor *= 2
+		factors = append(factors, factor)
+	}
+	return factors
+}
@@ -0,0 +1,86 @@
+package rendition
+
+import (
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// decoded is an image kept by Images
+type decoded struct {
+	path        string
+	img         image.Image
+	orientation int
+}
+
+// Images struct keeps the last decoded images so the many tiles a viewer asks
+// for at once decode their image a single time. Loads are serialised so a burst
+// of requests does not decode large images side by side.
+type Images struct {
+	mutex   sync.Mutex
+	size    int
+	entries []decoded
+}
+
+// NewImages creates a cache of the size last decoded images
+func NewImages(size int) *Images {
+	return &Images{size: size}
+}
+
+// Load returns the decoded image at path and its EXIF orientation
+func (c *Images) Load(path string) (image.Image, int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, entry := range c.entries {
+		if entry.path == path {
+			// move to the front so the least recently used image is dropped first
+			copy(c.entries[1:i+1], c.entries[:i])
+			c.entries[0] = entry
+			return entry.img, entry.orientation, nil
+		}
+	}
+
+	source, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer source.Close()
+	orientation := Orientation(source)
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	img, err := Decode(source)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	c.entries = append([]decoded{{path: path, img: img, orientation: orientation}}, c.entries...)
+	if len(c.entries) > c.size {
+		c.entries = c.entries[:c.size]
+	}
+	return img, orientation, nil
+}
+
+// Dimensions returns the size of the image at path once turned upright
+// without decoding its pixels
+func Dimensions(path string) (int, int, error) {
+	source, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer source.Close()
+	orientation := Orientation(source)
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	config, _, err := image.DecodeConfig(source)
+	if err != nil {
+		return 0, 0, err
+	}
+	if swapsAxes(orientation) {
+		return config.Height, config.Width, nil
+	}
+	return config.Width, config.Height, nil
+}
@@ -0,0 +1,155 @@
+package rendition
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// maxExifBytes bounds the APP1 segment read for the orientation
+const maxExifBytes = 64 << 10
+
+// exifOrientation is the TIFF tag of the EXIF orientation
+const exifOrientation = 0x0112
+
+// Orientation reads the EXIF orientation of a JPEG, 1 when it is absent
+func Orientation(source io.Reader) int {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(source, head); err != nil || head[0] != 0xFF || head[1] != 0xD8 {
+		return 1
+	}
+
+	marker := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(source, marker); err != nil || marker[0] != 0xFF {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if length < 0 || marker[1] == 0xDA {
+			return 1
+		}
+		if marker[1] != 0xE1 || length > maxExifBytes {
+			if _, err := io.CopyN(io.Discard, source, int64(length)); err != nil {
+				return 1
+			}
+			continue
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(source, segment); err != nil {
+			return 1
+		}
+		if len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+	}
+}
+
+// tiffOrientation finds the orientation tag in the first IFD of a TIFF header
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == exifOrientation {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value < 1 || value > 8 {
+				return 1
+			}
+			return value
+		}
+	}
+	return 1
+}
+
+// swapsAxes checks if the orientation turns the image by a quarter
+func swapsAxes(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// sourceRect maps a rectangle of the upright image back onto the stored image
+// of size width x height
+func sourceRect(orientation int, rect image.Rectangle, width int, height int) image.Rectangle {
+	W, H := width, height
+	if swapsAxes(orientation) {
+		W, H = height, width
+	}
+	point := func(X int, Y int) image.Point {
+		switch orientation {
+		case 2:
+			return image.Pt(W-X, Y)
+		case 3:
+			return image.Pt(W-X, H-Y)
+		case 4:
+			return image.Pt(X, H-Y)
+		case 5:
+			return image.Pt(Y, X)
+		case 6:
+			return image.Pt(Y, W-X)
+		case 7:
+			return image.Pt(H-Y, W-X)
+		case 8:
+			return image.Pt(H-Y, X)
+		default:
+			return image.Pt(X, Y)
+		}
+	}
+	return image.Rectangle{Min: point(rect.Min.X, rect.Min.Y), Max: point(rect.Max.X, rect.Max.Y)}.Canon()
+}
+
+// orient turns a decoded image upright
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if swapsAxes(orientation) {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			from := img.PixOffset(x+img.Rect.Min.X, y+img.Rect.Min.Y)
+			to := dst.PixOffset(dx, dy)
+			copy(dst.Pix[to:to+4], img.Pix[from:from+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,165 @@
+package rendition
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/webp" // register webp decoding
+)
+
+// defaultSpecs are generated when RENDITIONS is not set
+const defaultSpecs = "thumbnail:200x200,preview:1024x1024"
+
+// MaxPixels bounds the decoded size of a source image to block decompression bombs
+const MaxPixels = 50_000_000
+
+// jpegQuality of opaque renditions
+const jpegQuality = 85
+
+// specPattern matches a rendition in RENDITIONS, for example thumbnail:200x200
+var specPattern = regexp.MustCompile(`^([a-z0-9_-]{1,32}):([0-9]{1,5})x([0-9]{1,5})$`)
+
+// ErrTooLarge is returned for images with more than MaxPixels pixels
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// Spec struct names a box renditions are fitted into
+type Spec struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// LoadSpecs reads the renditions to generate from RENDITIONS,
+// a comma separated list of name:WIDTHxHEIGHT
+func LoadSpecs() []Spec {
+	value := os.Getenv("RENDITIONS")
+	if value == "" {
+		value = defaultSpecs
+	}
+
+	specs := []Spec{}
+	for _, item := range strings.Split(value, ",") {
+		match := specPattern.FindStringSubmatch(strings.TrimSpace(item))
+		if match == nil {
+			continue
+		}
+		width, _ := strconv.Atoi(match[2])
+		height, _ := strconv.Atoi(match[3])
+		if width == 0 || height == 0 {
+			continue
+		}
+		specs = append(specs, Spec{Name: match[1], Width: width, Height: height})
+	}
+	return specs
+}
+
+// Eager checks if renditions are generated on upload rather than on first request
+func Eager() bool {
+	eager, _ := strconv.ParseBool(os.Getenv("RENDITIONS_ON_UPLOAD"))
+	return eager
+}
+
+// Supported checks if renditions can be made from the mime type
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Result struct is an encoded rendition
+type Result struct {
+	Data     []byte
+	MimeType string
+	Width    int
+	Height   int
+}
+
+// Decode reads an image after checking its dimensions against MaxPixels
+func Decode(source io.ReadSeeker) (image.Image, error) {
+	config, _, err := image.DecodeConfig(source)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(source)
+	return img, err
+}
+
+// Render fits the upright source image into the spec without upscaling and encodes it,
+// as PNG when the source has transparency and JPEG otherwise
+func Render(source io.ReadSeeker, spec Spec) (Result, error) {
+	return Transform(source, Options{
+		Width:   spec.Width,
+		Height:  spec.Height,
+		Fit:     FitContain,
+		Format:  FormatAuto,
+		Quality: jpegQuality,
+	})
+}
+
+// Fit scales width and height down to fit the box keeping the aspect ratio
+func Fit(width int, height int, boxWidth int, boxHeight int) (int, int) {
+	if width <= boxWidth && height <= boxHeight {
+		return width, height
+	}
+	if width*boxHeight > height*boxWidth {
+		height = height * boxWidth / width
+		width = boxWidth
+	} else {
+		width = width * boxHeight / height
+		height = boxHeight
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+// Encode writes the image in one of the supported output formats
+func Encode(img image.Image, mimeType string, quality int) ([]byte, error) {
+	buffer := bytes.NewBuffer(nil)
+	var err error
+	switch mimeType {
+	case "image/jpeg":
+		err = jpeg.Encode(buffer, img, &jpeg.Options{Quality: quality})
+	case "image/png":
+		err = png.Encode(buffer, img)
+	case "image/gif":
+		err = gif.Encode(buffer, img, nil)
+	default:
+		err = fmt.Errorf("unsupported output format %s", mimeType)
+	}
+	return buffer.Bytes(), err
+}
+
+// Extension returns the file extension of an output format
+func Extension(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ".jpg"
+	}
+}
@@ -0,0 +1,26 @@
+package rendition
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Sign returns the signature of a set of options for a file, so only
+// parameter sets handed out by the application are transformed. The signature
+// is the unpadded base64url HMAC-SHA256 under TRANSFORM_SIGNING_KEY of
+// {fileId}?w={w}&h={h}&fit={fit}&format={format}&q={q}, every option written
+// in this order with its default when it was not given.
+func Sign(key []byte, fileID string, options Options) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(fileID + "?" + options.Canonical()))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks the signature of a set of options in constant time
+func Verify(key []byte, fileID string, options Options, signature string) bool {
+	if len(key) == 0 || signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(key, fileID, options)), []byte(signature))
+}
@@ -0,0 +1,205 @@
+package rendition
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// Fit modes of a transformation
+const (
+	FitContain = "contain"
+	FitCover   = "cover"
+	FitFill    = "fill"
+)
+
+// FormatAuto encodes opaque images as JPEG and the others as PNG
+const FormatAuto = "auto"
+
+// defaultMaxDimension bounds the width and height a transformation may ask for
+const defaultMaxDimension = 4096
+
+// formats maps the format parameter to the encoded mime type
+var formats = map[string]string{
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+// Options struct holds the parameters of a transformation
+type Options struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int
+}
+
+// MaxDimension reads the largest width or height allowed from TRANSFORM_MAX_DIMENSION
+func MaxDimension() int {
+	value, err := strconv.Atoi(os.Getenv("TRANSFORM_MAX_DIMENSION"))
+	if err != nil || value <= 0 {
+		return defaultMaxDimension
+	}
+	return value
+}
+
+// ParseOptions reads w, h, fit, format and q from the query of a transform request
+func ParseOptions(values url.Values) (Options, error) {
+	options := Options{Fit: FitContain, Format: FormatAuto, Quality: jpegQuality}
+	integer := func(name string, target *int) error {
+		if values.Get(name) == "" {
+			return nil
+		}
+		value, err := strconv.Atoi(values.Get(name))
+		if err != nil {
+			return fmt.Errorf("invalid %s", name)
+		}
+		*target = value
+		return nil
+	}
+	if err := integer("w", &options.Width); err != nil {
+		return options, err
+	}
+	if err := integer("h", &options.Height); err != nil {
+		return options, err
+	}
+	if err := integer("q", &options.Quality); err != nil {
+		return options, err
+	}
+	if values.Get("fit") != "" {
+		options.Fit = values.Get("fit")
+	}
+	if values.Get("format") != "" {
+		options.Format = values.Get("format")
+	}
+	return options, options.Validate()
+}
+
+// Validate checks the options are within the configured limits
+func (o Options) Validate() error {
+	max := MaxDimension()
+	if o.Width < 0 || o.Height < 0 || o.Width > max || o.Height > max {
+		return fmt.Errorf("w and h must be between 0 and %d", max)
+	}
+	if o.Quality < 1 || o.Quality > 100 {
+		return errors.New("q must be between 1 and 100")
+	}
+	switch o.Fit {
+	case FitContain, FitCover, FitFill:
+	default:
+		return errors.New("fit must be contain, cover or fill")
+	}
+	if _, found := formats[o.Format]; !found && o.Format != FormatAuto {
+		return errors.New("format must be auto, jpeg, png or gif")
+	}
+	return nil
+}
+
+// Canonical returns the options in a fixed order so equal options sign and cache alike
+func (o Options) Canonical() string {
+	return fmt.Sprintf("w=%d&h=%d&fit=%s&format=%s&q=%d", o.Width, o.Height, o.Fit, o.Format, o.Quality)
+}
+
+// Transform turns the source image upright, resizes or crops it and encodes it
+func Transform(source io.ReadSeeker, options Options) (Result, error) {
+	orientation := Orientation(source)
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		return Result{}, err
+	}
+	img, err := Decode(source)
+	if err != nil {
+		return Result{}, err
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if swapsAxes(orientation) {
+		width, height = height, width
+	}
+	crop, outWidth, outHeight := geometry(width, height, options)
+	scaled := resample(img, orientation, crop, outWidth, outHeight)
+
+	format := formats[options.Format]
+	if format == "" {
+		format = "image/jpeg"
+		if !scaled.Opaque() {
+			format = "image/png"
+		}
+	}
+	data, err := Encode(scaled, format, options.Quality)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Data: data, MimeType: format, Width: outWidth, Height: outHeight}, nil
+}
+
+// resample scales the crop of the upright image to width x height. The stored
+// image is scaled first and turned upright afterwards, the smaller copy is cheaper to turn.
+func resample(img image.Image, orientation int, crop image.Rectangle, width int, height int) *image.RGBA {
+	bounds := img.Bounds()
+	region := sourceRect(orientation, crop, bounds.Dx(), bounds.Dy()).Add(bounds.Min)
+	if swapsAxes(orientation) {
+		width, height = height, width
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, region, draw.Src, nil)
+	return orient(scaled, orientation)
+}
+
+// geometry works out the part of the upright image to use and the size of the output.
+// Images are never enlarged except to fill an exact box.
+func geometry(width int, height int, options Options) (image.Rectangle, int, int) {
+	full := image.Rect(0, 0, width, height)
+	boxWidth, boxHeight := options.Width, options.Height
+	if boxWidth == 0 && boxHeight == 0 {
+		w, h := Fit(width, height, MaxDimension(), MaxDimension())
+		return full, w, h
+	}
+
+	// a single dimension keeps the aspect ratio whatever the fit
+	if boxWidth == 0 || boxHeight == 0 {
+		if boxWidth == 0 {
+			boxWidth = MaxDimension()
+		}
+		if boxHeight == 0 {
+			boxHeight = MaxDimension()
+		}
+		w, h := Fit(width, height, boxWidth, boxHeight)
+		return full, w, h
+	}
+
+	switch options.Fit {
+	case FitFill:
+		return full, boxWidth, boxHeight
+	case FitCover:
+		crop := full
+		if width*boxHeight > height*boxWidth {
+			cropWidth := height * boxWidth / boxHeight
+			if cropWidth < 1 {
+				cropWidth = 1
+			}
+			crop.Min.X = (width - cropWidth) / 2
+			crop.Max.X = crop.Min.X + cropWidth
+		} else {
+			cropHeight := width * boxHeight / boxWidth
+			if cropHeight < 1 {
+				cropHeight = 1
+			}
+			crop.Min.Y = (height - cropHeight) / 2
+			crop.Max.Y = crop.Min.Y + cropHeight
+		}
+		w, h := Fit(boxWidth, boxHeight, crop.Dx(), crop.Dy())
+		return crop, w, h
+	default:
+		w, h := Fit(width, height, boxWidth, boxHeight)
+		return full, w, h
+	}
+}
@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/models"
+)
+
+// auditColumns are read into models.AuditEvent by scanAuditEvent
+const auditColumns = `id, tenantId, seq, coalesce(fileId, ''), actorId, origin, action, requestId, detail, createdOn, prevHash, hash`
+
+// AuditRepository struct
+type AuditRepository struct {
+	conn *sql.DB
+}
+
+// Init method
+func (repo *AuditRepository) Init(conn *sql.DB) {
+	repo.conn = conn
+}
+
+// Append method chains the event to the last event of its tenant. The tenant
+// lock orders concurrent appends so every event follows exactly one other.
+func (repo *AuditRepository) Append(ctx context.Context, event models.AuditEvent) (models.AuditEvent, error) {
+	ctx, span := startSpan(ctx, "AuditRepository.Append")
+	defer span.End()
+
+	last := `
+	select seq, hash
+	from audit_events
+	where tenantId = $1
+	order BY seq DESC
+	limit 1
+	`
+	statement := `
+	insert into audit_events (id, tenantId, seq, fileId, actorId, origin, action, requestId, detail, createdOn, prevHash, hash)
+	values ($1, $2, $3, nullif($4, ''), $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	event.ID = uuid.New().String()
+	event.CreatedOn = time.Now().UTC().Truncate(time.Microsecond)
+	if event.Detail == nil {
+		event.Detail = map[string]string{}
+	}
+	detail, err := json.Marshal(event.Detail)
+	if err != nil {
+		return event, err
+	}
+
+	err = inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext('audit_events:' || $1))", event.TenantID); err != nil {
+			return err
+		}
+		event.Seq, event.PrevHash = 0, models.AuditGenesis
+		err := tx.QueryRowContext(ctx, last, event.TenantID).Scan(&event.Seq, &event.PrevHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		event.Seq++
+		event.Hash = event.ComputeHash()
+		return execAffected(ctx, tx, statement, event.ID, event.TenantID, event.Seq, event.FileID, event.ActorID,
+			event.Origin, event.Action, event.RequestID, string(detail), event.CreatedOn, event.PrevHash, event.Hash)
+	})
+	return event, err
+}
+
+// GetEvents method returns the events of the tenant of ctx matching the query, newest first
+func (repo *AuditRepository) GetEvents(ctx context.Context, query models.AuditQuery) ([]models.AuditEvent, error) {
+	ctx, span := startSpan(ctx, "AuditRepository.GetEvents")
+	defer span.End()
+
+	statement := `
+	select ` + auditColumns + `
+	from audit_events
+	where tenantId = $1
+	and ($2 = '' or fileId = $2)
+	and ($3::bigint = 0 or actorId = $3::bigint)
+	and ($4 = '' or action = $4)
+	and ($5::timestamp is null or createdOn >= $5::timestamp)
+	and ($6::timestamp is null or createdOn < $6::timestamp)
+	order BY seq DESC
+	limit $7 offset $8
+	`
+	events := []models.AuditEvent{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, statement, TenantFrom(ctx), query.FileID, query.ActorID, query.Action,
+			nullTime(query.From), nullTime(query.To), query.Limit, query.Offset)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			event, err := scanAuditEvent(rows)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetChain method returns the events of a tenant after seq in order of seq
+func (repo *AuditRepository) GetChain(ctx context.Context, tenantID string, afterSeq int64, limit int) ([]models.AuditEvent, error) {
+	statement := `
+	select ` + auditColumns + `
+	from audit_events
+	where tenantId = $1 and seq > $2
+	order BY seq ASC
+	limit $3
+	`
+	events := []models.AuditEvent{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, statement, tenantID, afterSeq, limit)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			event, err := scanAuditEvent(rows)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetTenants method returns the tenants with audit events visible in ctx
+func (repo *AuditRepository) GetTenants(ctx context.Context) ([]string, error) {
+	query := `
+	select distinct tenantId
+	from audit_events
+	order BY tenantId
+	`
+	tenants := []string{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var tenantID string
+			if err := rows.Scan(&tenantID); err != nil {
+				return err
+			}
+			tenants = append(tenants, tenantID)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// scanAuditEvent reads the auditColumns of a row
+func scanAuditEvent(rows *sql.Rows) (models.AuditEvent, error) {
+	event := models.AuditEvent{}
+	var detail string
+	err := rows.Scan(&event.ID, &event.TenantID, &event.Seq, &event.FileID, &event.ActorID, &event.Origin,
+		&event.Action, &event.RequestID, &detail, &event.CreatedOn, &event.PrevHash, &event.Hash)
+	if err != nil {
+		return event, err
+	}
+	// an unreadable detail is kept empty, the hash check of the chain reports it
+	_ = json.Unmarshal([]byte(detail), &event.Detail)
+	event.CreatedOn = event.CreatedOn.UTC()
+	return event, nil
+}
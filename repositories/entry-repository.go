@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/models"
+)
+
+// EntryRepository struct
+type EntryRepository struct {
+	conn *sql.DB
+}
+
+// Init method
+func (repo *EntryRepository) Init(conn *sql.DB) {
+	repo.conn = conn
+}
+
+// Create method records the entries of an archive, encrypting their names like file names
+func (repo *EntryRepository) Create(ctx context.Context, enKey string, fileID string, entries []models.Entry) error {
+	statement := `
+    insert into file_entries (id, fileId, tenantId, position, name, size, compressedSize, modifiedOn, directory)
+    values ($1, $2, $3, $4, PGP_SYM_ENCRYPT($5, '` + enKey + `'), $6, $7, $8, $9)
+  	`
+	return inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		for _, entry := range entries {
+			_, err := tx.ExecContext(ctx, statement, uuid.New().String(), fileID, TenantFrom(ctx),
+				entry.Position, entry.Name, entry.Size, entry.CompressedSize, nullTime(entry.ModifiedOn), entry.Directory)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetEntries method returns the entries of an archive in archive order
+func (repo *EntryRepository) GetEntries(ctx context.Context, enKey string, fileID string) ([]models.Entry, error) {
+	query := `
+	select id, fileId, position, pgp_sym_decrypt(name::bytea, '` + enKey + `'), size, compressedSize,
+	modifiedOn, directory, coalesce(childId, '')
+	from file_entries
+	where fileId = $1
+	order BY position ASC
+	`
+	entries := []models.Entry{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, fileID)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var entry models.Entry
+			var modifiedOn sql.NullTime
+			err := rows.Scan(&entry.ID, &entry.FileID, &entry.Position, &entry.Name, &entry.Size,
+				&entry.CompressedSize, &modifiedOn, &entry.Directory, &entry.ChildID)
+			if err != nil {
+				return err
+			}
+			entry.ModifiedOn = modifiedOn.Time
+			entries = append(entries, entry)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SetChild method links an entry to the document it was exploded into
+func (repo *EntryRepository) SetChild(ctx context.Context, fileID string, position int, childID string) error {
+	statement := `
+    update file_entries
+    set childId = $3
+    where fileId = $1 and position = $2
+  	`
+	return inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, fileID, position, childID)
+	})
+}
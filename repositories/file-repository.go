@@ -3,40 +3,83 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/blind"
+	"github.com/greatfocus/gf-document/metrics"
 	"github.com/greatfocus/gf-document/models"
-	"github.com/greatfocus/gf-sframe/database"
+	"github.com/greatfocus/gf-document/tracing"
+	"github.com/lib/pq"
 	cache "github.com/patrickmn/go-cache"
 )
 
-// fileRepositoryCacheKeys array
-var fileRepositoryCacheKeys = []string{}
+// cacheRequests counts the lookups of the file cache, hits over all is the hit ratio
+var cacheRequests = metrics.NewCounter("file_cache_requests_total",
+	"Lookups of the FileRepository cache by result.", "result")
+
+// fileRepositoryCacheKeys lists the cached file queries, workers and requests
+// share it so fileCacheLock guards it and the cache writes of its keys
+var (
+	fileRepositoryCacheKeys = []string{}
+	fileCacheLock           sync.Mutex
+)
+
+// nameField names the encrypted name column within blind indexes
+const nameField = "files.name"
 
 // FileRepository struct
 type FileRepository struct {
-	db    database.Database
+	conn  *sql.DB
 	cache *cache.Cache
+	index blind.Index
 }
 
 // Init method
-func (repo *FileRepository) Init(database database.Database, cache *cache.Cache) {
-	repo.db = database
+func (repo *FileRepository) Init(conn *sql.DB, cache *cache.Cache) {
+	repo.conn = conn
 	repo.cache = cache
+	repo.index = blind.New(os.Getenv("BLIND_INDEX_KEY"))
 }
 
-// Create method
-func (repo *FileRepository) Create(ctx context.Context, enKey string, doc models.File) (models.File, error) {
+// Create method inserts the file and adds it to the storage usage
+// of its tenant and actor, failing when that goes over a hard quota
+func (repo *FileRepository) Create(ctx context.Context, enKey string, doc models.File, quotas map[string]models.Quota) (models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.Create")
+	defer span.End()
+
 	var id = uuid.New().String()
 	statement := `
-    insert into files (id, name, extension, size, status)
-    values ($1, PGP_SYM_ENCRYPT($2, '` + enKey + `'), $3, $4, $5)
-    returning id
+    insert into files (id, name, extension, size, status, actorId, origin, tenantId, mimeType,
+		nameIndex, namePrefixIndex, indexKeyId, metadata, parentId, originalName)
+    values ($1, PGP_SYM_ENCRYPT($2, '` + enKey + `'), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+		PGP_SYM_ENCRYPT(nullif($15, ''), '` + enKey + `'))
   	`
-	_, inserted := repo.db.Insert(ctx, statement, id, doc.Name, doc.Extension, doc.Size, doc.Status)
-	if !inserted {
+	doc.TenantID = TenantFrom(ctx)
+	metadata, err := json.Marshal(doc.Metadata)
+	if err != nil || doc.Metadata == nil {
+		metadata = []byte("{}")
+	}
+	err = inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		err := execAffected(ctx, tx, statement, id, doc.Name, doc.Extension, doc.Size,
+			doc.Status, doc.ActorID, doc.Origin, doc.TenantID, doc.MimeType,
+			repo.index.Exact(nameField, doc.Name), repo.index.Prefix(nameField, doc.Name), repo.index.KeyID(),
+			string(metadata), sql.NullString{String: doc.ParentID, Valid: doc.ParentID != ""}, doc.OriginalName)
+		if err != nil {
+			return err
+		}
+		return addUsage(ctx, tx, doc.TenantID, doc.ActorID, doc.Size, 1, quotas)
+	})
+	if err == ErrQuotaExceeded {
+		return doc, err
+	}
+	if err != nil {
 		return doc, errors.New("create doc failed")
 	}
 	doc.ID = id
@@ -46,22 +89,29 @@ func (repo *FileRepository) Create(ctx context.Context, enKey string, doc models
 
 // GetFileByID method
 func (repo *FileRepository) GetFileByID(ctx context.Context, enKey string, id string) (models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetFileByID")
+	defer span.End()
+
 	// get data from cache
-	var key = "FileRepository.GetFileByID." + id
+	var key = "FileRepository.GetFileByID." + TenantFrom(ctx) + "." + id
 	found, cache := repo.getFileCache(key)
+	span.SetAttributes(tracing.Bool("cache.hit", found))
 	if found {
 		return cache, nil
 	}
 
 	query := `
-	select id, pgp_sym_decrypt(name::bytea, '` + enKey + `'), status, createdOn
+	select ` + fileColumns(enKey) + `
 	from files
 	where id = $1
 	`
 
-	row := repo.db.Select(ctx, query, id)
 	file := models.File{}
-	err := row.Scan(&file.ID, &file.Name, &file.Status, &file.CreatedOn)
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		var err error
+		file, err = scanFile(tx.QueryRowContext(ctx, query, id))
+		return err
+	})
 	switch err {
 	case sql.ErrNoRows:
 		return file, err
@@ -74,42 +124,42 @@ func (repo *FileRepository) GetFileByID(ctx context.Context, enKey string, id st
 	}
 }
 
-// GetFiles method
-func (repo *FileRepository) GetFiles(ctx context.Context, enKey string, lastID string) ([]models.File, error) {
+// GetFiles method returns the files the actor is allowed to read
+func (repo *FileRepository) GetFiles(ctx context.Context, enKey string, actor models.Actor, lastID string) ([]models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetFiles")
+	defer span.End()
+
 	// get data from cache
-	var key = "FileRepository.GetFiles" + lastID
+	var key = "FileRepository.GetFiles." + TenantFrom(ctx) + "." + accessKey(actor) + "." + lastID
 	found, cache := repo.getFilesCache(key)
+	span.SetAttributes(tracing.Bool("cache.hit", found))
 	if found {
 		return cache, nil
 	}
 
 	var query string
-	var rows *sql.Rows
-	var err error
+	var args []interface{}
+	actorID := strconv.FormatInt(actor.ID, 10)
 	if lastID != "" {
 		query = `
-		select id, pgp_sym_decrypt(name::bytea, '` + enKey + `'), extension, size, status, createdOn
+		select ` + fileColumns(enKey) + `
 		from files
-		where id >= $1
+		where ` + readableFilter + `
+		and id >= $5
 		order BY createdOn DESC limit 20
 		`
-		rows, err = repo.db.Query(ctx, query, lastID)
+		args = []interface{}{actor.IsAdmin(), actor.ID, actorID, pq.Array(actor.Groups()), lastID}
 	} else {
 		query = `
-		select id, pgp_sym_decrypt(name::bytea, '` + enKey + `'), extension, size, status, createdOn
+		select ` + fileColumns(enKey) + `
 		from files
+		where ` + readableFilter + `
 		order BY createdOn DESC limit 20
 		`
-		rows, err = repo.db.Query(ctx, query)
+		args = []interface{}{actor.IsAdmin(), actor.ID, actorID, pq.Array(actor.Groups())}
 	}
 
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		_ = rows.Close()
-	}()
-	result, err := getFilesFromRows(rows)
+	result, err := repo.queryFiles(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -119,17 +169,43 @@ func (repo *FileRepository) GetFiles(ctx context.Context, enKey string, lastID s
 	return result, nil
 }
 
-// Update method update file
+// readableFilter limits files to those owned by or granted to the actor;
+// $1 is the admin override, $2 the actor, $3 the actor as text and $4 its groups
+const readableFilter = `($1 or actorId = $2 or exists (
+			select 1 from grants g
+			where g.fileId = files.id and g.permission = 'read'
+			and ((g.granteeType = 'actor' and g.granteeId = $3)
+			or (g.granteeType = 'group' and g.granteeId = any($4)))
+		))`
+
+// accessKey identifies the access scope of an actor within cache keys
+func accessKey(actor models.Actor) string {
+	if actor.IsAdmin() {
+		return "admin"
+	}
+	return strconv.FormatInt(actor.ID, 10) + ":" + strings.Join(actor.Groups(), ",")
+}
+
+// Update method update file, refreshing the name indexes when the name is known
 func (repo *FileRepository) Update(ctx context.Context, enKey string, file models.File) error {
+	ctx, span := startSpan(ctx, "FileRepository.Update")
+	defer span.End()
+
 	statement := `
     update files
 	set 
 	 	refId=$2,
-		status=$3
+		status=$3,
+		nameIndex=case when $4 = '' then nameIndex else $4 end,
+		namePrefixIndex=case when $4 = '' then namePrefixIndex else $5 end,
+		indexKeyId=case when $4 = '' then indexKeyId else $6 end
     where id=$1
   	`
-	updated := repo.db.Update(ctx, statement, file.ID, file.RefID, file.Status)
-	if !updated {
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, file.ID, file.RefID, file.Status,
+			repo.index.Exact(nameField, file.Name), repo.index.Prefix(nameField, file.Name), repo.index.KeyID())
+	})
+	if err != nil {
 		return errors.New("update file failed")
 	}
 
@@ -137,14 +213,271 @@ func (repo *FileRepository) Update(ctx context.Context, enKey string, file model
 	return nil
 }
 
-// Delete method
+// SetChecksum method records the SHA-256 of the stored content
+func (repo *FileRepository) SetChecksum(ctx context.Context, id string, checksum string) error {
+	ctx, span := startSpan(ctx, "FileRepository.SetChecksum")
+	defer span.End()
+
+	statement := `
+	update files
+	set checksum = $2
+	where id = $1
+	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, id, checksum)
+	})
+	if err != nil {
+		return err
+	}
+	repo.deleteCache()
+	return nil
+}
+
+// SetProblem method marks the file with the problem found with its stored
+// content, an empty problem clears the mark
+func (repo *FileRepository) SetProblem(ctx context.Context, id string, problem string) error {
+	ctx, span := startSpan(ctx, "FileRepository.SetProblem")
+	defer span.End()
+
+	statement := `
+	update files
+	set problem = nullif($2, ''),
+	problemOn = case when $2 = '' then null else CURRENT_TIMESTAMP end
+	where id = $1
+	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, id, problem)
+	})
+	if err != nil {
+		return err
+	}
+	repo.deleteCache()
+	return nil
+}
+
+// GetUnverified method returns the hashed files of every tenant last verified
+// before the time, those never verified first, following the file last
+// verified at afterVerified with afterID. The context must reach the tenants.
+func (repo *FileRepository) GetUnverified(ctx context.Context, enKey string, before time.Time, afterVerified time.Time, afterID string, limit int) ([]models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetUnverified")
+	defer span.End()
+
+	statement := `
+	select ` + fileColumns(enKey) + `
+	from files
+	where checksum is not null
+	and (verifiedOn is null or verifiedOn < $1)
+	and (coalesce(verifiedOn, '-infinity'), id) > (coalesce($2::timestamp, '-infinity'), $3)
+	order BY verifiedOn ASC NULLS FIRST, id ASC
+	limit $4
+	`
+	return repo.queryFiles(ctx, statement, before, nullTime(afterVerified), afterID, limit)
+}
+
+// SetVerified method records the content of the file was checked against its
+// checksum now, with the problem found or none
+func (repo *FileRepository) SetVerified(ctx context.Context, id string, problem string) error {
+	ctx, span := startSpan(ctx, "FileRepository.SetVerified")
+	defer span.End()
+
+	statement := `
+	update files
+	set verifiedOn = CURRENT_TIMESTAMP,
+	problemOn = case when $2 = '' then null when problem is distinct from $2 then CURRENT_TIMESTAMP else problemOn end,
+	problem = nullif($2, '')
+	where id = $1
+	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, id, problem)
+	})
+	if err != nil {
+		return err
+	}
+	repo.deleteCache()
+	return nil
+}
+
+// GetProblems method counts the files marked with each problem
+func (repo *FileRepository) GetProblems(ctx context.Context) (map[string]int64, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetProblems")
+	defer span.End()
+
+	query := `
+	select problem, count(*)
+	from files
+	where problem is not null
+	group BY problem
+	`
+	problems := map[string]int64{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var problem string
+			var count int64
+			if err := rows.Scan(&problem, &count); err != nil {
+				return err
+			}
+			problems[problem] = count
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// GetFileByName method finds a file by its exact name through the blind index,
+// decrypting every row only when no index key is configured
+func (repo *FileRepository) GetFileByName(ctx context.Context, enKey string, name string) (models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetFileByName")
+	defer span.End()
+
+	query := `
+	select ` + fileColumns(enKey) + `
+	from files
+	where nameIndex = $1
+	and pgp_sym_decrypt(name::bytea, '` + enKey + `') = $2
+	limit 1
+	`
+	args := []interface{}{repo.index.Exact(nameField, name), name}
+	if !repo.index.Enabled() {
+		query = `
+		select ` + fileColumns(enKey) + `
+		from files
+		where pgp_sym_decrypt(name::bytea, '` + enKey + `') = $1
+		limit 1
+		`
+		args = args[1:]
+	}
+
+	file := models.File{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		var err error
+		file, err = scanFile(tx.QueryRowContext(ctx, query, args...))
+		return err
+	})
+	return file, err
+}
+
+// GetFilesByNamePrefix method returns the readable files whose name starts with
+// prefix, only decrypting the rows of the matching prefix bucket
+func (repo *FileRepository) GetFilesByNamePrefix(ctx context.Context, enKey string, actor models.Actor, prefix string) ([]models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetFilesByNamePrefix")
+	defer span.End()
+
+	pattern := strings.ToLower(likeEscaper.Replace(prefix)) + "%"
+	bucket := repo.index.Prefix(nameField, prefix)
+	query := `
+	select ` + fileColumns(enKey) + `
+	from files
+	where ` + readableFilter + `
+	and ($5 = '' or namePrefixIndex = $5)
+	and lower(pgp_sym_decrypt(name::bytea, '` + enKey + `')) like $6
+	order BY createdOn DESC limit 20
+	`
+	return repo.queryFiles(ctx, query, actor.IsAdmin(), actor.ID, strconv.FormatInt(actor.ID, 10),
+		pq.Array(actor.Groups()), bucket, pattern)
+}
+
+// GetFilesByRefID method returns up to limit readable files attached to refID, oldest first
+func (repo *FileRepository) GetFilesByRefID(ctx context.Context, enKey string, actor models.Actor, refID string, limit int) ([]models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetFilesByRefID")
+	defer span.End()
+
+	query := `
+	select ` + fileColumns(enKey) + `
+	from files
+	where ` + readableFilter + `
+	and refId = $5
+	order BY createdOn, id limit $6
+	`
+	return repo.queryFiles(ctx, query, actor.IsAdmin(), actor.ID, strconv.FormatInt(actor.ID, 10),
+		pq.Array(actor.Groups()), refID, limit)
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ReindexNames method recomputes the blind indexes of up to limit rows written
+// before the indexes existed or with another key, returning how many changed
+func (repo *FileRepository) ReindexNames(ctx context.Context, enKey string, limit int) (int, error) {
+	ctx, span := startSpan(ctx, "FileRepository.ReindexNames")
+	defer span.End()
+
+	if !repo.index.Enabled() {
+		return 0, nil
+	}
+
+	query := `
+	select id, pgp_sym_decrypt(name::bytea, '` + enKey + `')
+	from files
+	where indexKeyId <> $1
+	limit $2
+	for update skip locked
+	`
+	statement := `
+	update files
+	set nameIndex=$2, namePrefixIndex=$3, indexKeyId=$4
+	where id=$1
+	`
+	count := 0
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, repo.index.KeyID(), limit)
+		if err != nil {
+			return err
+		}
+		names := map[string]string{}
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			names[id] = name
+		}
+		_ = rows.Close()
+
+		for id, name := range names {
+			err := execAffected(ctx, tx, statement, id, repo.index.Exact(nameField, name),
+				repo.index.Prefix(nameField, name), repo.index.KeyID())
+			if err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Delete method removes the file and its storage usage
 func (repo *FileRepository) Delete(ctx context.Context, enKey string, id string) error {
+	ctx, span := startSpan(ctx, "FileRepository.Delete")
+	defer span.End()
+
 	query := `
     delete from files
     where id=$1
+	returning tenantId, actorId, size
   	`
-	deleted := repo.db.Delete(ctx, query, id)
-	if !deleted {
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		var tenantID string
+		var actorID, size int64
+		err := tx.QueryRowContext(ctx, query, id).Scan(&tenantID, &actorID, &size)
+		if err != nil {
+			return err
+		}
+		return addUsage(ctx, tx, tenantID, actorID, -size, -1, nil)
+	})
+	if err != nil {
 		return errors.New("update file failed")
 	}
 
@@ -157,14 +490,18 @@ func (repo *FileRepository) getFileCache(key string) (bool, models.File) {
 	var data models.File
 	if x, found := repo.cache.Get(key); found {
 		data = x.(models.File)
+		cacheRequests.Inc("hit")
 		return found, data
 	}
+	cacheRequests.Inc("miss")
 	return false, data
 }
 
 // setFileCache method set cache for file
 func (repo *FileRepository) setFileCache(key string, file models.File) {
-	if file != (models.File{}) {
+	if file.ID != "" {
+		fileCacheLock.Lock()
+		defer fileCacheLock.Unlock()
 		fileRepositoryCacheKeys = append(fileRepositoryCacheKeys, key)
 		repo.cache.Set(key, file, 5*time.Minute)
 	}
@@ -175,14 +512,18 @@ func (repo *FileRepository) getFilesCache(key string) (bool, []models.File) {
 	var data []models.File
 	if x, found := repo.cache.Get(key); found {
 		data = x.([]models.File)
+		cacheRequests.Inc("hit")
 		return found, data
 	}
+	cacheRequests.Inc("miss")
 	return false, data
 }
 
 // setFileCache method set cache for files
 func (repo *FileRepository) setFilesCache(key string, files []models.File) {
 	if len(files) > 0 {
+		fileCacheLock.Lock()
+		defer fileCacheLock.Unlock()
 		fileRepositoryCacheKeys = append(fileRepositoryCacheKeys, key)
 		repo.cache.Set(key, files, 10*time.Minute)
 	}
@@ -190,21 +531,78 @@ func (repo *FileRepository) setFilesCache(key string, files []models.File) {
 
 // deleteCache method to delete
 func (repo *FileRepository) deleteCache() {
+	deleteFileCache(repo.cache)
+}
+
+// deleteFileCache drops every cached file query
+func deleteFileCache(c *cache.Cache) {
+	fileCacheLock.Lock()
+	defer fileCacheLock.Unlock()
 	if len(fileRepositoryCacheKeys) > 0 {
 		for i := 0; i < len(fileRepositoryCacheKeys); i++ {
-			repo.cache.Delete(fileRepositoryCacheKeys[i])
+			c.Delete(fileRepositoryCacheKeys[i])
 		}
 		fileRepositoryCacheKeys = []string{}
 	}
 }
 
+// queryFiles runs a files query within the tenant of ctx
+func (repo *FileRepository) queryFiles(ctx context.Context, query string, args ...interface{}) ([]models.File, error) {
+	var result []models.File
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		result, err = getFilesFromRows(rows)
+		return err
+	})
+	return result, err
+}
+
+// fileColumns lists the columns read by scanFile
+func fileColumns(enKey string) string {
+	return `files.id, coalesce(files.refId, ''), pgp_sym_decrypt(files.name::bytea, '` + enKey + `'),
+		files.extension, files.size, files.status, files.actorId, files.origin, files.tenantId,
+		files.mimeType, files.createdOn, files.metadata, coalesce(files.parentId, ''), files.stages,
+		coalesce(files.checksum, ''), coalesce(files.problem, ''), files.verifiedOn,
+		coalesce(pgp_sym_decrypt(files.originalName::bytea, '` + enKey + `'), '')`
+}
+
+// scanner is a row of fileColumns
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanFile reads a row of fileColumns followed by extra destinations
+func scanFile(row scanner, extra ...interface{}) (models.File, error) {
+	var file models.File
+	var metadata, stages []byte
+	var verifiedOn sql.NullTime
+	dest := []interface{}{&file.ID, &file.RefID, &file.Name, &file.Extension, &file.Size,
+		&file.Status, &file.ActorID, &file.Origin, &file.TenantID, &file.MimeType, &file.CreatedOn, &metadata,
+		&file.ParentID, &stages, &file.Checksum, &file.Problem, &verifiedOn,
+		&file.OriginalName}
+	err := row.Scan(append(dest, extra...)...)
+	file.VerifiedOn = verifiedOn.Time
+	if err == nil && len(metadata) > 0 {
+		_ = json.Unmarshal(metadata, &file.Metadata)
+	}
+	if err == nil && len(stages) > 0 {
+		_ = json.Unmarshal(stages, &file.Stages)
+	}
+	file.Ready = models.StagesReady(file.Stages)
+	return file, err
+}
+
 // prepare files row
 func getFilesFromRows(rows *sql.Rows) ([]models.File, error) {
 	files := []models.File{}
 	for rows.Next() {
-		var file models.File
-		err := rows.Scan(&file.ID, &file.Name, &file.Extension,
-			&file.Size, &file.Status, &file.CreatedOn)
+		file, err := scanFile(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -216,22 +614,162 @@ func getFilesFromRows(rows *sql.Rows) ([]models.File, error) {
 
 // GetFilesByStatus method
 func (repo *FileRepository) GetFilesByStatus(ctx context.Context, enKey string, status string) ([]models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetFilesByStatus")
+	defer span.End()
+
 	query := `
-	select id, typeId, pgp_sym_decrypt(name::bytea, '` + enKey + `'), extension, size, status, createdOn
+	select ` + fileColumns(enKey) + `
 	from files
 	where status = $1
 	`
 
-	rows, err := repo.db.Query(ctx, query, status)
+	result, err := repo.queryFiles(ctx, query, status)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = rows.Close()
-	}()
-	result, err := getFilesFromRows(rows)
+	return result, nil
+}
+
+// ListFiles method returns the files of every tenant matching the query in
+// order of id, the context must reach the tenants queried
+func (repo *FileRepository) ListFiles(ctx context.Context, enKey string, query models.FileQuery) ([]models.File, error) {
+	ctx, span := startSpan(ctx, "FileRepository.ListFiles")
+	defer span.End()
+
+	statement := `
+	select ` + fileColumns(enKey) + `
+	from files
+	where ($1 = '' or tenantId = $1)
+	and ($2 = '' or status = $2)
+	and ($3::timestamp is null or createdOn < $3::timestamp)
+	and id > $4
+	and ($6 = '' or problem = $6)
+	order BY id ASC
+	limit $5
+	`
+	result, err := repo.queryFiles(ctx, statement, query.TenantID, query.Status, nullTime(query.Before),
+		query.AfterID, query.Limit, query.Problem)
 	if err != nil {
 		return nil, err
 	}
 	return result, nil
 }
+
+// GetFileIDs method returns the ids of every file after afterID in order of
+// id, without reading the encrypted columns
+func (repo *FileRepository) GetFileIDs(ctx context.Context, afterID string, limit int) ([]string, error) {
+	ctx, span := startSpan(ctx, "FileRepository.GetFileIDs")
+	defer span.End()
+
+	query := `
+	select id
+	from files
+	where id > $1
+	order BY id ASC
+	limit $2
+	`
+	ids := []string{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, afterID, limit)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Rekey method encrypts the name and uploaded name of the file with newKey,
+// it fails when the name is not encrypted with oldKey
+func (repo *FileRepository) Rekey(ctx context.Context, oldKey string, newKey string, id string) error {
+	ctx, span := startSpan(ctx, "FileRepository.Rekey")
+	defer span.End()
+
+	statement := `
+	update files
+	set name = pgp_sym_encrypt(pgp_sym_decrypt(name::bytea, $2), $3),
+	originalName = pgp_sym_encrypt(pgp_sym_decrypt(originalName::bytea, $2), $3)
+	where id = $1
+	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, id, oldKey, newKey)
+	})
+	if err != nil {
+		return err
+	}
+	repo.deleteCache()
+	return nil
+}
+
+// Decrypts method reports if the name of the file is encrypted with enKey
+func (repo *FileRepository) Decrypts(ctx context.Context, enKey string, id string) bool {
+	query := `
+	select pgp_sym_decrypt(name::bytea, $2)
+	from files
+	where id = $1
+	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		var name string
+		return tx.QueryRowContext(ctx, query, id, enKey).Scan(&name)
+	})
+	return err == nil
+}
+
+// Import method inserts the file keeping its id, status and checksum and adds
+// it to the storage usage of its tenant and actor. It reports false when a
+// file with the id exists. A parent missing from the database is dropped.
+func (repo *FileRepository) Import(ctx context.Context, enKey string, doc models.File) (bool, error) {
+	ctx, span := startSpan(ctx, "FileRepository.Import")
+	defer span.End()
+
+	statement := `
+	insert into files (id, refId, name, extension, size, status, actorId, origin, tenantId, mimeType,
+		nameIndex, namePrefixIndex, indexKeyId, metadata, parentId, stages, checksum, createdOn, originalName)
+	values ($1, nullif($2, ''), PGP_SYM_ENCRYPT($3, '` + enKey + `'), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+		(select id from files where id = $15), $16, nullif($17, ''), $18, PGP_SYM_ENCRYPT(nullif($19, ''), '` + enKey + `'))
+	on conflict (id) do nothing
+	`
+	metadata, err := json.Marshal(doc.Metadata)
+	if err != nil || doc.Metadata == nil {
+		metadata = []byte("{}")
+	}
+	stages, err := json.Marshal(doc.Stages)
+	if err != nil || doc.Stages == nil {
+		stages = []byte("{}")
+	}
+
+	inserted := false
+	err = inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, statement, doc.ID, doc.RefID, doc.Name, doc.Extension, doc.Size,
+			doc.Status, doc.ActorID, doc.Origin, doc.TenantID, doc.MimeType,
+			repo.index.Exact(nameField, doc.Name), repo.index.Prefix(nameField, doc.Name), repo.index.KeyID(),
+			string(metadata), doc.ParentID, string(stages), doc.Checksum, doc.CreatedOn, doc.OriginalName)
+		if err != nil {
+			return err
+		}
+		if count, err := result.RowsAffected(); err != nil || count == 0 {
+			return err
+		}
+		inserted = true
+		return addUsage(ctx, tx, doc.TenantID, doc.ActorID, doc.Size, 1, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	repo.deleteCache()
+	return inserted, nil
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/greatfocus/gf-document/models"
+	cache "github.com/patrickmn/go-cache"
+)
+
+func TestFileCacheConcurrent(t *testing.T) {
+	repo := &FileRepository{cache: cache.New(time.Minute, time.Minute)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := strconv.Itoa(worker) + "-" + strconv.Itoa(j)
+				repo.setFileCache(key, models.File{ID: key})
+				repo.setFilesCache(key+"s", []models.File{{ID: key}})
+				if j%10 == 0 {
+					deleteFileCache(repo.cache)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	deleteFileCache(repo.cache)
+	if len(fileRepositoryCacheKeys) != 0 {
+		t.Fatalf("fileRepositoryCacheKeys = %d keys, want none", len(fileRepositoryCacheKeys))
+	}
+	if count := repo.cache.ItemCount(); count != 0 {
+		t.Fatalf("cache holds %d items, want none", count)
+	}
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/lib/pq"
+	cache "github.com/patrickmn/go-cache"
+)
+
+// GrantRepository struct
+type GrantRepository struct {
+	conn  *sql.DB
+	cache *cache.Cache
+}
+
+// Init method
+func (repo *GrantRepository) Init(conn *sql.DB, cache *cache.Cache) {
+	repo.conn = conn
+	repo.cache = cache
+}
+
+// Create method
+func (repo *GrantRepository) Create(ctx context.Context, grant models.Grant) (models.Grant, error) {
+	var id = uuid.New().String()
+	statement := `
+    insert into grants (id, fileId, granteeType, granteeId, permission, grantedBy, tenantId)
+    values ($1, $2, $3, $4, $5, $6, $7)
+    on conflict (fileId, granteeType, granteeId, permission) do nothing
+  	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, id, grant.FileID, grant.GranteeType,
+			grant.GranteeID, grant.Permission, grant.GrantedBy, TenantFrom(ctx))
+	})
+	if err != nil {
+		return grant, errors.New("grant already exists")
+	}
+	grant.ID = id
+
+	// listings depend on grants
+	deleteFileCache(repo.cache)
+	return grant, nil
+}
+
+// GetGrants method returns grants of a file
+func (repo *GrantRepository) GetGrants(ctx context.Context, fileID string) ([]models.Grant, error) {
+	query := `
+	select id, fileId, granteeType, granteeId, permission, grantedBy, createdOn
+	from grants
+	where fileId = $1
+	order BY createdOn ASC
+	`
+	grants := []models.Grant{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, fileID)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var grant models.Grant
+			err := rows.Scan(&grant.ID, &grant.FileID, &grant.GranteeType, &grant.GranteeID,
+				&grant.Permission, &grant.GrantedBy, &grant.CreatedOn)
+			if err != nil {
+				return err
+			}
+			grants = append(grants, grant)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// HasGrant method checks if the actor or one of its groups holds the permission
+func (repo *GrantRepository) HasGrant(ctx context.Context, fileID string, actor models.Actor, permission string) (bool, error) {
+	query := `
+	select count(1)
+	from grants
+	where fileId = $1 and permission = $2
+	and ((granteeType = 'actor' and granteeId = $3)
+	or (granteeType = 'group' and granteeId = any($4)))
+	`
+	var count int64
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, fileID, permission,
+			strconv.FormatInt(actor.ID, 10), pq.Array(actor.Groups())).Scan(&count)
+	})
+	switch err {
+	case sql.ErrNoRows:
+		return false, nil
+	case nil:
+		return count > 0, nil
+	default:
+		return false, err
+	}
+}
+
+// Delete method
+func (repo *GrantRepository) Delete(ctx context.Context, fileID string, id string) error {
+	query := `
+    delete from grants
+    where fileId=$1 and id=$2
+  	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, query, fileID, id)
+	})
+	if err != nil {
+		return errors.New("delete grant failed")
+	}
+
+	deleteFileCache(repo.cache)
+	return nil
+}
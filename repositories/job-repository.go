@@ -0,0 +1,285 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/models"
+	cache "github.com/patrickmn/go-cache"
+)
+
+// JobRepository struct is a durable queue of background jobs. Workers of any
+// instance claim due jobs with FOR UPDATE SKIP LOCKED, and the stage of each
+// job is mirrored on its file in the same transaction.
+type JobRepository struct {
+	conn  *sql.DB
+	cache *cache.Cache
+}
+
+// Init method
+func (repo *JobRepository) Init(conn *sql.DB, cache *cache.Cache) {
+	repo.conn = conn
+	repo.cache = cache
+}
+
+// setStage records the status of a job type on its file
+const setStage = `
+	update files
+	set stages = stages || jsonb_build_object($2::text, $3::text)
+	where id = $1
+	`
+
+// Create method queues a job for its file
+func (repo *JobRepository) Create(ctx context.Context, job models.Job) (models.Job, error) {
+	statement := `
+    insert into jobs (id, fileId, tenantId, type, payload, maxAttempts, runAt)
+    values ($1, $2, $3, $4, $5, $6, $7)
+  	`
+	job.ID = uuid.New().String()
+	job.TenantID = TenantFrom(ctx)
+	job.Status = models.StageQueued
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	payload, err := json.Marshal(job.Payload)
+	if err != nil || job.Payload == nil {
+		payload = []byte("{}")
+	}
+	err = inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		err := execAffected(ctx, tx, statement, job.ID, job.FileID, job.TenantID, job.Type,
+			string(payload), job.MaxAttempts, job.RunAt)
+		if err != nil {
+			return err
+		}
+		return execAffected(ctx, tx, setStage, job.FileID, job.Type, models.StageQueued)
+	})
+	if err != nil {
+		return job, err
+	}
+	deleteFileCache(repo.cache)
+	return job, nil
+}
+
+// Claim method takes the next due job, or a running one whose lease expired
+// with attempts left, and leases it for lease. sql.ErrNoRows is returned when
+// nothing is due.
+func (repo *JobRepository) Claim(ctx context.Context, lease time.Duration) (models.Job, error) {
+	statement := `
+	update jobs
+	set status = 'running', attempts = attempts + 1, lockedUntil = now() + $1 * interval '1 second',
+		updatedOn = now()
+	where id = (
+		select id from jobs
+		where (status = 'queued' and runAt <= now())
+		or (status = 'running' and lockedUntil < now() and attempts < maxAttempts)
+		order BY runAt
+		limit 1
+		for update skip locked
+	)
+	returning id, fileId, tenantId, type, payload, status, attempts, maxAttempts, runAt, coalesce(lastError, '')
+	`
+	job := models.Job{}
+	err := inTenant(WithTenant(ctx, TenantAll), repo.conn, func(tx *sql.Tx) error {
+		var payload []byte
+		err := tx.QueryRowContext(ctx, statement, lease.Seconds()).Scan(&job.ID, &job.FileID, &job.TenantID,
+			&job.Type, &payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &job.LastError)
+		if err != nil {
+			return err
+		}
+		_ = json.Unmarshal(payload, &job.Payload)
+		_, err = tx.ExecContext(ctx, setStage, job.FileID, job.Type, models.StageRunning)
+		return err
+	})
+	if err != nil {
+		return job, err
+	}
+	deleteFileCache(repo.cache)
+	return job, nil
+}
+
+// Expire method marks failed the running jobs of every tenant whose lease
+// expired after their last attempt, a worker stopped on each of them, and
+// returns how many were failed
+func (repo *JobRepository) Expire(ctx context.Context) (int64, error) {
+	statement := `
+	update jobs
+	set status = 'failed', lockedUntil = null,
+		lastError = 'lease expired after ' || attempts || ' attempts', updatedOn = now()
+	where status = 'running' and lockedUntil < now() and attempts >= maxAttempts
+	returning fileId, type
+	`
+	var count int64
+	err := inTenant(WithTenant(ctx, TenantAll), repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		stages := [][2]string{}
+		for rows.Next() {
+			var stage [2]string
+			if err := rows.Scan(&stage[0], &stage[1]); err != nil {
+				return err
+			}
+			stages = append(stages, stage)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, stage := range stages {
+			if _, err := tx.ExecContext(ctx, setStage, stage[0], stage[1], models.StageFailed); err != nil {
+				return err
+			}
+		}
+		count = int64(len(stages))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		deleteFileCache(repo.cache)
+	}
+	return count, nil
+}
+
+// Complete method marks the job and its stage done
+func (repo *JobRepository) Complete(ctx context.Context, job models.Job) error {
+	statement := `
+	update jobs
+	set status = 'done', lockedUntil = null, lastError = null, updatedOn = now()
+	where id = $1
+	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		if err := execAffected(ctx, tx, statement, job.ID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, setStage, job.FileID, job.Type, models.StageDone)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	deleteFileCache(repo.cache)
+	return nil
+}
+
+// Fail method queues the job again at retryAt, or marks it and its stage
+// failed once it has used all of its attempts. The status set is returned.
+func (repo *JobRepository) Fail(ctx context.Context, job models.Job, cause error, retryAt time.Time) (string, error) {
+	statement := `
+	update jobs
+	set status = case when attempts >= maxAttempts then 'failed' else 'queued' end,
+		runAt = $2, lockedUntil = null, lastError = $3, updatedOn = now()
+	where id = $1
+	returning status
+	`
+	var status string
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, statement, job.ID, retryAt, cause.Error()).Scan(&status)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, setStage, job.FileID, job.Type, status)
+		return err
+	})
+	if err != nil {
+		return status, err
+	}
+	deleteFileCache(repo.cache)
+	return status, nil
+}
+
+// GetJobs method returns the latest job of each type queued for a file
+func (repo *JobRepository) GetJobs(ctx context.Context, fileID string) ([]models.Job, error) {
+	query := `
+	select distinct on (type) id, fileId, tenantId, type, status, attempts, maxAttempts, runAt,
+		coalesce(lastError, ''), createdOn, updatedOn
+	from jobs
+	where fileId = $1
+	order BY type, createdOn DESC
+	`
+	jobs := []models.Job{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, fileID)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var job models.Job
+			err := rows.Scan(&job.ID, &job.FileID, &job.TenantID, &job.Type, &job.Status, &job.Attempts,
+				&job.MaxAttempts, &job.RunAt, &job.LastError, &job.CreatedOn, &job.UpdatedOn)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Stats method counts the due and running jobs of every type with the age of the
+// oldest, how far the workers are behind
+func (repo *JobRepository) Stats(ctx context.Context) ([]models.JobStats, error) {
+	query := `
+	select type, status, count(1), coalesce(max(extract(epoch from now() - runAt)), 0)
+	from jobs
+	where (status = 'queued' and runAt <= now()) or status = 'running'
+	group BY type, status
+	`
+	stats := []models.JobStats{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var stat models.JobStats
+			if err := rows.Scan(&stat.Type, &stat.Status, &stat.Count, &stat.OldestSeconds); err != nil {
+				return err
+			}
+			stats = append(stats, stat)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Purge method removes done jobs last changed before the time
+func (repo *JobRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	statement := `
+	delete from jobs
+	where status = 'done' and updatedOn < $1
+	`
+	var count int64
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, statement, before)
+		if err != nil {
+			return err
+		}
+		count, err = res.RowsAffected()
+		return err
+	})
+	return count, err
+}
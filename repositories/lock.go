@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+)
+
+// Lock struct is a postgres advisory lock held by a session of its own, so
+// one instance at a time runs the work it guards
+type Lock struct {
+	conn *sql.Conn
+	name string
+}
+
+// TryLock takes the lock named name, returning nil when another session holds it
+func TryLock(ctx context.Context, conn *sql.DB, name string) (*Lock, error) {
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err := c.QueryRowContext(ctx, "select pg_try_advisory_lock(hashtext($1))", name).Scan(&locked); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	if !locked {
+		_ = c.Close()
+		return nil, nil
+	}
+	return &Lock{conn: c, name: name}, nil
+}
+
+// Release unlocks and returns the connection to the pool, when the lock cannot
+// be released the connection is discarded so closing the session releases it
+func (l *Lock) Release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := l.conn.ExecContext(ctx, "select pg_advisory_unlock(hashtext($1))", l.name); err != nil {
+		_ = l.conn.Raw(func(interface{}) error {
+			return driver.ErrBadConn
+		})
+	}
+	_ = l.conn.Close()
+}
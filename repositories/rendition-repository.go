@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/models"
+)
+
+// RenditionRepository struct
+type RenditionRepository struct {
+	conn *sql.DB
+}
+
+// Init method
+func (repo *RenditionRepository) Init(conn *sql.DB) {
+	repo.conn = conn
+}
+
+// Create method records a rendition, replacing an earlier one with the same name
+func (repo *RenditionRepository) Create(ctx context.Context, rendition models.Rendition) (models.Rendition, error) {
+	statement := `
+    insert into renditions (id, fileId, name, mimeType, width, height, size, tenantId)
+    values ($1, $2, $3, $4, $5, $6, $7, $8)
+    on conflict (fileId, name) do update
+    set mimeType = excluded.mimeType, width = excluded.width, height = excluded.height,
+    size = excluded.size, createdOn = CURRENT_TIMESTAMP
+    returning id, createdOn
+  	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, statement, uuid.New().String(), rendition.FileID, rendition.Name,
+			rendition.MimeType, rendition.Width, rendition.Height, rendition.Size, TenantFrom(ctx)).
+			Scan(&rendition.ID, &rendition.CreatedOn)
+	})
+	return rendition, err
+}
+
+// GetRendition method returns the named rendition of a file
+func (repo *RenditionRepository) GetRendition(ctx context.Context, fileID string, name string) (models.Rendition, error) {
+	query := `
+	select id, fileId, name, mimeType, width, height, size, createdOn
+	from renditions
+	where fileId = $1 and name = $2
+	`
+	rendition := models.Rendition{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, fileID, name).Scan(&rendition.ID, &rendition.FileID,
+			&rendition.Name, &rendition.MimeType, &rendition.Width, &rendition.Height,
+			&rendition.Size, &rendition.CreatedOn)
+	})
+	return rendition, err
+}
+
+// GetRenditions method returns the renditions of a file
+func (repo *RenditionRepository) GetRenditions(ctx context.Context, fileID string) ([]models.Rendition, error) {
+	query := `
+	select id, fileId, name, mimeType, width, height, size, createdOn
+	from renditions
+	where fileId = $1
+	order BY name ASC
+	`
+	renditions := []models.Rendition{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, fileID)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var rendition models.Rendition
+			err := rows.Scan(&rendition.ID, &rendition.FileID, &rendition.Name, &rendition.MimeType,
+				&rendition.Width, &rendition.Height, &rendition.Size, &rendition.CreatedOn)
+			if err != nil {
+				return err
+			}
+			renditions = append(renditions, rendition)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return renditions, nil
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/greatfocus/gf-document/models"
+	"github.com/lib/pq"
+)
+
+// SearchRepository struct
+type SearchRepository struct {
+	conn *sql.DB
+}
+
+// Init method
+func (repo *SearchRepository) Init(conn *sql.DB) {
+	repo.conn = conn
+}
+
+// Index method stores the text of a file weighting metadata above content
+func (repo *SearchRepository) Index(ctx context.Context, fileID string, language string, metadata string, content string) error {
+	statement := `
+	insert into search (fileId, tenantId, language, metadata, content, searchVector)
+	values ($1, $2, $3::regconfig, $4, $5,
+		setweight(to_tsvector($3::regconfig, $4), 'A') || setweight(to_tsvector($3::regconfig, $5), 'B'))
+	on conflict (fileId) do update
+	set language = excluded.language,
+		metadata = excluded.metadata,
+		content = excluded.content,
+		searchVector = excluded.searchVector,
+		updatedOn = CURRENT_TIMESTAMP
+	`
+	return inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, fileID, TenantFrom(ctx), language, metadata, content)
+	})
+}
+
+// Search method ranks the files the actor may read against the query
+func (repo *SearchRepository) Search(ctx context.Context, enKey string, actor models.Actor, query models.SearchQuery) (models.SearchPage, error) {
+	statement := `
+	select ` + fileColumns(enKey) + `,
+		ts_rank_cd(s.searchVector, q.query) as searchRank,
+		ts_headline(s.language, case when s.content <> '' then s.content else s.metadata end, q.query,
+			'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2'),
+		count(1) over()
+	from search s
+	join files on files.id = s.fileId
+	cross join websearch_to_tsquery($5::regconfig, $6) as q(query)
+	where ` + readableFilter + `
+	and s.searchVector @@ q.query
+	and ($7 = '' or files.status = $7)
+	and ($8 = '' or files.extension = $8)
+	and ($9 = '' or files.mimeType = $9)
+	and ($10 = '' or files.refId = $10)
+	and ($11::timestamp is null or files.createdOn >= $11::timestamp)
+	and ($12::timestamp is null or files.createdOn < $12::timestamp)
+	order BY searchRank DESC, files.createdOn DESC
+	limit $13 offset $14
+	`
+	page := models.SearchPage{Results: []models.SearchResult{}, Limit: query.Limit, Offset: query.Offset}
+	args := []interface{}{actor.IsAdmin(), actor.ID, strconv.FormatInt(actor.ID, 10), pq.Array(actor.Groups()),
+		query.Language, query.Query, query.Status, query.Extension, query.MimeType, query.RefID,
+		nullTime(query.From), nullTime(query.To), query.Limit, query.Offset}
+
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, statement, args...)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			result := models.SearchResult{}
+			result.File, err = scanFile(rows, &result.Rank, &result.Snippet, &page.Total)
+			if err != nil {
+				return err
+			}
+			page.Results = append(page.Results, result)
+		}
+		return rows.Err()
+	})
+	return page, err
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/tracing"
+	"github.com/greatfocus/gf-sframe/server"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// TenantAll lets system jobs see the rows of every tenant
+const TenantAll = "*"
+
+// tenantKey is the context key of the tenant
+type tenantKey struct{}
+
+// errMissingTenant is returned when a statement runs without a tenant
+var errMissingTenant = errors.New("missing tenant")
+
+// WithTenant scopes the repositories called with ctx to the tenant
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFrom returns the tenant ctx is scoped to
+func TenantFrom(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
+
+// inTenant runs fn in a transaction where SET LOCAL app.tenant_id
+// enables the row level security policies of the tenant tables
+func inTenant(ctx context.Context, conn *sql.DB, fn func(tx *sql.Tx) error) error {
+	tenantID := TenantFrom(ctx)
+	if tenantID == "" {
+		return errMissingTenant
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "SET LOCAL app.tenant_id = "+pq.QuoteLiteral(tenantID))
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		if err != sql.ErrNoRows {
+			tracing.SpanFrom(ctx).RecordError(err)
+		}
+		// services answer with generic errors, the cause is logged here with the request
+		if err != sql.ErrNoRows && err != ErrQuotaExceeded {
+			logging.From(ctx).WithError(err).WithField("tenant_id", tenantID).Warn("database statement failed")
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// startSpan traces the database calls of a repository method
+func startSpan(ctx context.Context, name string) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, name, tracing.KindClient, tracing.String("db.system", "postgresql"))
+}
+
+// execAffected executes the statement and fails when no row changed
+func execAffected(ctx context.Context, tx *sql.Tx, statement string, args ...interface{}) error {
+	res, err := tx.ExecContext(ctx, statement, args...)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count < 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Connect opens the pool used for tenant scoped transactions.
+// gf-sframe's database.Database has no transactions so the
+// connection settings it reads are repeated here.
+func Connect(logger *logrus.Logger) *sql.DB {
+	logger.Info("Creating transaction database connection")
+	port, err := strconv.ParseUint(os.Getenv("DB_PORT"), 0, 64)
+	if err != nil {
+		log.Fatal(fmt.Println(err))
+	}
+	maxLifetime, err := strconv.ParseUint(os.Getenv("DB_MaxLifetime"), 0, 64)
+	if err != nil {
+		log.Fatal(fmt.Println(err))
+	}
+	maxIdleConns, err := strconv.ParseInt(os.Getenv("DB_MaxIdleConns"), 0, 64)
+	if err != nil {
+		log.Fatal(fmt.Println(err))
+	}
+	maxOpenConns, err := strconv.ParseInt(os.Getenv("DB_MaxOpenConns"), 0, 64)
+	if err != nil {
+		log.Fatal(fmt.Println(err))
+	}
+
+	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s",
+		os.Getenv("DB_HOST"), port, os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+	if os.Getenv("DB_SSL_KEY") != "" && os.Getenv("DB_SSL_CERT") != "" {
+		key := server.CreateSSLCert("postgresql-client.key", os.Getenv("DB_SSL_KEY"))
+		cert := server.CreateSSLCert("postgresql-client.crt", os.Getenv("DB_SSL_CERT"))
+		psqlInfo += fmt.Sprintf(" sslkey=%s sslcert=%s", key, cert)
+		if os.Getenv("DB_ROOT_CA") != "" {
+			ca := server.CreateSSLCert("postgresql-ca.crt", os.Getenv("DB_ROOT_CA"))
+			psqlInfo += fmt.Sprintf(" sslmode=verify-full sslrootcert=%s", ca)
+		} else {
+			psqlInfo += " sslmode=require"
+		}
+	} else {
+		psqlInfo += " sslmode=require"
+	}
+
+	conn, err := sql.Open("postgres", psqlInfo)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err = conn.Ping(); err != nil {
+		logger.Fatal(err)
+	}
+	conn.SetConnMaxLifetime(time.Duration(maxLifetime) * time.Minute)
+	conn.SetMaxIdleConns(int(maxIdleConns))
+	conn.SetMaxOpenConns(int(maxOpenConns))
+	logger.Info("Transaction database connection successful")
+	return conn
+}
+
+// nullTime passes zero times as NULL
+func nullTime(value time.Time) sql.NullTime {
+	return sql.NullTime{Time: value, Valid: !value.IsZero()}
+}
@@ -0,0 +1,220 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/database"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// rlsTestRole runs the statements when DATABASE_URL connects as a role that
+// bypasses row level security, such as a superuser
+const rlsTestRole = "gf_document_rls_test"
+
+// tenantDB connects to DATABASE_URL with the schema migrated. The returned
+// pool holds a single connection so a role set on it stays for the test.
+func tenantDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	migrator, err := database.NewMigrator(admin, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+
+	var bypass bool
+	err = conn.QueryRowContext(ctx, "select rolsuper or rolbypassrls from pg_roles where rolname = current_user").Scan(&bypass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bypass {
+		return conn
+	}
+	statements := []string{
+		`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = '` + rlsTestRole + `') THEN
+				CREATE ROLE ` + rlsTestRole + ` NOLOGIN;
+			END IF;
+		END $$`,
+		"GRANT USAGE ON SCHEMA public TO " + rlsTestRole,
+		"GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO " + rlsTestRole,
+		"SET ROLE " + rlsTestRole,
+	}
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conn
+}
+
+// insertFile stores a file of the tenant of ctx for tenantID
+func insertFile(ctx context.Context, conn *sql.DB, id string, tenantID string) error {
+	statement := `
+	insert into files (id, name, extension, size, status, actorId, origin, tenantId)
+	values ($1, PGP_SYM_ENCRYPT($1, 'secret'), '.png', 1, 'new', 1, 'test', $2)
+	`
+	return inTenant(ctx, conn, func(tx *sql.Tx) error {
+		return execAffected(ctx, tx, statement, id, tenantID)
+	})
+}
+
+// visibleFiles returns the ids of the files the tenant of ctx sees
+func visibleFiles(ctx context.Context, conn *sql.DB, ids ...string) ([]string, error) {
+	visible := []string{}
+	err := inTenant(ctx, conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "select id from files where id = any($1) order BY id", pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			visible = append(visible, id)
+		}
+		return rows.Err()
+	})
+	return visible, err
+}
+
+func TestInTenantIsolation(t *testing.T) {
+	conn := tenantDB(t)
+	run := uuid.New().String()
+	tenantA, tenantB := "tenant-a-"+run, "tenant-b-"+run
+	ctxA := WithTenant(context.Background(), tenantA)
+	ctxB := WithTenant(context.Background(), tenantB)
+	ctxAll := WithTenant(context.Background(), TenantAll)
+	fileA, fileB := "a-"+run, "b-"+run
+	t.Cleanup(func() {
+		_ = inTenant(ctxAll, conn, func(tx *sql.Tx) error {
+			_, err := tx.Exec("delete from files where id = any($1)", pq.Array([]string{fileA, fileB, "c-" + run}))
+			return err
+		})
+	})
+
+	if err := insertFile(ctxA, conn, fileA, tenantA); err != nil {
+		t.Fatalf("tenant a insert error = %v", err)
+	}
+	if err := insertFile(ctxB, conn, fileB, tenantB); err != nil {
+		t.Fatalf("tenant b insert error = %v", err)
+	}
+
+	t.Run("tenant b reads none of tenant a", func(t *testing.T) {
+		visible, err := visibleFiles(ctxB, conn, fileA, fileB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(visible) != 1 || visible[0] != fileB {
+			t.Fatalf("tenant b sees %v, want only %s", visible, fileB)
+		}
+	})
+
+	t.Run("tenant b changes none of tenant a", func(t *testing.T) {
+		for _, statement := range []string{
+			"update files set status = 'approved' where id = $1",
+			"update files set tenantId = '" + tenantB + "' where id = $1",
+			"delete from files where id = $1",
+		} {
+			err := inTenant(ctxB, conn, func(tx *sql.Tx) error {
+				return execAffected(ctxB, tx, statement, fileA)
+			})
+			if err != sql.ErrNoRows {
+				t.Fatalf("%s error = %v, want %v", statement, err, sql.ErrNoRows)
+			}
+		}
+		var status, tenantID string
+		err := inTenant(ctxA, conn, func(tx *sql.Tx) error {
+			return tx.QueryRow("select status, tenantId from files where id = $1", fileA).Scan(&status, &tenantID)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != "new" || tenantID != tenantA {
+			t.Fatalf("file of tenant a is %s of %s, want new of %s", status, tenantID, tenantA)
+		}
+	})
+
+	t.Run("tenant b creates nothing for tenant a", func(t *testing.T) {
+		err := insertFile(ctxB, conn, "c-"+run, tenantA)
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) || pqErr.Code != "42501" {
+			t.Fatalf("insert error = %v, want a row level security violation", err)
+		}
+	})
+
+	t.Run("tenant a reads only its own", func(t *testing.T) {
+		visible, err := visibleFiles(ctxA, conn, fileA, fileB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(visible) != 1 || visible[0] != fileA {
+			t.Fatalf("tenant a sees %v, want only %s", visible, fileA)
+		}
+	})
+
+	t.Run("missing tenant is refused", func(t *testing.T) {
+		_, err := visibleFiles(context.Background(), conn, fileA, fileB)
+		if err != errMissingTenant {
+			t.Fatalf("error = %v, want %v", err, errMissingTenant)
+		}
+	})
+
+	t.Run("TenantAll sees and changes every tenant", func(t *testing.T) {
+		visible, err := visibleFiles(ctxAll, conn, fileA, fileB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(visible) != 2 {
+			t.Fatalf("TenantAll sees %v, want %s and %s", visible, fileA, fileB)
+		}
+		var count int64
+		err = inTenant(ctxAll, conn, func(tx *sql.Tx) error {
+			res, err := tx.Exec("update files set status = 'approved' where id = any($1)", pq.Array([]string{fileA, fileB}))
+			if err != nil {
+				return err
+			}
+			count, err = res.RowsAffected()
+			return err
+		})
+		if err != nil || count != 2 {
+			t.Fatalf("TenantAll updated %d files, error %v, want 2", count, err)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/greatfocus/gf-document/models"
+)
+
+// ErrQuotaExceeded is returned when a change would go over a hard quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// UsageRepository struct
+type UsageRepository struct {
+	conn *sql.DB
+}
+
+// Init method
+func (repo *UsageRepository) Init(conn *sql.DB) {
+	repo.conn = conn
+}
+
+// GetUsage method returns the usage of the tenant of ctx and the actor
+func (repo *UsageRepository) GetUsage(ctx context.Context, actorID int64) (models.Usage, models.Usage, error) {
+	tenant := models.Usage{Scope: models.UsageTenant, TenantID: TenantFrom(ctx)}
+	actor := models.Usage{Scope: models.UsageActor, TenantID: TenantFrom(ctx), ActorID: actorID}
+	query := `
+	select bytes, files
+	from storage_usage
+	where tenantId = $1 and scope = $2 and actorId = $3
+	`
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, tenant.TenantID, tenant.Scope, 0).Scan(&tenant.Bytes, &tenant.Files)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, query, actor.TenantID, actor.Scope, actorID).Scan(&actor.Bytes, &actor.Files)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		return nil
+	})
+	return tenant, actor, err
+}
+
+// GetActorsUsage method returns the usage of every actor of the tenant of ctx
+func (repo *UsageRepository) GetActorsUsage(ctx context.Context) ([]models.Usage, error) {
+	query := `
+	select tenantId, actorId, bytes, files
+	from storage_usage
+	where tenantId = $1 and scope = 'actor'
+	order BY bytes DESC
+	`
+	usages := []models.Usage{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, TenantFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			usage := models.Usage{Scope: models.UsageActor}
+			err := rows.Scan(&usage.TenantID, &usage.ActorID, &usage.Bytes, &usage.Files)
+			if err != nil {
+				return err
+			}
+			usages = append(usages, usage)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// GetTenantsUsage method returns the usage of every tenant ctx may see
+func (repo *UsageRepository) GetTenantsUsage(ctx context.Context) ([]models.Usage, error) {
+	query := `
+	select tenantId, bytes, files
+	from storage_usage
+	where scope = 'tenant'
+	order BY tenantId
+	`
+	usages := []models.Usage{}
+	err := inTenant(ctx, repo.conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		for rows.Next() {
+			usage := models.Usage{Scope: models.UsageTenant}
+			if err := rows.Scan(&usage.TenantID, &usage.Bytes, &usage.Files); err != nil {
+				return err
+			}
+			usages = append(usages, usage)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// addUsage adds bytes and files to the tenant and actor totals within tx and
+// fails with ErrQuotaExceeded when a growing total goes over its hard quota
+func addUsage(ctx context.Context, tx *sql.Tx, tenantID string, actorID int64, bytes int64, files int64, quotas map[string]models.Quota) error {
+	statement := `
+	insert into storage_usage (tenantId, scope, actorId, bytes, files)
+	values ($1, $2, $3, greatest($4::bigint, 0), greatest($5::bigint, 0))
+	on conflict (tenantId, scope, actorId) do update
+	set bytes = greatest(storage_usage.bytes + $4, 0),
+		files = greatest(storage_usage.files + $5, 0),
+		updatedOn = CURRENT_TIMESTAMP
+	returning bytes, files
+	`
+	scopes := []models.Usage{
+		{Scope: models.UsageTenant, TenantID: tenantID},
+		{Scope: models.UsageActor, TenantID: tenantID, ActorID: actorID},
+	}
+	for _, usage := range scopes {
+		err := tx.QueryRowContext(ctx, statement, usage.TenantID, usage.Scope, usage.ActorID, bytes, files).
+			Scan(&usage.Bytes, &usage.Files)
+		if err != nil {
+			return err
+		}
+		if bytes > 0 || files > 0 {
+			usage.ApplyQuota(quotas[usage.Scope])
+			if usage.HardExceeded {
+				return ErrQuotaExceeded
+			}
+		}
+	}
+	return nil
+}
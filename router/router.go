@@ -1,6 +1,7 @@
 package router
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"time"
@@ -8,30 +9,134 @@ import (
 	"github.com/greatfocus/gf-document/services"
 
 	"github.com/greatfocus/gf-document/handler"
+	"github.com/greatfocus/gf-document/lifecycle"
 	"github.com/greatfocus/gf-sframe/server"
 )
 
 // Router is exported and used in main.go
-func LoadRouter(s *server.Server) *http.ServeMux {
+func LoadRouter(s *server.Server, conn *sql.DB, gate *lifecycle.Gate) *http.ServeMux {
 	mux := http.NewServeMux()
-	loadHandlers(mux, s)
+	loadHandlers(mux, s, conn, gate)
 	s.Logger.Info(fmt.Sprintln("Created routes with handler"))
 	return mux
 }
 
 // documentRoute created all routes and handlers relating to document controller
-func loadHandlers(mux *http.ServeMux, s *server.Server) {
+func loadHandlers(mux *http.ServeMux, s *server.Server, conn *sql.DB, gate *lifecycle.Gate) {
 	// initialize services
 	fileService := services.FileService{}
-	fileService.Init(s.Database, s.Cache, s.JWT, s.Logger)
+	fileService.Init(conn, s.Cache, s.JWT)
 
 	fileHandler := handler.File{}
 	fileHandler.Init(s, &fileService)
 	mux.Handle("/document/file", server.Use(fileHandler,
+		handler.Instrument("/document/file"),
+		handler.Correlate(s.Logger, "/document/file"),
 		server.SetHeaders(),
 		server.CheckThrottle(),
 		server.CheckCors(),
 		server.CheckAllowedIPs(),
 		server.ProcessTimeout(time.Duration(s.Timeout)),
-		server.WithoutAuth()))
+		handler.Admit(gate),
+		handler.Authenticate(s.JWT)))
+
+	resourceHandler := handler.Resource{}
+	resourceHandler.Init(s, &fileService)
+	mux.Handle("/document/file/", server.Use(resourceHandler,
+		handler.Instrument("/document/file/"),
+		handler.Correlate(s.Logger, "/document/file/"),
+		server.SetHeaders(),
+		server.CheckThrottle(),
+		server.CheckCors(),
+		server.CheckAllowedIPs(),
+		server.ProcessTimeout(time.Duration(s.Timeout)),
+		handler.Admit(gate),
+		handler.Authenticate(s.JWT)))
+
+	usageHandler := handler.Usage{}
+	usageHandler.Init(s, &fileService)
+	mux.Handle("/document/usage", server.Use(usageHandler,
+		handler.Instrument("/document/usage"),
+		handler.Correlate(s.Logger, "/document/usage"),
+		server.SetHeaders(),
+		server.CheckThrottle(),
+		server.CheckCors(),
+		server.CheckAllowedIPs(),
+		server.ProcessTimeout(time.Duration(s.Timeout)),
+		handler.Admit(gate),
+		handler.Authenticate(s.JWT)))
+
+	searchHandler := handler.Search{}
+	searchHandler.Init(s, &fileService)
+	mux.Handle("/document/search", server.Use(searchHandler,
+		handler.Instrument("/document/search"),
+		handler.Correlate(s.Logger, "/document/search"),
+		server.SetHeaders(),
+		server.CheckThrottle(),
+		server.CheckCors(),
+		server.CheckAllowedIPs(),
+		server.ProcessTimeout(time.Duration(s.Timeout)),
+		handler.Admit(gate),
+		handler.Authenticate(s.JWT)))
+
+	iiifHandler := handler.IIIF{}
+	iiifHandler.Init(s, &fileService)
+	mux.Handle("/document/iiif/", server.Use(iiifHandler,
+		handler.Instrument("/document/iiif/"),
+		handler.Correlate(s.Logger, "/document/iiif/"),
+		server.SetHeaders(),
+		server.CheckThrottle(),
+		server.CheckCors(),
+		server.CheckAllowedIPs(),
+		server.ProcessTimeout(time.Duration(s.Timeout)),
+		handler.Admit(gate),
+		handler.Authenticate(s.JWT)))
+
+	// bulk downloads stream for as long as the files take, so they are not cut off by ProcessTimeout
+	// and the handler moves the write deadline of the server forward on every write
+	archiveHandler := handler.Archive{}
+	archiveHandler.Init(s, &fileService)
+	mux.Handle("/document/archive", server.Use(archiveHandler,
+		handler.Instrument("/document/archive"),
+		handler.Correlate(s.Logger, "/document/archive"),
+		server.SetHeaders(),
+		server.CheckThrottle(),
+		server.CheckCors(),
+		server.CheckAllowedIPs(),
+		handler.Admit(gate),
+		handler.Authenticate(s.JWT)))
+
+	auditHandler := handler.Audit{}
+	auditHandler.Init(s, &fileService)
+	mux.Handle("/document/audit", server.Use(auditHandler,
+		handler.Instrument("/document/audit"),
+		handler.Correlate(s.Logger, "/document/audit"),
+		server.SetHeaders(),
+		server.CheckThrottle(),
+		server.CheckCors(),
+		server.CheckAllowedIPs(),
+		server.ProcessTimeout(time.Duration(s.Timeout)),
+		handler.Admit(gate),
+		handler.Authenticate(s.JWT)))
+	mux.Handle("/document/audit/verify", server.Use(auditHandler,
+		handler.Instrument("/document/audit/verify"),
+		handler.Correlate(s.Logger, "/document/audit/verify"),
+		server.SetHeaders(),
+		server.CheckThrottle(),
+		server.CheckCors(),
+		server.CheckAllowedIPs(),
+		server.ProcessTimeout(time.Duration(s.Timeout)),
+		handler.Admit(gate),
+		handler.Authenticate(s.JWT)))
+
+	// probes answer without the request middlewares, like gf-sframe's /document/info
+	healthHandler := handler.Health{}
+	healthHandler.Init(s, conn, &fileService, gate)
+	mux.Handle("/document/health/live", healthHandler)
+	mux.Handle("/document/health/ready", healthHandler)
+
+	metricsHandler := handler.Metrics{}
+	metricsHandler.Init(s, &fileService)
+	mux.Handle("/document/metrics", server.Use(metricsHandler,
+		server.CheckAllowedIPs()))
 }
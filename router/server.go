@@ -0,0 +1,55 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/greatfocus/gf-sframe/server"
+)
+
+// Listen serves the routes of s the way gf-sframe's Start does, with the info
+// probe, on a server the caller shuts down. The resource folder Start mounts is
+// left out as it would serve the uploads without authentication. Payloads
+// encrypted with CLIENT_PUBLICKEY need keys only Start loads, and Start gives
+// no handle to shut its server down, so that setting is refused.
+func Listen(s *server.Server) *http.Server {
+	if os.Getenv("CLIENT_PUBLICKEY") != "" {
+		s.Logger.Fatal("CLIENT_PUBLICKEY is set but encrypted payloads cannot be served with a graceful shutdown, unset it")
+	}
+	s.Mux.Handle("/"+s.URI+"/info", http.HandlerFunc(info))
+
+	timeout := time.Duration(s.Timeout) * time.Second
+	srv := &http.Server{
+		Addr:           ":" + os.Getenv("SERVER_PORT"),
+		ReadTimeout:    timeout,
+		WriteTimeout:   timeout,
+		MaxHeaderBytes: 1 << 20,
+		Handler:        s.Mux,
+	}
+	go func() {
+		s.Logger.Info("Listening to port HTTP" + srv.Addr)
+		var err error
+		crt, key := server.GetServerCertificate()
+		if crt != "" && key != "" {
+			err = srv.ListenAndServeTLS(crt, key)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			s.Logger.Fatal(err)
+		}
+	}()
+	return srv
+}
+
+// info answers the liveness probe of gf-sframe
+func info(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Add("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+)
+
+// errForbidden is returned when the actor may not act on a file
+var errForbidden = errors.New("you are not allowed to access file")
+
+// IsForbidden checks if the error was caused by missing access
+func IsForbidden(err error) bool {
+	return errors.Is(err, errForbidden)
+}
+
+// authorize checks the actor may perform permission on the file.
+// Owners and admins may do anything, everyone else needs a grant.
+func (f *FileService) authorize(ctx context.Context, actor models.Actor, file models.File, permission string) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+	if actor.ID != 0 && file.ActorID == actor.ID {
+		return nil
+	}
+
+	granted, err := f.grantRepository.HasGrant(ctx, file.ID, actor, permission)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", file.ID).Error("grants not read")
+		return errForbidden
+	}
+	if !granted {
+		return errForbidden
+	}
+	return nil
+}
+
+// GetGrants method lists who has access to a file
+func (f *FileService) GetGrants(ctx context.Context, enKey string, actor models.Actor, fileID string) ([]models.Grant, error) {
+	ctx = tenantContext(ctx, actor)
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, fileID)
+	if err != nil {
+		return nil, errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantShare); err != nil {
+		return nil, err
+	}
+	return f.grantRepository.GetGrants(ctx, fileID)
+}
+
+// Grant method gives another actor or group access to a file. Owners and
+// admins may grant any permission, actors sharing the file only those they hold.
+func (f *FileService) Grant(ctx context.Context, enKey string, actor models.Actor, grant models.Grant) (models.Grant, error) {
+	ctx = tenantContext(ctx, actor)
+	err := grant.ValidateGrant("add")
+	if err != nil {
+		return grant, err
+	}
+
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, grant.FileID)
+	if err != nil {
+		return grant, errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantShare); err != nil {
+		return grant, err
+	}
+	// sharing passes on a permission the actor holds, it does not raise it
+	if err := f.authorize(ctx, actor, file, grant.Permission); err != nil {
+		return grant, err
+	}
+
+	grant.GrantedBy = actor.ID
+	created, err := f.grantRepository.Create(ctx, grant)
+	if err != nil {
+		return created, err
+	}
+	f.audit(ctx, actor, models.AuditGrant, file, map[string]string{
+		"grantId":     created.ID,
+		"granteeType": created.GranteeType,
+		"granteeId":   created.GranteeID,
+		"permission":  created.Permission,
+	})
+	return created, nil
+}
+
+// Revoke method removes a grant from a file
+func (f *FileService) Revoke(ctx context.Context, enKey string, actor models.Actor, grant models.Grant) error {
+	ctx = tenantContext(ctx, actor)
+	err := grant.ValidateGrant("delete")
+	if err != nil {
+		return err
+	}
+
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, grant.FileID)
+	if err != nil {
+		return errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantShare); err != nil {
+		return err
+	}
+	if err := f.grantRepository.Delete(ctx, grant.FileID, grant.ID); err != nil {
+		return err
+	}
+	f.audit(ctx, actor, models.AuditRevoke, file, map[string]string{"grantId": grant.ID})
+	return nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/repositories"
+	"github.com/greatfocus/gf-document/tracing"
+)
+
+// adminBatch is how many files the admin operations read at a time
+const adminBatch = 500
+
+// inspectAuditLimit bounds the audit events of an inspection
+const inspectAuditLimit = 100
+
+// ListFiles method returns the files of every tenant matching the query for
+// operators, in order of id
+func (f *FileService) ListFiles(ctx context.Context, enKey string, query models.FileQuery) ([]models.FileRecord, error) {
+	ctx, span := tracing.Start(ctx, "FileService.ListFiles", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, models.SystemActor)
+	if query.Limit <= 0 || query.Limit > adminBatch {
+		query.Limit = adminBatch
+	}
+	files, err := f.fileRepository.ListFiles(ctx, enKey, query)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]models.FileRecord, 0, len(files))
+	for _, file := range files {
+		records = append(records, models.NewFileRecord(file))
+	}
+	return records, nil
+}
+
+// eachFile calls fn with the files matching the query a batch at a time until
+// fn fails or the files run out
+func (f *FileService) eachFile(ctx context.Context, enKey string, query models.FileQuery, fn func(file models.File) error) error {
+	ctx = tenantContext(ctx, models.SystemActor)
+	query.Limit = adminBatch
+	for {
+		files, err := f.fileRepository.ListFiles(ctx, enKey, query)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := fn(file); err != nil {
+				return err
+			}
+			query.AfterID = file.ID
+		}
+		if len(files) < adminBatch {
+			return nil
+		}
+	}
+}
+
+// InspectFile method returns everything recorded about a file of any tenant
+// with the location of its content. The inspection is recorded as a view.
+func (f *FileService) InspectFile(ctx context.Context, enKey string, id string) (models.FileInspection, error) {
+	ctx, span := tracing.Start(ctx, "FileService.InspectFile", tracing.KindInternal)
+	defer span.End()
+
+	inspection := models.FileInspection{}
+	file, err := f.fileRepository.GetFileByID(tenantContext(ctx, models.SystemActor), enKey, id)
+	if err != nil {
+		return inspection, errors.New("record does not exist")
+	}
+	inspection.File = models.NewFileRecord(file)
+	if path, found := f.storage.Path(file.TenantID, file.Name); found {
+		inspection.Path = path
+		inspection.Stored = true
+		if info, err := os.Stat(path); err == nil {
+			inspection.StoredSize = info.Size()
+		}
+	}
+
+	// the audit log is read per tenant
+	ctx = repositories.WithTenant(ctx, file.TenantID)
+	if inspection.Grants, err = f.grantRepository.GetGrants(ctx, file.ID); err != nil {
+		return inspection, err
+	}
+	if inspection.Renditions, err = f.renditionRepository.GetRenditions(ctx, file.ID); err != nil {
+		return inspection, err
+	}
+	if inspection.Entries, err = f.entryRepository.GetEntries(ctx, enKey, file.ID); err != nil {
+		return inspection, err
+	}
+	if inspection.Jobs, err = f.jobRepository.GetJobs(ctx, file.ID); err != nil {
+		return inspection, err
+	}
+	inspection.Audit, err = f.auditRepository.GetEvents(ctx, models.AuditQuery{FileID: file.ID, Limit: inspectAuditLimit})
+	if err != nil {
+		return inspection, err
+	}
+	f.audit(ctx, models.SystemActor, models.AuditView, file, nil)
+	return inspection, nil
+}
+
+// VerifyChecksums method hashes the stored content of the files matching the
+// query and calls report with the result of each
+func (f *FileService) VerifyChecksums(ctx context.Context, enKey string, query models.FileQuery, report func(result models.ChecksumResult) error) error {
+	ctx, span := tracing.Start(ctx, "FileService.VerifyChecksums", tracing.KindInternal)
+	defer span.End()
+
+	return f.eachFile(ctx, enKey, query, func(file models.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return report(f.verifyChecksum(file))
+	})
+}
+
+// verifyChecksum compares the stored content of the file with its checksum
+func (f *FileService) verifyChecksum(file models.File) models.ChecksumResult {
+	result := models.ChecksumResult{ID: file.ID, TenantID: file.TenantID, Expected: file.Checksum}
+	path, found := f.storage.Path(file.TenantID, file.Name)
+	if !found {
+		result.Status = models.ChecksumMissing
+		return result
+	}
+	if file.Checksum == "" {
+		result.Status = models.ChecksumUnhashed
+		return result
+	}
+
+	actual, err := hashFile(path)
+	if err != nil {
+		result.Status = models.ChecksumMissing
+		result.Error = err.Error()
+		return result
+	}
+	result.Actual = actual
+	result.Status = models.ChecksumOK
+	if actual != file.Checksum {
+		result.Status = models.ChecksumMismatch
+	}
+	return result
+}
+
+// Rekey method encrypts the names of every file with newKey, the names
+// still encrypted with oldKey are moved and those already moved are skipped
+// so an interrupted run can be repeated. The blind indexes are refreshed after.
+func (f *FileService) Rekey(ctx context.Context, oldKey string, newKey string) (models.RekeyResult, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Rekey", tracing.KindInternal)
+	defer span.End()
+
+	result := models.RekeyResult{}
+	if oldKey == "" || newKey == "" || oldKey == newKey {
+		return result, errors.New("invalid keys")
+	}
+
+	ctx = tenantContext(ctx, models.SystemActor)
+	afterID := ""
+	for {
+		ids, err := f.fileRepository.GetFileIDs(ctx, afterID, adminBatch)
+		if err != nil {
+			return result, err
+		}
+		for _, id := range ids {
+			afterID = id
+			err := f.fileRepository.Rekey(ctx, oldKey, newKey, id)
+			switch {
+			case err == nil:
+				result.Rekeyed++
+			case f.fileRepository.Decrypts(ctx, newKey, id):
+				result.Skipped++
+			default:
+				logging.From(ctx).WithError(err).WithField("file_id", id).Error("file name not rekeyed")
+				result.Failed = append(result.Failed, id)
+			}
+		}
+		if len(ids) < adminBatch {
+			break
+		}
+	}
+
+	reindexed, err := f.ReindexNames(ctx, newKey, adminBatch)
+	result.Reindexed = reindexed
+	return result, err
+}
+
+// Purge method deletes the files matching the policy with their content,
+// reporting each. A dry run only reports them.
+func (f *FileService) Purge(ctx context.Context, enKey string, policy models.FileQuery, dryRun bool, report func(file models.FileRecord) error) error {
+	ctx, span := tracing.Start(ctx, "FileService.Purge", tracing.KindInternal)
+	defer span.End()
+
+	if err := policy.ValidatePurge(); err != nil {
+		return err
+	}
+	ctx = tenantContext(ctx, models.SystemActor)
+
+	// deleting while paging by id is safe, the next page starts after the last id seen
+	return f.eachFile(ctx, enKey, policy, func(file models.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !dryRun {
+			if err := f.removeFile(ctx, enKey, models.SystemActor, file, map[string]string{"reason": "purge"}); err != nil {
+				return err
+			}
+		}
+		return report(models.NewFileRecord(file))
+	})
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+
+	"github.com/greatfocus/gf-document/archive"
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/sirupsen/logrus"
+)
+
+// maxChildBytes bounds the entries exploded into documents, as large as an upload may be
+const maxChildBytes = 10 << 20
+
+// errStopExplode ends the walk of an archive once a child cannot be stored
+var errStopExplode = errors.New("explode stopped")
+
+// inspectArchive checks an archive against the limits and lists its entries.
+// Archives over the limits or with unsafe names are rejected.
+func (f *FileService) inspectArchive(ctx context.Context, doc *models.File, content []byte) ([]archive.Entry, error) {
+	entries, err := archive.Inspect(content, doc.MimeType, f.archiveLimits)
+	if errors.Is(err, archive.ErrLimit) || errors.Is(err, archive.ErrUnsafeName) {
+		return nil, fmt.Errorf("%w: %v", errRejected, err)
+	}
+	if err != nil {
+		// gzip streams that are not tar archives are stored as they are
+		logging.From(ctx).WithError(err).Warn("archive not inspected")
+		return nil, nil
+	}
+
+	if doc.Metadata == nil {
+		doc.Metadata = map[string]string{}
+	}
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	doc.Metadata["entries"] = strconv.Itoa(len(entries))
+	doc.Metadata["uncompressedSize"] = strconv.FormatInt(size, 10)
+	return entries, nil
+}
+
+// recordEntries stores the entry listing of an archive
+func (f *FileService) recordEntries(ctx context.Context, enKey string, doc models.File, entries []archive.Entry) {
+	rows := make([]models.Entry, len(entries))
+	for i, entry := range entries {
+		rows[i] = models.Entry{
+			Position:       i,
+			Name:           entry.Name,
+			Size:           entry.Size,
+			CompressedSize: entry.CompressedSize,
+			ModifiedOn:     entry.ModifiedOn,
+			Directory:      entry.Directory,
+		}
+	}
+	if err := f.entryRepository.Create(ctx, enKey, doc.ID, rows); err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", doc.ID).Error("archive entries not recorded")
+	}
+}
+
+// explode stores every file of an archive as a document of its own linked to
+// the archive, stopping at the first child that goes over a quota. Entries
+// exploded by an earlier attempt are skipped.
+func (f *FileService) explode(ctx context.Context, enKey string, actor models.Actor, parent models.File, content []byte) error {
+	exploded := map[int]bool{}
+	entries, err := f.entryRepository.GetEntries(ctx, enKey, parent.ID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		exploded[entry.Position] = entry.ChildID != ""
+	}
+
+	position := -1
+	err = archive.Walk(content, parent.MimeType, f.archiveLimits, func(entry archive.Entry, r io.Reader) error {
+		position++
+		if entry.Directory || exploded[position] {
+			return nil
+		}
+		if entry.Size > maxChildBytes {
+			logging.From(ctx).WithFields(logrus.Fields{"file_id": parent.ID, "position": position}).Warn("archive entry too large to explode")
+			return nil
+		}
+		data, err := io.ReadAll(io.LimitReader(r, maxChildBytes+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > maxChildBytes {
+			return nil
+		}
+
+		child := models.File{
+			Status:   "new",
+			ActorID:  actor.ID,
+			Origin:   actor.Origin,
+			TenantID: actor.TenantID,
+			ParentID: parent.ID,
+		}
+		child, err = f.ingest(ctx, enKey, child, upload{filename: path.Base(entry.Name), content: data})
+		if IsQuotaExceeded(err) {
+			return errStopExplode
+		}
+		if err != nil {
+			logging.From(ctx).WithError(err).WithFields(logrus.Fields{"file_id": parent.ID, "position": position}).Warn("archive entry not exploded")
+			return nil
+		}
+		if err := f.entryRepository.SetChild(ctx, parent.ID, position, child.ID); err != nil {
+			logging.From(ctx).WithError(err).WithFields(logrus.Fields{"file_id": parent.ID, "child_id": child.ID}).Error("archive child not recorded")
+		}
+		f.audit(ctx, actor, models.AuditUpload, child, map[string]string{"parentId": parent.ID})
+		return nil
+	})
+	if err == errStopExplode {
+		return nil
+	}
+	return err
+}
+
+// GetEntries method lists the entries of an archive
+func (f *FileService) GetEntries(ctx context.Context, enKey string, actor models.Actor, id string) ([]models.Entry, error) {
+	ctx = tenantContext(ctx, actor)
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
+	if err != nil {
+		return nil, errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+		return nil, err
+	}
+	if !archive.Supported(file.MimeType) {
+		return nil, errNotFound
+	}
+	return f.entryRepository.GetEntries(ctx, enKey, file.ID)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/repositories"
+	"github.com/greatfocus/gf-document/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// Audit query limits
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditChainBatch is how many events a verification reads at a time
+const auditChainBatch = 1000
+
+// auditSourceKey is the context key of the source recorded with audit events
+type auditSourceKey struct{}
+
+// WithAuditSource names what started the work in ctx, such as a queue or a
+// scheduled task, so its audit events can be told from API requests
+func WithAuditSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, auditSourceKey{}, source)
+}
+
+// audit appends an event for the file to the audit log of its tenant. The action
+// has already happened so a failure is logged rather than returned.
+func (f *FileService) audit(ctx context.Context, actor models.Actor, action string, file models.File, detail map[string]string) {
+	event := models.AuditEvent{
+		TenantID:  file.TenantID,
+		FileID:    file.ID,
+		ActorID:   actor.ID,
+		Origin:    actor.Origin,
+		Action:    action,
+		RequestID: logging.RequestID(ctx),
+		Detail:    map[string]string{},
+	}
+	if event.TenantID == "" {
+		event.TenantID = actor.TenantID
+	}
+	for key, value := range detail {
+		event.Detail[key] = value
+	}
+	if source, ok := ctx.Value(auditSourceKey{}).(string); ok {
+		event.Detail["source"] = source
+	}
+
+	if _, err := f.auditRepository.Append(ctx, event); err != nil {
+		logging.From(ctx).WithError(err).WithFields(logrus.Fields{
+			"file_id": file.ID,
+			"action":  action,
+		}).Error("audit event not recorded")
+	}
+}
+
+// GetAudit method returns the audit events of the tenant of the actor, admins only
+func (f *FileService) GetAudit(ctx context.Context, actor models.Actor, query models.AuditQuery) (models.AuditPage, error) {
+	ctx, span := tracing.Start(ctx, "FileService.GetAudit", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	if !actor.IsAdmin() {
+		return models.AuditPage{}, errForbidden
+	}
+	if err := query.ValidateAudit(); err != nil {
+		return models.AuditPage{}, err
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultAuditLimit
+	}
+	if query.Limit > maxAuditLimit {
+		query.Limit = maxAuditLimit
+	}
+
+	events, err := f.auditRepository.GetEvents(ctx, query)
+	if err != nil {
+		return models.AuditPage{}, err
+	}
+	return models.AuditPage{Events: events, Limit: query.Limit, Offset: query.Offset}, nil
+}
+
+// VerifyAudit method checks the audit chain of the tenant of the actor, admins
+// only. The system actor checks the chains of every tenant.
+func (f *FileService) VerifyAudit(ctx context.Context, actor models.Actor) ([]models.AuditVerification, error) {
+	ctx, span := tracing.Start(ctx, "FileService.VerifyAudit", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	if !actor.IsAdmin() {
+		return nil, errForbidden
+	}
+
+	tenants := []string{actor.TenantID}
+	if actor.TenantID == repositories.TenantAll {
+		var err error
+		if tenants, err = f.auditRepository.GetTenants(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	results := []models.AuditVerification{}
+	for _, tenantID := range tenants {
+		result, err := f.verifyChain(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		if !result.Valid {
+			logging.From(ctx).WithFields(logrus.Fields{
+				"tenant_id": tenantID,
+				"problems":  len(result.Problems),
+			}).Error("audit chain broken")
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// verifyChain checks the events of a tenant in batches
+func (f *FileService) verifyChain(ctx context.Context, tenantID string) (models.AuditVerification, error) {
+	result := models.NewAuditVerification(tenantID)
+	var after int64
+	for {
+		events, err := f.auditRepository.GetChain(ctx, tenantID, after, auditChainBatch)
+		if err != nil {
+			return result, err
+		}
+		for _, event := range events {
+			result.Check(event)
+			after = event.Seq
+		}
+		if len(events) < auditChainBatch {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/archive"
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/tracing"
+)
+
+// defaultArchiveFiles bounds a bulk download when ARCHIVE_DOWNLOAD_MAX_FILES is not set
+const defaultArchiveFiles = 500
+
+// manifestName is the entry closing every bulk download
+const manifestName = "manifest.json"
+
+// archiveFilesLimit reads the most files a bulk download may hold
+func archiveFilesLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("ARCHIVE_DOWNLOAD_MAX_FILES"))
+	if err != nil || limit <= 0 {
+		return defaultArchiveFiles
+	}
+	return limit
+}
+
+// ArchiveFiles method resolves the files of a bulk download. Listed ids must
+// all be readable, a refId selects the files of the reference the actor may read.
+func (f *FileService) ArchiveFiles(ctx context.Context, enKey string, actor models.Actor, request models.ArchiveRequest) ([]models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.ArchiveFiles", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	if err := request.ValidateArchive(); err != nil {
+		return nil, err
+	}
+	limit := archiveFilesLimit()
+
+	if request.RefID != "" {
+		files, err := f.fileRepository.GetFilesByRefID(ctx, enKey, actor, request.RefID, limit+1)
+		if err != nil {
+			logging.From(ctx).WithError(err).WithField("ref_id", request.RefID).Error("archive files not listed")
+			return nil, errors.New("failed to list files")
+		}
+		if len(files) == 0 {
+			return nil, errors.New("record does not exist")
+		}
+		if len(files) > limit {
+			return nil, fmt.Errorf("archive cannot hold more than %d files", limit)
+		}
+		f.auditArchive(ctx, actor, files, request.RefID)
+		return files, nil
+	}
+
+	files := []models.File{}
+	seen := map[string]bool{}
+	for _, id := range request.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if len(seen) > limit {
+			return nil, fmt.Errorf("archive cannot hold more than %d files", limit)
+		}
+
+		file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
+		if err != nil {
+			return nil, fmt.Errorf("record does not exist: %s", id)
+		}
+		if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
+		}
+		files = append(files, file)
+	}
+	f.auditArchive(ctx, actor, files, "")
+	return files, nil
+}
+
+// auditArchive records the download of every file of a bulk download
+func (f *FileService) auditArchive(ctx context.Context, actor models.Actor, files []models.File, refID string) {
+	detail := map[string]string{"archive": "true"}
+	if refID != "" {
+		detail["refId"] = refID
+	}
+	for _, file := range files {
+		f.audit(ctx, actor, models.AuditDownload, file, detail)
+	}
+}
+
+// WriteArchive method streams the files as a ZIP closed by a manifest with their
+// checksums. Content that cannot be read once the response has started is
+// recorded in the manifest rather than failing the download.
+func (f *FileService) WriteArchive(ctx context.Context, w io.Writer, files []models.File, refID string) error {
+	ctx, span := tracing.Start(ctx, "FileService.WriteArchive", tracing.KindInternal)
+	defer span.End()
+
+	zip := archive.NewWriter(w)
+	zip.Reserve(manifestName)
+	manifest := models.Manifest{CreatedOn: time.Now().UTC(), RefID: refID, Files: []models.ManifestFile{}}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entry := models.ManifestFile{ID: file.ID, RefID: file.RefID, MimeType: file.MimeType}
+		if err := f.writeArchiveFile(zip, file, &entry); err != nil {
+			if entry.Path != "" {
+				// the entry is partly written, the archive cannot be trusted
+				return err
+			}
+			logging.From(ctx).WithError(err).WithField("file_id", file.ID).Warn("archive file skipped")
+			entry.Error = err.Error()
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, _, _, err := zip.AddReserved(manifestName, "application/json", manifest.CreatedOn, bytes.NewReader(content)); err != nil {
+		return err
+	}
+	return zip.Close()
+}
+
+// writeArchiveFile copies one file into the archive through the storage layer
+func (f *FileService) writeArchiveFile(zip *archive.Writer, file models.File, entry *models.ManifestFile) error {
+	path, found := f.storage.Path(file.TenantID, file.Name)
+	if !found {
+		return errors.New("file content does not exist")
+	}
+	content, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return errors.New("file content does not exist")
+	}
+	defer content.Close()
+
+	// entries carry the uploaded name, files stored before it was kept the stored one
+	name := file.OriginalName
+	if name == "" {
+		name = file.Name
+	}
+	entry.Name = name
+	name, size, sum, err := zip.Add(name, file.MimeType, file.CreatedOn, content)
+	entry.Path = name
+	entry.Size = size
+	entry.SHA256 = sum
+	return err
+}
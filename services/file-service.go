@@ -7,14 +7,26 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/greatfocus/gf-document/archive"
+	"github.com/greatfocus/gf-document/blind"
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/metadata"
 	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/pdf"
+	"github.com/greatfocus/gf-document/rendition"
 	"github.com/greatfocus/gf-document/repositories"
-	"github.com/greatfocus/gf-sframe/database"
+	"github.com/greatfocus/gf-document/storage"
+	"github.com/greatfocus/gf-document/tracing"
 	"github.com/greatfocus/gf-sframe/server"
 	cache "github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
@@ -24,75 +36,97 @@ var uploadPath = "./upload"
 
 // FileService struct
 type FileService struct {
-	fileRepository *repositories.FileRepository
-	jwt            server.JWT
-	logger         *logrus.Logger
+	fileRepository      *repositories.FileRepository
+	grantRepository     *repositories.GrantRepository
+	usageRepository     *repositories.UsageRepository
+	searchRepository    *repositories.SearchRepository
+	renditionRepository *repositories.RenditionRepository
+	entryRepository     *repositories.EntryRepository
+	jobRepository       *repositories.JobRepository
+	auditRepository     *repositories.AuditRepository
+	storage             *storage.Local
+	replica             *storage.Local
+	cache               *storage.Cache
+	images              *rendition.Images
+	quotas              map[string]models.Quota
+	renditionSpecs      []rendition.Spec
+	metadataPolicy      metadata.Policy
+	pdfPolicy           pdf.Policy
+	archiveLimits       archive.Limits
+	explodeArchives     bool
+	jobAttempts         int
+	transformKey        []byte
+	jwt                 server.JWT
 }
 
 // Init method
-func (f *FileService) Init(database database.Database, cache *cache.Cache, jwt server.JWT, logger *logrus.Logger) {
+func (f *FileService) Init(conn *sql.DB, cache *cache.Cache, jwt server.JWT) {
 	f.fileRepository = &repositories.FileRepository{}
-	f.fileRepository.Init(database, cache)
+	f.fileRepository.Init(conn, cache)
+	f.grantRepository = &repositories.GrantRepository{}
+	f.grantRepository.Init(conn, cache)
+	f.usageRepository = &repositories.UsageRepository{}
+	f.usageRepository.Init(conn)
+	f.searchRepository = &repositories.SearchRepository{}
+	f.searchRepository.Init(conn)
+	f.renditionRepository = &repositories.RenditionRepository{}
+	f.renditionRepository.Init(conn)
+	f.entryRepository = &repositories.EntryRepository{}
+	f.entryRepository.Init(conn)
+	f.jobRepository = &repositories.JobRepository{}
+	f.jobRepository.Init(conn, cache)
+	f.auditRepository = &repositories.AuditRepository{}
+	f.auditRepository.Init(conn)
+	f.quotas = loadQuotas()
+	f.renditionSpecs = rendition.LoadSpecs()
+	f.metadataPolicy = metadata.LoadPolicy()
+	f.pdfPolicy = pdf.LoadPolicy()
+	f.archiveLimits = archive.LoadLimits()
+	f.explodeArchives, _ = strconv.ParseBool(os.Getenv("ARCHIVE_EXPLODE"))
+	f.jobAttempts = jobAttempts()
+	f.storage = &storage.Local{}
+	f.storage.Init(uploadPath)
+	f.replica = replicaStorage()
+	f.cache = &storage.Cache{}
+	f.cache.Init(f.storage, transformCacheBytes())
+	f.images = rendition.NewImages(2)
+	f.transformKey = []byte(os.Getenv("TRANSFORM_SIGNING_KEY"))
 	f.jwt = jwt
-	f.logger = logger
 }
 
-// createFolder make dir
-func createFolder(path string) bool {
-	_, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		errDir := os.MkdirAll(path, 0755)
-		return errDir == nil
-	}
-	return true
-}
-
-// FileExists check file exist
-func fileExists(filename string) bool {
-	path := uploadPath + "/Temp"
-	createdFolder := createFolder(path)
-	if !createdFolder {
-		return false
-	}
-
-	info, err := os.Stat(path + "/" + filename)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return !info.IsDir()
-}
-
-// dropFile check file exist
-func dropFile(filename string) {
-	path := uploadPath + "/Temp"
-	os.Remove(path + "/" + filename)
+// tenantContext scopes the repositories to the tenant of the actor
+func tenantContext(ctx context.Context, actor models.Actor) context.Context {
+	return repositories.WithTenant(ctx, actor.TenantID)
 }
 
-// MoveFile cut file after success save
-func moveFile(filename string) bool {
-	oldLocation := uploadPath + "/Temp"
-	newLocation := uploadPath
+// Upload file function
+func (f *FileService) Upload(ctx context.Context, enKey string, actor models.Actor, r *http.Request) (_ models.File, err error) {
+	ctx, span := tracing.Start(ctx, "FileService.Upload", tracing.KindInternal)
+	defer func() {
+		observeRejection(err)
+		span.RecordError(err)
+		span.End()
+	}()
+	ctx = tenantContext(ctx, actor)
+	doc := models.File{}
 
-	createdFolder := createFolder(oldLocation)
-	if !createdFolder {
-		return false
+	// reject uploads that cannot fit the quota before reading the body
+	remaining, err := f.checkQuota(ctx, actor, r.ContentLength)
+	if err != nil {
+		return doc, err
 	}
-
-	createdFolder = createFolder(newLocation)
-	if !createdFolder {
-		return false
+	var body *quotaReader
+	if remaining >= 0 {
+		body = &quotaReader{body: r.Body, limit: remaining + multipartOverhead}
+		r.Body = body
 	}
 
-	err := os.Rename(oldLocation+"/"+filename, newLocation+"/"+filename)
-	return err == nil
-}
-
-// Upload file function
-func (f *FileService) Upload(ctx context.Context, enKey string, r *http.Request) (models.File, error) {
-	doc := models.File{}
 	// Parse our multipart form, 10 << 20 specifies a maximum
 	// upload of 10 MB files.
 	r.ParseMultipartForm(10 << 20)
+	if body != nil && body.exceeded != nil {
+		return doc, body.exceeded
+	}
 	// FormFile returns the first file for the given key `myFile`
 	// it also returns the FileHeader so we can get the Filename,
 	// the Header and the size of the file
@@ -102,38 +136,24 @@ func (f *FileService) Upload(ctx context.Context, enKey string, r *http.Request)
 		return doc, derr
 	}
 	defer file.Close()
-	f.logger.Info(fmt.Sprintf("Uploaded File: %+v\n", handler.Filename))
-	f.logger.Info(fmt.Sprintf("File Size: %+v\n", handler.Size))
-	f.logger.Info(fmt.Sprintf("MIME Header: %+v\n", handler.Header))
+	logging.From(ctx).WithFields(logrus.Fields{
+		"filename":     handler.Filename,
+		"size":         handler.Size,
+		"content_type": handler.Header.Get("Content-Type"),
+	}).Debug("upload received")
 
-	doc.Size = handler.Size
 	doc.Status = "new"
+	doc.ActorID = actor.ID
+	doc.Origin = actor.Origin
+	doc.TenantID = actor.TenantID
 
 	if uploadPath == "" {
 		err := errors.New("Upload PATH is not set")
 		derr := errors.New("cannot create file")
-		f.logger.Error(fmt.Sprintf("Error: %v\n", err))
+		logging.From(ctx).WithError(err).Error("upload not stored")
 		return doc, derr
 	}
 
-	// Create a temporary file within our temp-images directory that follows
-	// a particular naming pattern
-	createdFolder := createFolder(uploadPath + "/Temp")
-	if !createdFolder {
-		return doc, err
-	}
-
-	tempFile, err := os.CreateTemp(uploadPath+"/Temp", "image-*.png")
-	re := regexp.MustCompile(`^(.*/)?(?:$|(.+?)(?:(\.[^.]*$)|$))`)
-	match2 := re.FindStringSubmatch(tempFile.Name())
-	fileName := match2[2] + match2[3]
-	doc.Name = fileName
-	doc.Extension = match2[3]
-	if err != nil {
-		return doc, err
-	}
-	defer tempFile.Close()
-
 	// read all of the contents of our uploaded file into a
 	// byte array
 	fileBuffer := bytes.NewBuffer(nil)
@@ -141,42 +161,79 @@ func (f *FileService) Upload(ctx context.Context, enKey string, r *http.Request)
 		return doc, err
 	}
 
-	// write this byte array to our temporary file
-	tempFile.Write(fileBuffer.Bytes())
-	// return that we have successfully uploaded our file!
-	f.logger.Info(fmt.Sprintf("Successfully Uploaded File: %+v\n", tempFile))
-
-	created, err := f.createFile(ctx, enKey, r, doc)
+	explode, err := strconv.ParseBool(r.FormValue("explode"))
+	if err != nil {
+		explode = f.explodeArchives
+	}
+	doc, err = f.ingest(ctx, enKey, doc, upload{
+		filename:    handler.Filename,
+		declared:    handler.Header.Get("Content-Type"),
+		content:     fileBuffer.Bytes(),
+		language:    r.FormValue("language"),
+		title:       r.FormValue("title"),
+		description: r.FormValue("description"),
+		explode:     explode,
+	})
 	if err != nil {
 		return doc, err
 	}
-	return created, nil
+	f.audit(ctx, actor, models.AuditUpload, doc, map[string]string{
+		"mimeType": doc.MimeType,
+		"size":     strconv.FormatInt(doc.Size, 10),
+	})
+
+	result := models.File{}
+	result.PrepareFileOutput(doc)
+	return result, nil
+}
+
+// extensionPattern matches extensions safe to keep on stored files
+var extensionPattern = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+// fileExtension keeps the extension of the uploaded file, or one matching its type
+func fileExtension(filename string, mimeType string) string {
+	extension := strings.ToLower(filepath.Ext(filename))
+	if extensionPattern.MatchString(extension) {
+		return extension
+	}
+	if extensions, err := mime.ExtensionsByType(mimeType); err == nil && len(extensions) > 0 {
+		return extensions[0]
+	}
+	return ".png"
 }
 
 // CreateFile method
-func (f *FileService) createFile(ctx context.Context, enKey string, r *http.Request, file models.File) (models.File, error) {
+func (f *FileService) createFile(ctx context.Context, enKey string, file models.File) (models.File, error) {
 	// validate token
 	file.CreatedOn = time.Now()
 
 	// validate payload rules
 	err := file.ValidateFile("add")
 	if err != nil {
+		if file.Name != "" {
+			f.storage.Drop(file.TenantID, file.Name)
+		}
 		return file, err
 	}
 
-	fileFound := fileExists(file.Name)
+	fileFound := f.storage.Exists(file.TenantID, file.Name)
 	if !fileFound {
 		derr := errors.New("kindly upload choose and upload file")
-		f.logger.Error(fmt.Sprintf("Error: %v\n", derr))
+		logging.From(ctx).WithField("file_id", file.ID).Error("upload content missing")
 		return file, derr
 	}
 
 	// insert file
-	created, err := f.fileRepository.Create(ctx, enKey, file)
+	created, err := f.fileRepository.Create(ctx, enKey, file, f.quotas)
+	if err == repositories.ErrQuotaExceeded {
+		f.storage.Drop(file.TenantID, file.Name)
+		return file, addedQuotaError(err)
+	}
 	if err != nil {
 		derr := errors.New("failed to upload image")
-		f.logger.Error(fmt.Sprintf("Error: %v\n", derr))
-		f.fileRepository.Delete(ctx, enKey, created.ID)
+		logging.From(ctx).WithError(err).WithField("file_id", created.ID).Error("upload not created")
+		// the insert rolled back, the stored content has no record
+		f.storage.Drop(file.TenantID, file.Name)
 		return file, derr
 	}
 
@@ -186,8 +243,12 @@ func (f *FileService) createFile(ctx context.Context, enKey string, r *http.Requ
 }
 
 // GetFiles method gets file by lastID
-func (f *FileService) GetFiles(ctx context.Context, enKey string, lastID string) ([]models.File, error) {
-	files, err := f.fileRepository.GetFiles(ctx, enKey, lastID)
+func (f *FileService) GetFiles(ctx context.Context, enKey string, actor models.Actor, lastID string) ([]models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.GetFiles", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	files, err := f.fileRepository.GetFiles(ctx, enKey, actor, lastID)
 	if err != nil {
 		return files, err
 	}
@@ -195,33 +256,119 @@ func (f *FileService) GetFiles(ctx context.Context, enKey string, lastID string)
 }
 
 // GetFileByID method gets file by ID
-func (f *FileService) GetFileByID(ctx context.Context, enKey string, id string) (models.File, error) {
+func (f *FileService) GetFileByID(ctx context.Context, enKey string, actor models.Actor, id string) (models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.GetFileByID", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
 	file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
-	if err != nil && err != sql.ErrNoRows {
+	if err == sql.ErrNoRows {
+		return file, nil
+	}
+	if err != nil {
 		return file, err
 	}
+	if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+		return models.File{}, err
+	}
+	f.audit(ctx, actor, models.AuditView, file, nil)
 	return file, nil
 }
 
+// GetFilesByName method finds the readable files with an exact name or a name prefix
+func (f *FileService) GetFilesByName(ctx context.Context, enKey string, actor models.Actor, name string, prefix string) ([]models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.GetFilesByName", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	if name != "" {
+		file, err := f.fileRepository.GetFileByName(ctx, enKey, name)
+		if err == sql.ErrNoRows {
+			return []models.File{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+			return []models.File{}, nil
+		}
+		return []models.File{file}, nil
+	}
+
+	if utf8.RuneCountInString(prefix) < blind.PrefixLength {
+		return nil, fmt.Errorf("namePrefix needs at least %d characters", blind.PrefixLength)
+	}
+	return f.fileRepository.GetFilesByNamePrefix(ctx, enKey, actor, prefix)
+}
+
+// ReindexNames method refreshes stale blind indexes in batches
+func (f *FileService) ReindexNames(ctx context.Context, enKey string, batch int) (int, error) {
+	ctx = tenantContext(ctx, models.SystemActor)
+	total := 0
+	for {
+		count, err := f.fileRepository.ReindexNames(ctx, enKey, batch)
+		total += count
+		if err != nil || count < batch {
+			return total, err
+		}
+	}
+}
+
+// Download method returns the file record and the location of its content
+func (f *FileService) Download(ctx context.Context, enKey string, actor models.Actor, id string) (models.File, string, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Download", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
+	if err != nil {
+		return file, "", errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+		return models.File{}, "", err
+	}
+
+	path, found := f.storage.Path(file.TenantID, file.Name)
+	if !found {
+		derr := errors.New("file content does not exist")
+		logging.From(ctx).WithField("file_id", file.ID).Error("file content missing")
+		return file, "", derr
+	}
+	f.audit(ctx, actor, models.AuditDownload, file, nil)
+	return file, path, nil
+}
+
 // Update method updates the file record
-func (f *FileService) Update(ctx context.Context, enKey string, file models.File) (models.File, error) {
+func (f *FileService) Update(ctx context.Context, enKey string, actor models.Actor, file models.File) (models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Update", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
 	// forensic should be done
 	foundFile, err := f.fileRepository.GetFileByID(ctx, enKey, file.ID)
 	if err != nil {
 		return file, err
 	}
+	if err := f.authorize(ctx, actor, foundFile, models.GrantWrite); err != nil {
+		return file, err
+	}
 
 	// updated File
 	file.Status = "approved"
+	file.Name = foundFile.Name
 	err = f.fileRepository.Update(ctx, enKey, file)
 	if err != nil {
 		derr := errors.New("failed to update File")
-		f.logger.Error(fmt.Sprintf("Error: %v\n", derr))
+		logging.From(ctx).WithError(err).WithField("file_id", file.ID).Error("file not updated")
 		return file, derr
 	}
 
-	// move the file from temp
-	moveFile(foundFile.Name)
+	// move the file from temp, content left behind is still served from temp
+	// and moved by the reconciler
+	if !f.storage.Move(foundFile.TenantID, foundFile.Name) {
+		logging.From(ctx).WithField("file_id", file.ID).Error("file content not moved")
+	}
+	f.audit(ctx, actor, models.AuditApprove, foundFile, nil)
 
 	result := models.File{}
 	result.PrepareFileOutput(file)
@@ -229,24 +376,27 @@ func (f *FileService) Update(ctx context.Context, enKey string, file models.File
 }
 
 // Delete method delete the file record
-func (f *FileService) Delete(ctx context.Context, enKey string, id string) (bool, error) {
+func (f *FileService) Delete(ctx context.Context, enKey string, actor models.Actor, id string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Delete", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
 	// payment should doen before verification
 	insertedFile, err := f.fileRepository.GetFileByID(ctx, enKey, id)
 	if err != nil {
 		return false, errors.New("record does not exist")
 	}
+	if err := f.authorize(ctx, actor, insertedFile, models.GrantDelete); err != nil {
+		return false, err
+	}
 
 	if insertedFile.Status == "approved" {
 		return false, errors.New("you are not allowed to delete file")
 	}
 
-	err = f.fileRepository.Delete(ctx, enKey, id)
-	if err != nil {
-		derr := errors.New("failed to delete File")
-		f.logger.Error(fmt.Sprintf("Error: %v\n", derr))
-		return false, derr
+	if err := f.removeFile(ctx, enKey, actor, insertedFile, nil); err != nil {
+		return false, err
 	}
-	dropFile(insertedFile.Name)
 
 	result := models.File{}
 	result.PrepareFileOutput(insertedFile)
@@ -255,6 +405,10 @@ func (f *FileService) Delete(ctx context.Context, enKey string, id string) (bool
 
 // DeleteFromJob method delete the file record
 func (f *FileService) DeleteFromJob(ctx context.Context, enKey string, id string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "FileService.DeleteFromJob", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, models.SystemActor)
 	// payment should doen before verification
 	insertedFile, err := f.fileRepository.GetFileByID(ctx, enKey, id)
 	if err != nil {
@@ -265,15 +419,32 @@ func (f *FileService) DeleteFromJob(ctx context.Context, enKey string, id string
 		return false, errors.New("you are not allowed to delete file")
 	}
 
-	err = f.fileRepository.Delete(ctx, enKey, id)
-	if err != nil {
-		derr := errors.New("failed to delete File")
-		f.logger.Error(fmt.Sprintf("Error: %v\n", derr))
-		return false, derr
+	if err := f.removeFile(ctx, enKey, models.SystemActor, insertedFile, map[string]string{"reason": "expired"}); err != nil {
+		return false, err
 	}
-	dropFile(insertedFile.Name)
 
 	result := models.File{}
 	result.PrepareFileOutput(insertedFile)
 	return true, nil
 }
+
+// removeFile deletes the record of the file then its content, renditions and
+// cached transformations, and records the deletion
+func (f *FileService) removeFile(ctx context.Context, enKey string, actor models.Actor, file models.File, detail map[string]string) error {
+	err := f.fileRepository.Delete(ctx, enKey, file.ID)
+	if err != nil {
+		derr := errors.New("failed to delete File")
+		logging.From(ctx).WithError(err).WithField("file_id", file.ID).Error("file not deleted")
+		return derr
+	}
+	f.storage.Remove(file.TenantID, file.Name)
+	f.storage.DropRenditions(file.TenantID, file.ID)
+	f.cache.Drop(file.TenantID, file.ID)
+	f.audit(ctx, actor, models.AuditDelete, file, detail)
+	return nil
+}
+
+// StorageRoot method returns the folder uploads are stored under
+func (f *FileService) StorageRoot() string {
+	return f.storage.Root()
+}
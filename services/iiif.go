@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/rendition"
+	"github.com/greatfocus/gf-document/tracing"
+)
+
+// imageFile returns a readable image file and the location of its content
+func (f *FileService) imageFile(ctx context.Context, enKey string, actor models.Actor, id string) (models.File, string, error) {
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
+	if err != nil {
+		return file, "", errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+		return models.File{}, "", err
+	}
+	if !rendition.Supported(file.MimeType) {
+		return models.File{}, "", errNotFound
+	}
+
+	path, found := f.storage.Path(file.TenantID, file.Name)
+	if !found {
+		derr := errors.New("file content does not exist")
+		logging.From(ctx).WithError(derr).WithField("file_id", file.ID).Error("image content missing")
+		return models.File{}, "", derr
+	}
+	return file, path, nil
+}
+
+// IIIFInfo method describes an image for IIIF viewers, id is the base URI of the image service
+func (f *FileService) IIIFInfo(ctx context.Context, enKey string, actor models.Actor, id string, serviceID string) (models.IIIFInfo, error) {
+	ctx = tenantContext(ctx, actor)
+	_, path, err := f.imageFile(ctx, enKey, actor, id)
+	if err != nil {
+		return models.IIIFInfo{}, err
+	}
+	width, height, err := rendition.Dimensions(path)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", id).Error("image not read")
+		return models.IIIFInfo{}, errors.New("cannot read image")
+	}
+
+	factors := rendition.ScaleFactors(width, height)
+	sizes := []models.IIIFSize{}
+	for i := len(factors) - 1; i >= 0; i-- {
+		sizes = append(sizes, models.IIIFSize{
+			Type:   "Size",
+			Width:  (width + factors[i] - 1) / factors[i],
+			Height: (height + factors[i] - 1) / factors[i],
+		})
+	}
+	return models.IIIFInfo{
+		Context:        models.IIIFContext,
+		ID:             serviceID,
+		Type:           "ImageService3",
+		Protocol:       models.IIIFProtocol,
+		Profile:        models.IIIFProfile,
+		Width:          width,
+		Height:         height,
+		MaxWidth:       rendition.MaxDimension(),
+		MaxHeight:      rendition.MaxDimension(),
+		Sizes:          sizes,
+		Tiles:          []models.IIIFTile{{Width: rendition.IIIFTileSize, ScaleFactors: factors}},
+		ExtraQualities: []string{"color", "gray", "bitonal"},
+		ExtraFormats:   []string{"gif"},
+		ExtraFeatures:  []string{"mirroring", "regionByPct", "regionByPx", "regionSquare", "rotationBy90s", "sizeByConfinedWh", "sizeByH", "sizeByPct", "sizeByW", "sizeByWh", "sizeUpscaling"},
+	}, nil
+}
+
+// IIIFImage method renders an IIIF image request, returning the result and the
+// location of its content in the tile cache
+func (f *FileService) IIIFImage(ctx context.Context, enKey string, actor models.Actor, id string, path string) (models.Rendition, string, error) {
+	ctx, span := tracing.Start(ctx, "FileService.IIIFImage", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	request, err := rendition.ParseIIIF(path)
+	if err != nil {
+		return models.Rendition{}, "", err
+	}
+	file, source, err := f.imageFile(ctx, enKey, actor, id)
+	if err != nil {
+		return models.Rendition{}, "", err
+	}
+
+	sum := sha256.Sum256([]byte(request.Canonical()))
+	result := models.Rendition{FileID: file.ID, Name: "iiif-" + hex.EncodeToString(sum[:16]), CreatedOn: file.CreatedOn}
+	if cached, found := f.cache.Get(file.TenantID, file.ID, result.Name); found {
+		result.MimeType = mimeTypeOf(cached)
+		return result, cached, nil
+	}
+
+	img, orientation, err := f.images.Load(source)
+	if err == rendition.ErrTooLarge {
+		return models.Rendition{}, "", err
+	}
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", file.ID).Error("image not read")
+		return models.Rendition{}, "", errors.New("cannot read image")
+	}
+	rendered, err := request.Apply(img, orientation)
+	if err != nil {
+		return models.Rendition{}, "", err
+	}
+	cached, err := f.cache.Put(file.TenantID, file.ID, result.Name, request.Extension(), rendered.Data)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", file.ID).Error("image not cached")
+		return models.Rendition{}, "", errors.New("cannot render image")
+	}
+
+	result.MimeType = rendered.MimeType
+	result.Width = rendered.Width
+	result.Height = rendered.Height
+	result.Size = int64(len(rendered.Data))
+	return result, cached, nil
+}
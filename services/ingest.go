@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/greatfocus/gf-document/archive"
+	"github.com/greatfocus/gf-document/extract"
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/metadata"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/rendition"
+	"github.com/greatfocus/gf-document/tracing"
+)
+
+// tempNamePattern splits the name of a temp file into its base and extension
+var tempNamePattern = regexp.MustCompile(`^(.*/)?(?:$|(.+?)(?:(\.[^.]*$)|$))`)
+
+// maxOriginalName bounds the uploaded name kept with a file, in bytes
+const maxOriginalName = 255
+
+// upload struct is a document on its way into storage
+type upload struct {
+	filename    string
+	declared    string
+	content     []byte
+	language    string
+	title       string
+	description string
+	explode     bool
+}
+
+// ingest detects the type of a document, cleans and inspects it, stores it in
+// the temp folder of the tenant and records it, queueing the hashing, text
+// extraction, renditions and explode jobs. doc carries the owner and parent.
+// Cleaning and inspecting run before the file is stored, not as jobs, so a
+// file refused by the metadata, PDF or archive policy is never stored.
+func (f *FileService) ingest(ctx context.Context, enKey string, doc models.File, in upload) (models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.ingest", tracing.KindInternal)
+	defer span.End()
+
+	content := in.content
+	head := content
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	doc.MimeType = extract.DetectMimeType(in.filename, in.declared, head)
+	doc.OriginalName = originalName(in.filename)
+	doc.Size = int64(len(content))
+	span.SetAttributes(tracing.String("file.mimeType", doc.MimeType), tracing.Int("file.size", doc.Size))
+
+	// record the whitelisted metadata and drop the rest before the file is stored
+	if metadata.Supported(doc.MimeType) {
+		mode := f.metadataPolicy.Mode(doc.MimeType)
+		err := traced(ctx, "metadata.Process", func(ctx context.Context) error {
+			cleaned, fields, err := metadata.Process(doc.MimeType, content, mode)
+			doc.Metadata = fields
+			if err != nil && mode == metadata.ModeStrip {
+				// the metadata cannot be told apart from the image, so it is refused
+				return fmt.Errorf("%w: image metadata not stripped: %v", errRejected, err)
+			}
+			if err != nil {
+				logging.From(ctx).WithError(err).WithField("filename", in.filename).Warn("metadata not processed")
+			}
+			content = cleaned
+			doc.Size = int64(len(content))
+			return nil
+		})
+		if err != nil {
+			span.RecordError(err)
+			return doc, err
+		}
+	}
+	if doc.MimeType == "application/pdf" {
+		err := traced(ctx, "pdf.Inspect", func(ctx context.Context) error {
+			return f.inspectPDF(ctx, &doc, content)
+		})
+		if err != nil {
+			span.RecordError(err)
+			return doc, err
+		}
+	}
+	var entries []archive.Entry
+	if archive.Supported(doc.MimeType) {
+		err := traced(ctx, "archive.Inspect", func(ctx context.Context) error {
+			var err error
+			entries, err = f.inspectArchive(ctx, &doc, content)
+			return err
+		})
+		if err != nil {
+			span.RecordError(err)
+			return doc, err
+		}
+	}
+
+	// Create a temporary file within the temp directory of the tenant
+	// that follows a particular naming pattern
+	err := traced(ctx, "storage.Write", func(ctx context.Context) error {
+		tempFile, err := f.storage.CreateTemp(doc.TenantID, "image-*"+fileExtension(in.filename, doc.MimeType))
+		if err != nil {
+			return err
+		}
+		defer tempFile.Close()
+		match := tempNamePattern.FindStringSubmatch(tempFile.Name())
+		doc.Name = match[2] + match[3]
+		doc.Extension = match[3]
+
+		// write this byte array to our temporary file
+		if _, err := tempFile.Write(content); err != nil {
+			f.storage.Drop(doc.TenantID, doc.Name)
+			return err
+		}
+		logging.From(ctx).WithField("path", tempFile.Name()).Debug("upload written")
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return doc, err
+	}
+
+	created, err := f.createFile(ctx, enKey, doc)
+	if err != nil {
+		span.RecordError(err)
+		return doc, err
+	}
+
+	doc.ID = created.ID
+	observeUpload(doc)
+	if len(entries) > 0 {
+		f.recordEntries(ctx, enKey, doc, entries)
+	}
+
+	// the rest of the processing runs in the background
+	f.enqueue(ctx, doc, JobHash, nil)
+	f.enqueue(ctx, doc, JobExtract, map[string]string{
+		"language":    in.language,
+		"filename":    in.filename,
+		"title":       in.title,
+		"description": in.description,
+	})
+	if rendition.Eager() && rendition.Supported(doc.MimeType) {
+		f.enqueue(ctx, doc, JobRenditions, nil)
+	}
+	if in.explode && len(entries) > 0 {
+		f.enqueue(ctx, doc, JobExplode, nil)
+	}
+	return doc, nil
+}
+
+// originalName keeps the base of the uploaded filename without control
+// characters, some browsers send the full path of the file
+func originalName(filename string) string {
+	filename = filename[strings.LastIndexAny(filename, `/\`)+1:]
+	filename = strings.ToValidUTF8(filename, "")
+	filename = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filename))
+	for len(filename) > maxOriginalName {
+		_, size := utf8.DecodeLastRuneInString(filename)
+		filename = filename[:len(filename)-size]
+	}
+	return filename
+}
+
+// traced runs fn in a child span of ctx named name, recording its error
+func traced(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracing.Start(ctx, name, tracing.KindInternal)
+	defer span.End()
+	err := fn(ctx)
+	span.RecordError(err)
+	return err
+}
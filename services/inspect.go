@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/pdf"
+)
+
+// errRejected is returned for uploads the content policy does not allow
+var errRejected = errors.New("document rejected by policy")
+
+// inspectPDF records the structure of a PDF as metadata and rejects the
+// risks PDF_REJECT lists. Documents that cannot be read are kept as they are.
+func (f *FileService) inspectPDF(ctx context.Context, doc *models.File, content []byte) error {
+	report, err := pdf.Inspect(content)
+	if err != nil {
+		logging.From(ctx).WithError(err).Warn("PDF not inspected")
+		return nil
+	}
+
+	if doc.Metadata == nil {
+		doc.Metadata = map[string]string{}
+	}
+	for name, value := range report.Fields() {
+		doc.Metadata[name] = value
+	}
+	if rejected := f.pdfPolicy.Rejected(report); len(rejected) > 0 {
+		return fmt.Errorf("%w: pdf contains %s", errRejected, strings.Join(rejected, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/rendition"
+	"github.com/greatfocus/gf-document/repositories"
+	"github.com/greatfocus/gf-document/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// Job types processed in the background after an upload
+const (
+	JobHash       = "hash"
+	JobExtract    = "extract"
+	JobRenditions = "renditions"
+	JobExplode    = "explode"
+)
+
+// Retry settings of failed jobs
+const (
+	defaultJobAttempts = 5
+	jobBackoff         = 10 * time.Second
+	maxJobBackoff      = time.Hour
+)
+
+// JobLease is how long a claimed job may run before another worker may take it over
+const JobLease = 5 * time.Minute
+
+// jobHandler processes one job of a file
+type jobHandler func(ctx context.Context, enKey string, file models.File, path string, job models.Job) error
+
+// jobHandlers maps the job types to their handlers
+func (f *FileService) jobHandlers() map[string]jobHandler {
+	return map[string]jobHandler{
+		JobHash:       f.hashJob,
+		JobExtract:    f.extractJob,
+		JobRenditions: f.renditionsJob,
+		JobExplode:    f.explodeJob,
+	}
+}
+
+// jobAttempts reads how often a job runs before it is failed, JOB_MAX_ATTEMPTS
+func jobAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("JOB_MAX_ATTEMPTS"))
+	if err != nil || attempts <= 0 {
+		return defaultJobAttempts
+	}
+	return attempts
+}
+
+// retryDelay doubles the backoff with every attempt up to maxJobBackoff
+func retryDelay(attempts int) time.Duration {
+	delay := jobBackoff
+	for i := 1; i < attempts && delay < maxJobBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxJobBackoff {
+		delay = maxJobBackoff
+	}
+	return delay
+}
+
+// enqueue queues a job for the file, the failure is logged as the upload itself succeeded
+func (f *FileService) enqueue(ctx context.Context, file models.File, jobType string, payload map[string]string) {
+	// the job continues the trace and correlation id of the upload that queued it
+	if payload == nil {
+		payload = map[string]string{}
+	}
+	tracing.Inject(ctx, tracing.MapCarrier(payload))
+	logging.Inject(ctx, tracing.MapCarrier(payload))
+	_, err := f.jobRepository.Create(ctx, models.Job{
+		FileID:      file.ID,
+		Type:        jobType,
+		Payload:     payload,
+		MaxAttempts: f.jobAttempts,
+	})
+	if err != nil {
+		logging.From(ctx).WithError(err).WithFields(logrus.Fields{"file_id": file.ID, "job_type": jobType}).Error("job not queued")
+	}
+}
+
+// ClaimJob method leases the next due job of any tenant, found is false when none is due
+func (f *FileService) ClaimJob(ctx context.Context) (models.Job, bool, error) {
+	job, err := f.jobRepository.Claim(ctx, JobLease)
+	if err == sql.ErrNoRows {
+		return job, false, nil
+	}
+	if err != nil {
+		return job, false, err
+	}
+	return job, true, nil
+}
+
+// RunJob method processes a claimed job, completing it or scheduling a retry
+// with backoff, and returns the status the job was left in
+func (f *FileService) RunJob(ctx context.Context, enKey string, job models.Job) (string, error) {
+	ctx = tracing.Extract(ctx, tracing.MapCarrier(job.Payload))
+	ctx = logging.Extract(ctx, tracing.MapCarrier(job.Payload))
+	ctx, span := tracing.Start(ctx, "FileService.RunJob", tracing.KindConsumer, tracing.String("job.type", job.Type),
+		tracing.String("job.id", job.ID), tracing.String("file.id", job.FileID), tracing.Int("job.attempt", int64(job.Attempts)))
+	defer span.End()
+
+	ctx = repositories.WithTenant(ctx, job.TenantID)
+	err := f.runJob(ctx, enKey, job)
+	span.RecordError(err)
+	if err == nil {
+		err = f.jobRepository.Complete(ctx, job)
+		if err == sql.ErrNoRows {
+			// the job was removed with its file
+			return models.StageDone, nil
+		}
+		return models.StageDone, err
+	}
+
+	status, ferr := f.jobRepository.Fail(ctx, job, err, time.Now().Add(retryDelay(job.Attempts)))
+	if ferr == sql.ErrNoRows {
+		return models.StageDone, nil
+	}
+	if ferr != nil {
+		return status, ferr
+	}
+	logging.From(ctx).WithError(err).WithFields(logrus.Fields{"job_id": job.ID, "job_type": job.Type, "file_id": job.FileID, "attempt": job.Attempts, "status": status}).Warn("job failed")
+	return status, nil
+}
+
+// runJob looks up the file and its content and calls the handler of the job type
+func (f *FileService) runJob(ctx context.Context, enKey string, job models.Job) error {
+	handler, found := f.jobHandlers()[job.Type]
+	if !found {
+		return fmt.Errorf("unknown job type %s", job.Type)
+	}
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, job.FileID)
+	if err != nil {
+		return err
+	}
+	path, found := f.storage.Path(file.TenantID, file.Name)
+	if !found {
+		return errors.New("file content does not exist")
+	}
+	return handler(ctx, enKey, file, path, job)
+}
+
+// ExpireJobs method fails the jobs of every tenant left running past their
+// lease after their last attempt, which Claim no longer takes over
+func (f *FileService) ExpireJobs(ctx context.Context) (int64, error) {
+	return f.jobRepository.Expire(ctx)
+}
+
+// PurgeJobs method removes the jobs of every tenant done before the time
+func (f *FileService) PurgeJobs(ctx context.Context, before time.Time) (int64, error) {
+	return f.jobRepository.Purge(repositories.WithTenant(ctx, repositories.TenantAll), before)
+}
+
+// hashJob records the SHA-256 of the stored content
+func (f *FileService) hashJob(ctx context.Context, enKey string, file models.File, path string, job models.Job) error {
+	checksum, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	return f.fileRepository.SetChecksum(ctx, file.ID, checksum)
+}
+
+// hashFile returns the SHA-256 of the content at path
+func hashFile(path string) (string, error) {
+	content, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// extractJob indexes the text of the file with the details given on upload
+func (f *FileService) extractJob(ctx context.Context, enKey string, file models.File, path string, job models.Job) error {
+	return f.indexFile(ctx, file, path, job.Payload["language"],
+		job.Payload["filename"], job.Payload["title"], job.Payload["description"])
+}
+
+// renditionsJob creates every configured rendition of an image
+func (f *FileService) renditionsJob(ctx context.Context, enKey string, file models.File, path string, job models.Job) error {
+	if !rendition.Supported(file.MimeType) {
+		return nil
+	}
+	for _, spec := range f.renditionSpecs {
+		if _, err := f.renderFile(ctx, file, path, spec); err != nil {
+			return fmt.Errorf("rendition %s: %w", spec.Name, err)
+		}
+	}
+	return nil
+}
+
+// explodeJob stores the files of an archive as child documents of its owner
+func (f *FileService) explodeJob(ctx context.Context, enKey string, file models.File, path string, job models.Job) error {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	owner := models.Actor{ID: file.ActorID, Origin: file.Origin, TenantID: file.TenantID}
+	return f.explode(ctx, enKey, owner, file, content)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/greatfocus/gf-document/metrics"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/repositories"
+)
+
+// Upload and storage metrics
+var (
+	uploadBytes = metrics.NewCounter("upload_bytes_total",
+		"Bytes of documents stored by uploads and archive explodes.")
+	uploadSize = metrics.NewHistogram("upload_size_bytes",
+		"Size of the documents stored.", metrics.SizeBuckets)
+	uploadsRejected = metrics.NewCounter("uploads_rejected_total",
+		"Uploads refused by reason, quota, policy or invalid.", "reason")
+	storageBytes = metrics.NewGauge("storage_bytes",
+		"Bytes held per tenant.", "tenant")
+	storageFiles = metrics.NewGauge("storage_files",
+		"Files held per tenant.", "tenant")
+	jobsPending = metrics.NewGauge("jobs_pending",
+		"Due and running jobs per type and status.", "type", "status")
+	jobsOldest = metrics.NewGauge("jobs_oldest_pending_seconds",
+		"Age of the oldest due or running job per type and status.", "type", "status")
+)
+
+// observeUpload counts a stored document
+func observeUpload(doc models.File) {
+	uploadBytes.Add(float64(doc.Size))
+	uploadSize.Observe(float64(doc.Size))
+}
+
+// observeRejection counts an upload that failed by its reason
+func observeRejection(err error) {
+	switch {
+	case err == nil:
+	case IsQuotaExceeded(err):
+		uploadsRejected.Inc("quota")
+	case errors.Is(err, errRejected):
+		uploadsRejected.Inc("policy")
+	default:
+		uploadsRejected.Inc("invalid")
+	}
+}
+
+// CollectMetrics method refreshes the gauges read from the database before a scrape
+func (f *FileService) CollectMetrics(ctx context.Context) error {
+	ctx = repositories.WithTenant(ctx, repositories.TenantAll)
+	usages, err := f.usageRepository.GetTenantsUsage(ctx)
+	if err != nil {
+		return err
+	}
+	storageBytes.Reset()
+	storageFiles.Reset()
+	for _, usage := range usages {
+		storageBytes.Set(float64(usage.Bytes), usage.TenantID)
+		storageFiles.Set(float64(usage.Files), usage.TenantID)
+	}
+
+	stats, err := f.jobRepository.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	jobsPending.Reset()
+	jobsOldest.Reset()
+	for _, stat := range stats {
+		jobsPending.Set(float64(stat.Count), stat.Type, stat.Status)
+		jobsOldest.Set(stat.OldestSeconds, stat.Type, stat.Status)
+	}
+
+	problems, err := f.fileRepository.GetProblems(ctx)
+	if err != nil {
+		return err
+	}
+	filesProblem.Reset()
+	for problem, count := range problems {
+		filesProblem.Set(float64(count), problem)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+)
+
+// processingPoll is how often a waiting client's document is checked for changes
+const processingPoll = time.Second
+
+// Processing method returns the state of the background processing of a file
+func (f *FileService) Processing(ctx context.Context, enKey string, actor models.Actor, id string) (models.Processing, error) {
+	ctx = tenantContext(ctx, actor)
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
+	if err != nil {
+		return models.Processing{}, errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+		return models.Processing{}, err
+	}
+	return f.processing(ctx, file.ID)
+}
+
+// WaitProcessing method returns the state once it differs from version or
+// processing is finished, and the last state seen when ctx is done first
+func (f *FileService) WaitProcessing(ctx context.Context, enKey string, actor models.Actor, id string, version string) (models.Processing, error) {
+	state, err := f.Processing(ctx, enKey, actor, id)
+	if err != nil {
+		return state, err
+	}
+	ctx = tenantContext(ctx, actor)
+	for state.Version == version && !state.Finished {
+		select {
+		case <-ctx.Done():
+			return state, nil
+		case <-time.After(processingPoll):
+		}
+		next, err := f.processing(ctx, state.FileID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return state, nil
+			}
+			return state, err
+		}
+		state = next
+	}
+	return state, nil
+}
+
+// processing reads the jobs of a file. The stages are read from the jobs
+// rather than the cached file so waiting clients see changes as they happen.
+func (f *FileService) processing(ctx context.Context, fileID string) (models.Processing, error) {
+	jobs, err := f.jobRepository.GetJobs(ctx, fileID)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", fileID).Error("processing state not read")
+		return models.Processing{}, errors.New("failed to read processing state")
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedOn.Before(jobs[j].CreatedOn) ||
+			(jobs[i].CreatedOn.Equal(jobs[j].CreatedOn) && jobs[i].Type < jobs[j].Type)
+	})
+
+	stages := map[string]string{}
+	hash := sha256.New()
+	for _, job := range jobs {
+		stages[job.Type] = job.Status
+		fmt.Fprintf(hash, "%s:%s:%d:%d;", job.Type, job.Status, job.Attempts, job.UpdatedOn.UnixNano())
+	}
+	return models.Processing{
+		FileID:   fileID,
+		Ready:    models.StagesReady(stages),
+		Finished: models.StagesFinished(stages),
+		Version:  hex.EncodeToString(hash.Sum(nil)[:8]),
+		Stages:   jobs,
+	}, nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/repositories"
+	"github.com/sirupsen/logrus"
+)
+
+// multipartOverhead is the room left for boundaries and other form fields
+// when the request body is measured against the remaining quota
+const multipartOverhead = 64 << 10
+
+// errQuotaExceeded is returned when an upload does not fit the hard quota
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
+// IsQuotaExceeded checks if the error was caused by a hard quota
+func IsQuotaExceeded(err error) bool {
+	return errors.Is(err, errQuotaExceeded)
+}
+
+// loadQuotas reads the tenant and actor quotas from the environment, for example
+// QUOTA_TENANT_HARD_BYTES or QUOTA_ACTOR_SOFT_FILES, unset values are unlimited
+func loadQuotas() map[string]models.Quota {
+	read := func(name string) int64 {
+		value, err := strconv.ParseInt(os.Getenv(name), 0, 64)
+		if err != nil || value < 0 {
+			return 0
+		}
+		return value
+	}
+	return map[string]models.Quota{
+		models.UsageTenant: {
+			SoftBytes: read("QUOTA_TENANT_SOFT_BYTES"),
+			HardBytes: read("QUOTA_TENANT_HARD_BYTES"),
+			SoftFiles: read("QUOTA_TENANT_SOFT_FILES"),
+			HardFiles: read("QUOTA_TENANT_HARD_FILES"),
+		},
+		models.UsageActor: {
+			SoftBytes: read("QUOTA_ACTOR_SOFT_BYTES"),
+			HardBytes: read("QUOTA_ACTOR_HARD_BYTES"),
+			SoftFiles: read("QUOTA_ACTOR_SOFT_FILES"),
+			HardFiles: read("QUOTA_ACTOR_HARD_FILES"),
+		},
+	}
+}
+
+// quotaError explains which quota an upload went over
+func quotaError(usage models.Usage) error {
+	if usage.Quota.HardFiles > 0 && usage.Files >= usage.Quota.HardFiles {
+		return fmt.Errorf("%w: %s limit of %d files reached", errQuotaExceeded, usage.Scope, usage.Quota.HardFiles)
+	}
+	return fmt.Errorf("%w: %s limit of %d bytes reached", errQuotaExceeded, usage.Scope, usage.Quota.HardBytes)
+}
+
+// checkQuota rejects uploads that cannot fit and returns how many more
+// bytes the actor may store, -1 is unlimited
+func (f *FileService) checkQuota(ctx context.Context, actor models.Actor, size int64) (int64, error) {
+	tenant, owner, err := f.usageRepository.GetUsage(ctx, actor.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := int64(-1)
+	for _, usage := range []models.Usage{tenant, owner} {
+		usage.ApplyQuota(f.quotas[usage.Scope])
+		if usage.SoftExceeded {
+			logging.From(ctx).WithFields(logrus.Fields{"scope": usage.Scope, "tenant_id": usage.TenantID, "actor_id": usage.ActorID}).Warn("soft quota exceeded")
+		}
+		if usage.Quota.HardFiles > 0 && usage.Files >= usage.Quota.HardFiles {
+			return 0, quotaError(usage)
+		}
+
+		left := usage.RemainingBytes()
+		if left == 0 || (left > 0 && size > left) {
+			return 0, quotaError(usage)
+		}
+		if left > 0 && (remaining < 0 || left < remaining) {
+			remaining = left
+		}
+	}
+	return remaining, nil
+}
+
+// quotaReader fails the upload as soon as the body outgrows the remaining quota
+type quotaReader struct {
+	body     io.ReadCloser
+	limit    int64
+	read     int64
+	exceeded error
+}
+
+// Read method
+func (q *quotaReader) Read(p []byte) (int, error) {
+	if q.exceeded != nil {
+		return 0, q.exceeded
+	}
+	n, err := q.body.Read(p)
+	q.read += int64(n)
+	if q.read > q.limit {
+		q.exceeded = fmt.Errorf("%w: upload is larger than the remaining %d bytes", errQuotaExceeded, q.limit)
+		return n, q.exceeded
+	}
+	return n, err
+}
+
+// Close method
+func (q *quotaReader) Close() error {
+	return q.body.Close()
+}
+
+// GetUsage method returns the storage used by the actor and its tenant,
+// admins also get every actor of the tenant or a single one by actorID
+func (f *FileService) GetUsage(ctx context.Context, actor models.Actor, actorID int64) (models.UsageReport, error) {
+	ctx = tenantContext(ctx, actor)
+	report := models.UsageReport{}
+	if actorID != 0 && actorID != actor.ID && !actor.IsAdmin() {
+		return report, errForbidden
+	}
+	if actorID == 0 {
+		actorID = actor.ID
+	}
+
+	tenant, owner, err := f.usageRepository.GetUsage(ctx, actorID)
+	if err != nil {
+		return report, err
+	}
+	tenant.ApplyQuota(f.quotas[models.UsageTenant])
+	owner.ApplyQuota(f.quotas[models.UsageActor])
+	report.Tenant = tenant
+	report.Actor = owner
+
+	if actor.IsAdmin() {
+		actors, err := f.usageRepository.GetActorsUsage(ctx)
+		if err != nil {
+			return report, err
+		}
+		for i := range actors {
+			actors[i].ApplyQuota(f.quotas[models.UsageActor])
+		}
+		report.Actors = actors
+	}
+	return report, nil
+}
+
+// addedQuotaError turns the repository quota failure into a service error
+func addedQuotaError(err error) error {
+	if err == repositories.ErrQuotaExceeded {
+		return fmt.Errorf("%w: upload does not fit the remaining quota", errQuotaExceeded)
+	}
+	return err
+}
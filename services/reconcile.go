@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/metrics"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/storage"
+	"github.com/greatfocus/gf-document/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// reconcileProblems counts what the reconciler found by problem
+var reconcileProblems = metrics.NewCounter("reconcile_problems_total",
+	"Files and stored content found out of step by the reconciler, by problem.", "problem")
+
+// reconciler struct is the state of a reconcile run
+type reconciler struct {
+	f       *FileService
+	options models.ReconcileOptions
+	report  func(problem models.ReconcileProblem) error
+	result  models.ReconcileReport
+	names   map[string]struct{}
+	ids     map[string]struct{}
+	started time.Time
+}
+
+// Reconcile method compares the files table with the upload volume. Records
+// whose content is missing or differs in size, or in hash with Hash, are
+// reported and marked on repair. Approved content left in temp is moved.
+// Content and renditions without a record are reported and deleted on repair
+// once older than the grace period. Records are read before the volume so
+// content stored during the run is at most reported as a young orphan.
+func (f *FileService) Reconcile(ctx context.Context, enKey string, options models.ReconcileOptions, report func(problem models.ReconcileProblem) error) (models.ReconcileReport, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Reconcile", tracing.KindInternal)
+	defer span.End()
+
+	r := &reconciler{
+		f:       f,
+		options: options,
+		report:  report,
+		result:  models.ReconcileReport{Problems: map[string]int{}},
+		names:   map[string]struct{}{},
+		ids:     map[string]struct{}{},
+		started: time.Now(),
+	}
+	ctx = tenantContext(ctx, models.SystemActor)
+	err := f.eachFile(ctx, enKey, models.FileQuery{}, func(file models.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return r.file(ctx, file)
+	})
+	if err == nil {
+		err = f.storage.Blobs(func(blob storage.Blob) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return r.blob(ctx, blob)
+		})
+	}
+	if err == nil {
+		err = f.storage.RenditionFolders(func(tenantID string, fileID string, path string, modifiedOn time.Time) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return r.renditions(ctx, tenantID, fileID, path, modifiedOn)
+		})
+	}
+	r.result.DurationMs = time.Since(r.started).Milliseconds()
+	span.RecordError(err)
+	return r.result, err
+}
+
+// file checks the stored content of a record
+func (r *reconciler) file(ctx context.Context, file models.File) error {
+	r.result.Files++
+	r.names[blobKey(file.TenantID, file.Name)] = struct{}{}
+	r.ids[file.ID] = struct{}{}
+
+	problem := models.ReconcileProblem{FileID: file.ID, TenantID: file.TenantID}
+	path, found := r.f.storage.Path(file.TenantID, file.Name)
+	problem.Path = path
+	switch {
+	case !found:
+		problem.Problem = models.ProblemMissing
+	default:
+		info, err := os.Stat(path)
+		if err != nil {
+			problem.Problem = models.ProblemMissing
+			problem.Error = err.Error()
+			break
+		}
+		if info.Size() != file.Size {
+			problem.Problem = models.ProblemSize
+			problem.Expected = strconv.FormatInt(file.Size, 10)
+			problem.Actual = strconv.FormatInt(info.Size(), 10)
+			break
+		}
+		if r.options.Hash && file.Checksum != "" {
+			actual, err := hashFile(path)
+			if err != nil {
+				problem.Problem = models.ProblemMissing
+				problem.Error = err.Error()
+				break
+			}
+			if actual != file.Checksum {
+				problem.Problem = models.ProblemHash
+				problem.Expected = file.Checksum
+				problem.Actual = actual
+				break
+			}
+		}
+		if file.Status == "approved" && storage.InTemp(path) {
+			problem.Problem = models.ProblemMisplaced
+		}
+	}
+
+	if problem.Problem == "" || problem.Problem == models.ProblemMisplaced {
+		// the content is sound, a mark left by an earlier run is cleared
+		if r.options.Repair && file.Problem != "" {
+			if err := r.f.fileRepository.SetProblem(ctx, file.ID, ""); err != nil {
+				logging.From(ctx).WithError(err).WithField("file_id", file.ID).Error("file problem not cleared")
+			} else {
+				r.result.Cleared++
+			}
+		}
+		if problem.Problem == "" {
+			return nil
+		}
+	}
+
+	if r.options.Repair {
+		switch problem.Problem {
+		case models.ProblemMisplaced:
+			if r.f.storage.Move(file.TenantID, file.Name) {
+				problem.Repair = models.RepairMoved
+			}
+		case file.Problem:
+			problem.Repair = models.RepairMarked
+		default:
+			if err := r.f.fileRepository.SetProblem(ctx, file.ID, problem.Problem); err != nil {
+				problem.Error = err.Error()
+			} else {
+				problem.Repair = models.RepairMarked
+			}
+		}
+	}
+	return r.found(ctx, problem)
+}
+
+// blob checks stored content has a record. Content found under a recorded
+// name in another place than the one served is a leftover copy.
+func (r *reconciler) blob(ctx context.Context, blob storage.Blob) error {
+	r.result.Blobs++
+	if _, known := r.names[blobKey(blob.TenantID, blob.Name)]; known {
+		path, found := r.f.storage.Path(blob.TenantID, blob.Name)
+		if !found || path == blob.Path {
+			return nil
+		}
+	}
+	modifiedOn := blob.ModifiedOn
+	problem := models.ReconcileProblem{
+		Problem:    models.ProblemOrphan,
+		TenantID:   blob.TenantID,
+		Path:       blob.Path,
+		Actual:     strconv.FormatInt(blob.Size, 10),
+		ModifiedOn: &modifiedOn,
+	}
+	r.remove(&problem, modifiedOn)
+	return r.found(ctx, problem)
+}
+
+// renditions checks the renditions of a file have a record
+func (r *reconciler) renditions(ctx context.Context, tenantID string, fileID string, path string, modifiedOn time.Time) error {
+	r.result.Renditions++
+	if _, known := r.ids[fileID]; known {
+		return nil
+	}
+	problem := models.ReconcileProblem{
+		Problem:    models.ProblemOrphanRenditions,
+		FileID:     fileID,
+		TenantID:   tenantID,
+		Path:       path,
+		ModifiedOn: &modifiedOn,
+	}
+	r.remove(&problem, modifiedOn)
+	return r.found(ctx, problem)
+}
+
+// remove deletes the content of an orphan on repair once it is older than
+// the grace period, measured from the start of the run
+func (r *reconciler) remove(problem *models.ReconcileProblem, modifiedOn time.Time) {
+	if !r.options.Repair || r.started.Sub(modifiedOn) < r.options.Grace {
+		return
+	}
+	if err := r.f.storage.RemovePath(problem.Path); err != nil {
+		problem.Error = err.Error()
+		return
+	}
+	problem.Repair = models.RepairDeleted
+}
+
+// found counts and logs a problem then reports it
+func (r *reconciler) found(ctx context.Context, problem models.ReconcileProblem) error {
+	r.result.Problems[problem.Problem]++
+	switch problem.Repair {
+	case models.RepairDeleted, models.RepairMoved:
+		r.result.Repaired++
+	case models.RepairMarked:
+		r.result.Marked++
+	}
+	reconcileProblems.Inc(problem.Problem)
+	logging.From(ctx).WithFields(logrus.Fields{
+		"problem":   problem.Problem,
+		"file_id":   problem.FileID,
+		"tenant_id": problem.TenantID,
+		"path":      problem.Path,
+		"repair":    problem.Repair,
+	}).Warn("storage out of step")
+	return r.report(problem)
+}
+
+// blobKey identifies stored content by its tenant and name
+func blobKey(tenantID string, name string) string {
+	return tenantID + "/" + filepath.Base(name)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/rendition"
+	"github.com/greatfocus/gf-document/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// errNotFound is returned when a sub resource of a file does not exist
+var errNotFound = errors.New("resource does not exist")
+
+// IsNotFound checks if the error was caused by a missing resource
+func IsNotFound(err error) bool {
+	return errors.Is(err, errNotFound)
+}
+
+// renditionSpec finds the configured rendition by name
+func (f *FileService) renditionSpec(name string) (rendition.Spec, bool) {
+	for _, spec := range f.renditionSpecs {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return rendition.Spec{}, false
+}
+
+// renderFile resizes the source image, stores the result and records it
+func (f *FileService) renderFile(ctx context.Context, file models.File, path string, spec rendition.Spec) (models.Rendition, error) {
+	source, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return models.Rendition{}, err
+	}
+	defer source.Close()
+
+	result, err := rendition.Render(source, spec)
+	if err != nil {
+		return models.Rendition{}, err
+	}
+	filename := spec.Name + rendition.Extension(result.MimeType)
+	if err := f.storage.WriteRendition(file.TenantID, file.ID, filename, result.Data); err != nil {
+		return models.Rendition{}, err
+	}
+
+	return f.renditionRepository.Create(ctx, models.Rendition{
+		FileID:   file.ID,
+		Name:     spec.Name,
+		MimeType: result.MimeType,
+		Width:    result.Width,
+		Height:   result.Height,
+		Size:     int64(len(result.Data)),
+	})
+}
+
+// Rendition method returns the named rendition of an image and the location of
+// its content, generating it on the first request
+func (f *FileService) Rendition(ctx context.Context, enKey string, actor models.Actor, id string, name string) (models.Rendition, string, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Rendition", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
+	if err != nil {
+		return models.Rendition{}, "", errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+		return models.Rendition{}, "", err
+	}
+
+	spec, found := f.renditionSpec(name)
+	if !found || !rendition.Supported(file.MimeType) {
+		return models.Rendition{}, "", errNotFound
+	}
+
+	stored, err := f.renditionRepository.GetRendition(ctx, file.ID, spec.Name)
+	if err == nil {
+		filename := stored.Name + rendition.Extension(stored.MimeType)
+		if path, found := f.storage.RenditionPath(file.TenantID, file.ID, filename); found {
+			return stored, path, nil
+		}
+	} else if err != sql.ErrNoRows {
+		return models.Rendition{}, "", err
+	}
+
+	source, found := f.storage.Path(file.TenantID, file.Name)
+	if !found {
+		derr := errors.New("file content does not exist")
+		logging.From(ctx).WithError(derr).WithField("file_id", file.ID).Error("rendition source missing")
+		return models.Rendition{}, "", derr
+	}
+	created, err := f.renderFile(ctx, file, source, spec)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithFields(logrus.Fields{"file_id": file.ID, "rendition": spec.Name}).Error("rendition failed")
+		return models.Rendition{}, "", errors.New("cannot create rendition")
+	}
+	path, _ := f.storage.RenditionPath(file.TenantID, file.ID, created.Name+rendition.Extension(created.MimeType))
+	return created, path, nil
+}
+
+// GetRenditions method lists the renditions generated for a file
+func (f *FileService) GetRenditions(ctx context.Context, enKey string, actor models.Actor, id string) ([]models.Rendition, error) {
+	ctx = tenantContext(ctx, actor)
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
+	if err != nil {
+		return nil, errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantRead); err != nil {
+		return nil, err
+	}
+	return f.renditionRepository.GetRenditions(ctx, file.ID)
+}
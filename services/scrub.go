@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/greatfocus/gf-document/lifecycle"
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/metrics"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/storage"
+	"github.com/greatfocus/gf-document/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// scrubBatch is how many due files the scrubber reads at a time
+const scrubBatch = 100
+
+// scrubChunk bounds a read so the pace stays even on large files
+const scrubChunk = 64 << 10
+
+// Scrub metrics
+var (
+	scrubFiles = metrics.NewCounter("scrub_files_total",
+		"Files verified by the scrubber by result, ok, corrupt, missing or restored.", "result")
+	scrubBytes = metrics.NewCounter("scrub_bytes_total",
+		"Bytes read by the scrubber, replica copies included.")
+	filesProblem = metrics.NewGauge("files_problem",
+		"Files marked with a problem of their stored content.", "problem")
+)
+
+// replicaStorage returns the replica holding a copy of the upload volume,
+// STORAGE_REPLICA_PATH, nil when none is configured
+func replicaStorage() *storage.Local {
+	root := os.Getenv("STORAGE_REPLICA_PATH")
+	if root == "" {
+		return nil
+	}
+	replica := &storage.Local{}
+	replica.Init(root)
+	return replica
+}
+
+// Scrub method reads again the content of the hashed files not verified
+// within the interval, never verified first, and compares it with their
+// checksum. Content missing or corrupt is restored from the replica when it
+// holds a matching copy, otherwise the file is marked and the corruption
+// recorded in the audit log. It returns once no file is due or ctx is done.
+func (f *FileService) Scrub(ctx context.Context, enKey string, options models.ScrubOptions) (models.ScrubReport, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Scrub", tracing.KindInternal)
+	defer span.End()
+
+	report := models.ScrubReport{}
+	pace := newThrottle(options.BytesPerSecond)
+	before := time.Now().Add(-options.Interval)
+	ctx = tenantContext(ctx, models.SystemActor)
+
+	// verified files leave the due ones, paging by key skips those that failed
+	last := models.File{}
+	for {
+		files, err := f.fileRepository.GetUnverified(ctx, enKey, before, last.VerifiedOn, last.ID, scrubBatch)
+		if err != nil {
+			span.RecordError(err)
+			return report, err
+		}
+		for _, file := range files {
+			if err := f.scrubFile(ctx, file, pace, &report); err != nil {
+				return report, err
+			}
+			last = file
+		}
+		if len(files) < scrubBatch {
+			return report, nil
+		}
+	}
+}
+
+// scrubFile verifies the content of the file and records the result, it
+// only fails when ctx is done
+func (f *FileService) scrubFile(ctx context.Context, file models.File, pace *throttle, report *models.ScrubReport) error {
+	result := models.ScrubOK
+	actual := ""
+	path, found := f.storage.Path(file.TenantID, file.Name)
+	if !found {
+		result = models.ScrubMissing
+	} else {
+		checksum, read, err := pace.hash(ctx, path)
+		report.Bytes += read
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			// unreadable content is as good as missing
+			result = models.ScrubMissing
+		case checksum != file.Checksum:
+			result = models.ScrubCorrupt
+			actual = checksum
+		}
+	}
+
+	problem := ""
+	switch result {
+	case models.ScrubMissing:
+		problem = models.ProblemMissing
+	case models.ScrubCorrupt:
+		problem = models.ProblemHash
+	}
+	fields := logrus.Fields{
+		"file_id":   file.ID,
+		"tenant_id": file.TenantID,
+		"problem":   problem,
+	}
+	if problem != "" && f.replica != nil {
+		read, err := f.restore(ctx, file, pace)
+		report.Bytes += read
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			logging.From(ctx).WithError(err).WithFields(fields).Warn("file content not restored")
+		} else {
+			f.audit(ctx, models.SystemActor, models.AuditRestore, file, map[string]string{"problem": problem, "from": "replica"})
+			logging.From(ctx).WithFields(fields).Warn("file content restored")
+			result = models.ScrubRestored
+			problem = ""
+		}
+	}
+
+	// a corruption is recorded once, when the file is first found with it
+	if problem != "" && file.Problem != problem {
+		f.audit(ctx, models.SystemActor, models.AuditCorrupt, file, map[string]string{
+			"problem":  problem,
+			"expected": file.Checksum,
+			"actual":   actual,
+		})
+		logging.From(ctx).WithFields(fields).Error("file content corrupt")
+	}
+	if err := f.fileRepository.SetVerified(ctx, file.ID, problem); err != nil {
+		logging.From(ctx).WithError(err).WithFields(fields).Error("file verification not recorded")
+	}
+
+	report.Files++
+	switch result {
+	case models.ScrubCorrupt:
+		report.Corrupt++
+	case models.ScrubMissing:
+		report.Missing++
+	case models.ScrubRestored:
+		report.Restored++
+	}
+	scrubFiles.Inc(result)
+	return nil
+}
+
+// restore replaces the content of the file with the copy held by the
+// replica. The copy is checked before the content is replaced and again while
+// it is copied, a copy not matching the checksum is refused.
+func (f *FileService) restore(ctx context.Context, file models.File, pace *throttle) (int64, error) {
+	path, found := f.replica.Path(file.TenantID, file.Name)
+	if !found {
+		return 0, errors.New("file content not in replica")
+	}
+	checksum, read, err := pace.hash(ctx, path)
+	if err != nil {
+		return read, err
+	}
+	if checksum != file.Checksum {
+		return read, errors.New("replica content does not match the checksum")
+	}
+
+	src, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return read, err
+	}
+	defer src.Close()
+	copied := sha256.New()
+	content := &throttledReader{ctx: ctx, reader: io.TeeReader(src, copied), pace: pace}
+	err = f.storage.Replace(file.TenantID, file.Name, file.Status == "approved", content)
+	read += content.read
+	if err != nil {
+		return read, err
+	}
+	if hex.EncodeToString(copied.Sum(nil)) != file.Checksum {
+		return read, errors.New("replica content changed while restored")
+	}
+	return read, nil
+}
+
+// throttle paces reads to a number of bytes per second over a scrub
+type throttle struct {
+	rate    int64
+	started time.Time
+	read    int64
+}
+
+// newThrottle returns a throttle allowing rate bytes per second
+func newThrottle(rate int64) *throttle {
+	return &throttle{rate: rate, started: time.Now()}
+}
+
+// wait counts n bytes read and sleeps until the pace allows them, a throttle
+// without a rate never waits
+func (t *throttle) wait(ctx context.Context, n int) error {
+	t.read += int64(n)
+	scrubBytes.Add(float64(n))
+	if t.rate <= 0 {
+		return nil
+	}
+	due := t.started.Add(time.Duration(float64(t.read) / float64(t.rate) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		return lifecycle.Sleep(ctx, wait)
+	}
+	return nil
+}
+
+// hash returns the SHA-256 of the content at path with the bytes read
+func (t *throttle) hash(ctx context.Context, path string) (string, int64, error) {
+	src, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	sum := sha256.New()
+	content := &throttledReader{ctx: ctx, reader: src, pace: t}
+	if _, err := io.Copy(sum, content); err != nil {
+		return "", content.read, err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), content.read, nil
+}
+
+// throttledReader struct reads through a throttle
+type throttledReader struct {
+	ctx    context.Context
+	reader io.Reader
+	pace   *throttle
+	read   int64
+}
+
+// Read reads at most scrubChunk bytes then waits for the pace
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > scrubChunk {
+		p = p[:scrubChunk]
+	}
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if werr := r.pace.wait(r.ctx, n); werr != nil {
+		return n, werr
+	}
+	return n, err
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/greatfocus/gf-document/extract"
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/tracing"
+)
+
+// maxSearchLimit bounds the page size of a search
+const maxSearchLimit = 100
+
+// searchLanguages are the text search configurations documents may be indexed with
+var searchLanguages = map[string]bool{
+	"simple": true, "danish": true, "dutch": true, "english": true, "finnish": true,
+	"french": true, "german": true, "hungarian": true, "italian": true, "norwegian": true,
+	"portuguese": true, "romanian": true, "russian": true, "spanish": true, "swedish": true,
+	"turkish": true,
+}
+
+// searchLanguage returns the configuration to use, SEARCH_LANGUAGE when unset or unknown
+func searchLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if searchLanguages[language] {
+		return language
+	}
+	if fallback := strings.ToLower(os.Getenv("SEARCH_LANGUAGE")); searchLanguages[fallback] {
+		return fallback
+	}
+	return "simple"
+}
+
+// indexFile extracts the text of an upload and stores it for search, a document
+// whose text cannot be extracted is still indexed by its metadata
+func (f *FileService) indexFile(ctx context.Context, file models.File, path string, language string, metadata ...string) error {
+	content, err := extract.Text(ctx, path, file.MimeType)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", file.ID).Warn("text extraction failed")
+	}
+
+	fields := append(metadata, file.Extension, file.MimeType)
+	return f.searchRepository.Index(ctx, file.ID, searchLanguage(language),
+		extract.Normalise(strings.Join(fields, " ")), content)
+}
+
+// Search method returns a page of the files the actor may read ranked against the query
+func (f *FileService) Search(ctx context.Context, enKey string, actor models.Actor, query models.SearchQuery) (models.SearchPage, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Search", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	err := query.ValidateSearch()
+	if err != nil {
+		return models.SearchPage{}, err
+	}
+
+	query.Language = searchLanguage(query.Language)
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	if query.Limit > maxSearchLimit {
+		query.Limit = maxSearchLimit
+	}
+	return f.searchRepository.Search(ctx, enKey, actor, query)
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/rendition"
+	"github.com/greatfocus/gf-document/repositories"
+	"github.com/greatfocus/gf-document/storage"
+	"github.com/greatfocus/gf-document/tracing"
+)
+
+// Layout of an export
+const (
+	exportManifest = "manifest.jsonl"
+	exportContent  = "content"
+)
+
+// Export method copies the files matching the query with their content to
+// dir, writing a manifest line per file. Files whose content is missing or
+// does not match its checksum are left out and reported as failed.
+func (f *FileService) Export(ctx context.Context, enKey string, query models.FileQuery, dir string) (models.TransferResult, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Export", tracing.KindInternal)
+	defer span.End()
+
+	result := models.TransferResult{}
+	if err := os.MkdirAll(filepath.Join(dir, exportContent), 0750); err != nil {
+		return result, err
+	}
+	manifest, err := os.OpenFile(filepath.Join(dir, exportManifest), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return result, err
+	}
+	defer manifest.Close()
+	encoder := json.NewEncoder(manifest)
+
+	err = f.eachFile(ctx, enKey, query, func(file models.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, err := f.exportFile(repositories.WithTenant(ctx, file.TenantID), file, dir)
+		if err != nil {
+			logging.From(ctx).WithError(err).WithField("file_id", file.ID).Warn("file not exported")
+			result.Failed = append(result.Failed, file.ID)
+			return nil
+		}
+		result.Files++
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, manifest.Sync()
+}
+
+// exportFile copies the content of the file into dir and returns its manifest line
+func (f *FileService) exportFile(ctx context.Context, file models.File, dir string) (models.ExportRecord, error) {
+	record := models.ExportRecord{FileRecord: models.NewFileRecord(file)}
+	if !storage.ValidTenant(file.TenantID) {
+		return record, errors.New("invalid tenant")
+	}
+	result := f.verifyChecksum(file)
+	switch result.Status {
+	case models.ChecksumMissing:
+		return record, errors.New("file content does not exist")
+	case models.ChecksumMismatch:
+		return record, errors.New("file content does not match its checksum")
+	}
+	path, _ := f.storage.Path(file.TenantID, file.Name)
+	if record.Checksum == "" {
+		checksum, err := hashFile(path)
+		if err != nil {
+			return record, err
+		}
+		record.Checksum = checksum
+	}
+
+	record.Content = filepath.ToSlash(filepath.Join(exportContent, file.TenantID, filepath.Base(file.Name)))
+	if err := copyFile(path, filepath.Join(dir, filepath.FromSlash(record.Content))); err != nil {
+		return record, err
+	}
+	grants, err := f.grantRepository.GetGrants(ctx, file.ID)
+	if err != nil {
+		return record, err
+	}
+	record.Grants = grants
+	return record, nil
+}
+
+// Import method stores the files of an export made by Export, keeping their
+// ids. Files whose id or content already exists are skipped so an import can
+// be repeated, content not matching its checksum is reported as failed.
+// Imported files are indexed again in the background.
+func (f *FileService) Import(ctx context.Context, enKey string, dir string) (models.TransferResult, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Import", tracing.KindInternal)
+	defer span.End()
+
+	result := models.TransferResult{}
+	manifest, err := os.Open(filepath.Join(dir, exportManifest))
+	if err != nil {
+		return result, err
+	}
+	defer manifest.Close()
+
+	decoder := json.NewDecoder(manifest)
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		record := models.ExportRecord{}
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("invalid manifest: %w", err)
+		}
+
+		imported, err := f.importFile(repositories.WithTenant(ctx, record.TenantID), enKey, record, dir)
+		switch {
+		case err != nil:
+			logging.From(ctx).WithError(err).WithField("file_id", record.ID).Warn("file not imported")
+			result.Failed = append(result.Failed, record.ID)
+		case imported:
+			result.Files++
+		default:
+			result.Skipped++
+		}
+	}
+}
+
+// importFile stores the content of the record then inserts it, reporting false
+// when the file already exists
+func (f *FileService) importFile(ctx context.Context, enKey string, record models.ExportRecord, dir string) (bool, error) {
+	file := record.File
+	file.TenantID = record.TenantID
+	file.CreatedOn = record.CreatedOn
+	if file.ID == "" || !storage.ValidTenant(file.TenantID) {
+		return false, errors.New("invalid record")
+	}
+	if err := file.ValidateFile("add"); err != nil {
+		return false, err
+	}
+
+	// the content must stay within the export
+	content := filepath.Clean(filepath.FromSlash(record.Content))
+	if filepath.IsAbs(content) || content == ".." || strings.HasPrefix(content, ".."+string(filepath.Separator)) {
+		return false, errors.New("invalid content path")
+	}
+	source := filepath.Join(dir, content)
+	checksum, err := hashFile(source)
+	if err != nil {
+		return false, err
+	}
+	if file.Checksum != "" && checksum != file.Checksum {
+		return false, errors.New("file content does not match its checksum")
+	}
+	file.Checksum = checksum
+
+	if _, found := f.storage.Path(file.TenantID, file.Name); found {
+		return false, nil
+	}
+	src, err := os.Open(source)
+	if err != nil {
+		return false, err
+	}
+	err = f.storage.Write(file.TenantID, file.Name, file.Status == "approved", src)
+	src.Close()
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := f.fileRepository.Import(ctx, enKey, file)
+	if err != nil || !inserted {
+		f.storage.Remove(file.TenantID, file.Name)
+		return false, err
+	}
+	for _, grant := range record.Grants {
+		grant.FileID = file.ID
+		// a grant of the file can only exist if it was imported before
+		_, _ = f.grantRepository.Create(ctx, grant)
+	}
+	f.audit(ctx, models.SystemActor, models.AuditUpload, file, map[string]string{"import": "true"})
+
+	// search and renditions are not exported, they are built again
+	f.enqueue(ctx, file, JobExtract, nil)
+	if rendition.Eager() && rendition.Supported(file.MimeType) {
+		f.enqueue(ctx, file, JobRenditions, nil)
+	}
+	return true, nil
+}
+
+// copyFile copies the content at from to a new file at to
+func copyFile(from string, to string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(to), 0750); err != nil {
+		return err
+	}
+	src, err := os.Open(filepath.Clean(from))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = io.Copy(dst, src)
+	return err
+}
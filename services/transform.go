@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/rendition"
+	"github.com/greatfocus/gf-document/tracing"
+)
+
+// defaultTransformCacheBytes bounds the transform cache when TRANSFORM_CACHE_BYTES is unset
+const defaultTransformCacheBytes = 512 << 20
+
+// transformCacheBytes reads the size of the transform cache from the environment
+func transformCacheBytes() int64 {
+	value, err := strconv.ParseInt(os.Getenv("TRANSFORM_CACHE_BYTES"), 0, 64)
+	if err != nil || value <= 0 {
+		return defaultTransformCacheBytes
+	}
+	return value
+}
+
+// mimeTypeOf returns the type of cached content from its extension
+func mimeTypeOf(path string) string {
+	return mime.TypeByExtension(filepath.Ext(path))
+}
+
+// Transform method resizes, crops and re-encodes an image for the signed options
+// in values, returning the result and the location of its cached content
+func (f *FileService) Transform(ctx context.Context, enKey string, actor models.Actor, id string, values url.Values) (models.Rendition, string, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Transform", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	options, err := rendition.ParseOptions(values)
+	if err != nil {
+		return models.Rendition{}, "", err
+	}
+	if len(f.transformKey) == 0 {
+		return models.Rendition{}, "", fmt.Errorf("%w: transformations are not enabled", errForbidden)
+	}
+	if !rendition.Verify(f.transformKey, id, options, values.Get("sig")) {
+		return models.Rendition{}, "", fmt.Errorf("%w: invalid signature", errForbidden)
+	}
+
+	file, source, err := f.imageFile(ctx, enKey, actor, id)
+	if err != nil {
+		return models.Rendition{}, "", err
+	}
+
+	sum := sha256.Sum256([]byte(options.Canonical()))
+	result := models.Rendition{FileID: file.ID, Name: "transform-" + hex.EncodeToString(sum[:16]), CreatedOn: file.CreatedOn}
+	if path, found := f.cache.Get(file.TenantID, file.ID, result.Name); found {
+		result.MimeType = mimeTypeOf(path)
+		return result, path, nil
+	}
+
+	content, err := os.Open(filepath.Clean(source))
+	if err != nil {
+		return models.Rendition{}, "", err
+	}
+	defer content.Close()
+
+	transformed, err := rendition.Transform(content, options)
+	if err == rendition.ErrTooLarge {
+		return models.Rendition{}, "", err
+	}
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", file.ID).Error("transform failed")
+		return models.Rendition{}, "", errors.New("cannot transform file")
+	}
+	path, err := f.cache.Put(file.TenantID, file.ID, result.Name, rendition.Extension(transformed.MimeType), transformed.Data)
+	if err != nil {
+		logging.From(ctx).WithError(err).WithField("file_id", file.ID).Error("transform not cached")
+		return models.Rendition{}, "", errors.New("cannot transform file")
+	}
+
+	result.MimeType = transformed.MimeType
+	result.Width = transformed.Width
+	result.Height = transformed.Height
+	result.Size = int64(len(transformed.Data))
+	return result, path, nil
+}
+
+// SignTransform method signs the options in values for an image, so the
+// application can hand out transform URLs. Signing is left to actors who may
+// change the file, readers only use the parameter sets they are given.
+func (f *FileService) SignTransform(ctx context.Context, enKey string, actor models.Actor, id string, values url.Values) (models.TransformSignature, error) {
+	ctx, span := tracing.Start(ctx, "FileService.SignTransform", tracing.KindInternal)
+	defer span.End()
+
+	ctx = tenantContext(ctx, actor)
+	options, err := rendition.ParseOptions(values)
+	if err != nil {
+		return models.TransformSignature{}, err
+	}
+	if len(f.transformKey) == 0 {
+		return models.TransformSignature{}, fmt.Errorf("%w: transformations are not enabled", errForbidden)
+	}
+
+	file, err := f.fileRepository.GetFileByID(ctx, enKey, id)
+	if err != nil {
+		return models.TransformSignature{}, errors.New("record does not exist")
+	}
+	if err := f.authorize(ctx, actor, file, models.GrantWrite); err != nil {
+		return models.TransformSignature{}, err
+	}
+	if !rendition.Supported(file.MimeType) {
+		return models.TransformSignature{}, errNotFound
+	}
+
+	signature := rendition.Sign(f.transformKey, file.ID, options)
+	return models.TransformSignature{
+		FileID:    file.ID,
+		Canonical: file.ID + "?" + options.Canonical(),
+		Signature: signature,
+		Query:     options.Canonical() + "&sig=" + signature,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheFolder holds generated content of the files of a tenant, one folder per file
+const cacheFolder = "cache"
+
+// cacheKeyPattern restricts cache keys to safe file names
+var cacheKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// errInvalidKey is returned for cache keys that cannot be used as a file name
+var errInvalidKey = errors.New("invalid cache key")
+
+// Cache struct keeps generated content on the upload volume up to a total
+// size, evicting what was used least recently once it is full
+type Cache struct {
+	local    *Local
+	maxBytes int64
+	mutex    sync.Mutex
+	size     int64
+	loaded   bool
+}
+
+// Init method
+func (c *Cache) Init(local *Local, maxBytes int64) {
+	c.local = local
+	c.maxBytes = maxBytes
+}
+
+// folder returns the cache directory of a file
+func (c *Cache) folder(tenantID string, fileID string) (string, error) {
+	path, err := c.local.tenantPath(tenantID)
+	if err != nil {
+		return "", err
+	}
+	if !tenantPattern.MatchString(fileID) {
+		return "", errInvalidFile
+	}
+	return filepath.Join(path, cacheFolder, fileID), nil
+}
+
+// Get finds cached content by key whatever its extension and marks it used
+func (c *Cache) Get(tenantID string, fileID string, key string) (string, bool) {
+	path, err := c.folder(tenantID, fileID)
+	if err != nil || !cacheKeyPattern.MatchString(key) {
+		return "", false
+	}
+	matches, _ := filepath.Glob(filepath.Join(path, key+".*"))
+	if len(matches) == 0 {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(matches[0], now, now)
+	return matches[0], true
+}
+
+// Put stores content under key and extension, then evicts old entries if the cache is full
+func (c *Cache) Put(tenantID string, fileID string, key string, extension string, data []byte) (string, error) {
+	path, err := c.folder(tenantID, fileID)
+	if err != nil {
+		return "", err
+	}
+	if !cacheKeyPattern.MatchString(key) {
+		return "", errInvalidKey
+	}
+	if !createFolder(path) {
+		return "", errors.New("cannot create folder")
+	}
+
+	temp, err := os.CreateTemp(path, ".cache-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return "", err
+	}
+	if err := temp.Close(); err != nil {
+		return "", err
+	}
+	path = filepath.Join(path, filepath.Base(key+extension))
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.loaded {
+		c.size = 0
+		for _, entry := range c.entries() {
+			c.size += entry.size
+		}
+		c.loaded = true
+	} else {
+		c.size += int64(len(data))
+	}
+	if c.maxBytes > 0 && c.size > c.maxBytes {
+		c.evict()
+	}
+	return path, nil
+}
+
+// Drop removes the cached content of a file
+func (c *Cache) Drop(tenantID string, fileID string) {
+	path, err := c.folder(tenantID, fileID)
+	if err != nil {
+		return
+	}
+	os.RemoveAll(path)
+}
+
+// cacheEntry is a cached file seen while measuring the cache
+type cacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// entries lists the cached files of every tenant
+func (c *Cache) entries() []cacheEntry {
+	matches, _ := filepath.Glob(filepath.Join(c.local.root, "*", cacheFolder, "*", "*"))
+	entries := make([]cacheEntry, 0, len(matches))
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		entries = append(entries, cacheEntry{path: match, size: info.Size(), modTime: info.ModTime()})
+	}
+	return entries
+}
+
+// evict removes the least recently used entries until the cache is down to 90% of its size
+func (c *Cache) evict() {
+	entries := c.entries()
+	c.size = 0
+	for _, entry := range entries {
+		c.size += entry.size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	target := c.maxBytes / 10 * 9
+	for _, entry := range entries {
+		if c.size <= target {
+			return
+		}
+		if os.Remove(entry.path) == nil {
+			c.size -= entry.size
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// tempFolder holds uploads until they are approved
+const tempFolder = "Temp"
+
+// legacyTenant owns the files stored before paths were prefixed per tenant
+const legacyTenant = "default"
+
+// tenantPattern restricts tenant identifiers to safe directory names
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// errInvalidTenant is returned for tenants that cannot be used as a directory
+var errInvalidTenant = errors.New("invalid tenant")
+
+// Local struct stores file content on the upload volume under a directory per tenant
+type Local struct {
+	root string
+}
+
+// Init method
+func (l *Local) Init(root string) {
+	l.root = root
+}
+
+// Root returns the upload volume
+func (l *Local) Root() string {
+	return l.root
+}
+
+// ValidTenant checks if the tenant can be used as a directory
+func ValidTenant(tenantID string) bool {
+	return tenantPattern.MatchString(tenantID)
+}
+
+// tenantPath returns the directory of the tenant
+func (l *Local) tenantPath(tenantID string) (string, error) {
+	if !ValidTenant(tenantID) {
+		return "", errInvalidTenant
+	}
+	return filepath.Join(l.root, tenantID), nil
+}
+
+// createFolder make dir
+func createFolder(path string) bool {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		errDir := os.MkdirAll(path, 0755)
+		return errDir == nil
+	}
+	return true
+}
+
+// CreateTemp creates a new file in the temp folder of the tenant
+func (l *Local) CreateTemp(tenantID string, pattern string) (*os.File, error) {
+	path, err := l.tenantPath(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Join(path, tempFolder)
+	if !createFolder(path) {
+		return nil, errors.New("cannot create folder")
+	}
+	return os.CreateTemp(path, pattern)
+}
+
+// tempPath returns the temp location of the file, falling back to the
+// legacy location for files uploaded before paths were prefixed per tenant
+func (l *Local) tempPath(tenantID string, filename string) (string, error) {
+	path, err := l.tenantPath(tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	filename = filepath.Base(filename)
+	path = filepath.Join(path, tempFolder, filename)
+	if _, err := os.Stat(path); err != nil && tenantID == legacyTenant {
+		legacy := filepath.Join(l.root, tempFolder, filename)
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy, nil
+		}
+	}
+	return path, nil
+}
+
+// Exists check file exist in the temp folder of the tenant
+func (l *Local) Exists(tenantID string, filename string) bool {
+	path, err := l.tempPath(tenantID, filename)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return !info.IsDir()
+}
+
+// Drop removes the file from the temp folder of the tenant
+func (l *Local) Drop(tenantID string, filename string) {
+	path, err := l.tempPath(tenantID, filename)
+	if err != nil {
+		return
+	}
+	os.Remove(path)
+}
+
+// Move cuts the file out of temp after it is approved
+func (l *Local) Move(tenantID string, filename string) bool {
+	oldLocation, err := l.tempPath(tenantID, filename)
+	if err != nil {
+		return false
+	}
+	newLocation, _ := l.tenantPath(tenantID)
+	if !createFolder(newLocation) {
+		return false
+	}
+
+	err = os.Rename(oldLocation, filepath.Join(newLocation, filepath.Base(filename)))
+	return err == nil
+}
+
+// Path finds the file whether it is approved or still in temp
+func (l *Local) Path(tenantID string, filename string) (string, bool) {
+	path, err := l.tenantPath(tenantID)
+	if err != nil {
+		return "", false
+	}
+
+	filename = filepath.Base(filename)
+	paths := []string{filepath.Join(path, filename), filepath.Join(path, tempFolder, filename)}
+	if tenantID == legacyTenant {
+		paths = append(paths, filepath.Join(l.root, filename), filepath.Join(l.root, tempFolder, filename))
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// InTemp reports if a path found by Path is in a temp folder
+func InTemp(path string) bool {
+	return filepath.Base(filepath.Dir(path)) == tempFolder
+}
+
+// Remove deletes the file whether it is approved or still in temp
+func (l *Local) Remove(tenantID string, filename string) bool {
+	path, found := l.Path(tenantID, filename)
+	if !found {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// Write stores content as the file of the tenant, in temp unless approved. It
+// fails when the file exists.
+func (l *Local) Write(tenantID string, filename string, approved bool, content io.Reader) (err error) {
+	path, err := l.tenantPath(tenantID)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		path = filepath.Join(path, tempFolder)
+	}
+	if !createFolder(path) {
+		return errors.New("cannot create folder")
+	}
+
+	path = filepath.Join(path, filepath.Base(filename))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	_, err = io.Copy(file, content)
+	return err
+}
+
+// Replace stores content as the file of the tenant atomically, where the file
+// is found or else in temp unless approved
+func (l *Local) Replace(tenantID string, filename string, approved bool, content io.Reader) (err error) {
+	path, found := l.Path(tenantID, filename)
+	if !found {
+		if path, err = l.tenantPath(tenantID); err != nil {
+			return err
+		}
+		if !approved {
+			path = filepath.Join(path, tempFolder)
+		}
+		if !createFolder(path) {
+			return errors.New("cannot create folder")
+		}
+		path = filepath.Join(path, filepath.Base(filename))
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".replace-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := io.Copy(temp, content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// renditionFolder holds the renditions of the files of a tenant, one folder per file
+const renditionFolder = "renditions"
+
+// errInvalidFile is returned for file ids that cannot be used as a directory
+var errInvalidFile = errors.New("invalid file")
+
+// renditionsPath returns the directory of the renditions of a file
+func (l *Local) renditionsPath(tenantID string, fileID string) (string, error) {
+	path, err := l.tenantPath(tenantID)
+	if err != nil {
+		return "", err
+	}
+	if !tenantPattern.MatchString(fileID) {
+		return "", errInvalidFile
+	}
+	return filepath.Join(path, renditionFolder, fileID), nil
+}
+
+// WriteRendition stores a rendition of the file, replacing the previous one atomically
+func (l *Local) WriteRendition(tenantID string, fileID string, filename string, data []byte) error {
+	path, err := l.renditionsPath(tenantID, fileID)
+	if err != nil {
+		return err
+	}
+	if !createFolder(path) {
+		return errors.New("cannot create folder")
+	}
+
+	temp, err := os.CreateTemp(path, ".rendition-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filepath.Join(path, filepath.Base(filename)))
+}
+
+// RenditionPath finds a stored rendition of the file
+func (l *Local) RenditionPath(tenantID string, fileID string, filename string) (string, bool) {
+	path, err := l.renditionsPath(tenantID, fileID)
+	if err != nil {
+		return "", false
+	}
+	path = filepath.Join(path, filepath.Base(filename))
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
+
+// DropRenditions removes every rendition of the file
+func (l *Local) DropRenditions(tenantID string, fileID string) {
+	path, err := l.renditionsPath(tenantID, fileID)
+	if err != nil {
+		return
+	}
+	os.RemoveAll(path)
+}
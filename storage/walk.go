@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errInvalidPath is returned for paths outside the upload volume
+var errInvalidPath = errors.New("invalid path")
+
+// Blob struct is content found on the upload volume
+type Blob struct {
+	TenantID   string
+	Name       string
+	Path       string
+	Temp       bool
+	Size       int64
+	ModifiedOn time.Time
+}
+
+// Blobs calls fn with the content of every tenant, approved or in temp,
+// including the legacy files of the default tenant. Renditions, the cache and
+// hidden files such as those being written are left out.
+func (l *Local) Blobs(fn func(blob Blob) error) error {
+	entries, err := os.ReadDir(l.root)
+	if err != nil {
+		return err
+	}
+	if err := l.blobsIn(l.root, legacyTenant, false, fn); err != nil {
+		return err
+	}
+	if err := l.blobsIn(filepath.Join(l.root, tempFolder), legacyTenant, true, fn); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == tempFolder || !ValidTenant(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(l.root, entry.Name())
+		if err := l.blobsIn(path, entry.Name(), false, fn); err != nil {
+			return err
+		}
+		if err := l.blobsIn(filepath.Join(path, tempFolder), entry.Name(), true, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blobsIn calls fn with the files directly in dir, a missing dir has none
+func (l *Local) blobsIn(dir string, tenantID string, temp bool, fn func(blob Blob) error) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// removed since the folder was read
+			continue
+		}
+		blob := Blob{
+			TenantID:   tenantID,
+			Name:       entry.Name(),
+			Path:       filepath.Join(dir, entry.Name()),
+			Temp:       temp,
+			Size:       info.Size(),
+			ModifiedOn: info.ModTime(),
+		}
+		if err := fn(blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenditionFolders calls fn with the tenant, file id and folder of the
+// renditions of every file, with the time the folder last changed
+func (l *Local) RenditionFolders(fn func(tenantID string, fileID string, path string, modifiedOn time.Time) error) error {
+	tenants, err := os.ReadDir(l.root)
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants {
+		if !tenant.IsDir() || !ValidTenant(tenant.Name()) {
+			continue
+		}
+		dir := filepath.Join(l.root, tenant.Name(), renditionFolder)
+		folders, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, folder := range folders {
+			if !folder.IsDir() {
+				continue
+			}
+			info, err := folder.Info()
+			if err != nil {
+				continue
+			}
+			if err := fn(tenant.Name(), folder.Name(), filepath.Join(dir, folder.Name()), info.ModTime()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RemovePath deletes content found by Blobs or RenditionFolders, refusing
+// paths outside the upload volume
+func (l *Local) RemovePath(path string) error {
+	rel, err := filepath.Rel(l.root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errInvalidPath
+	}
+	return os.RemoveAll(path)
+}
@@ -0,0 +1,32 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/greatfocus/gf-document/models"
+)
+
+// auditVerifyTimeout bounds a verification of every chain
+const auditVerifyTimeout = time.Hour
+
+// VerifyAudit start the job to check the audit chains of every tenant for gaps
+// and tampering, it reports false when a chain is broken or cannot be read
+func (t *Tasks) VerifyAudit() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), auditVerifyTimeout)
+	defer cancel()
+
+	t.server.Logger.Info("Scheduler_VerifyAudit started")
+	results, err := t.fileService.VerifyAudit(ctx, models.SystemActor)
+	if err != nil {
+		t.server.Logger.Warn(fmt.Sprintf("Scheduler_VerifyAudit Error %v", err))
+		return false
+	}
+	valid := true
+	for _, result := range results {
+		valid = valid && result.Valid
+	}
+	t.server.Logger.Info(fmt.Sprintf("Scheduler_VerifyAudit ended, %d chains checked, valid %t", len(results), valid))
+	return valid
+}
@@ -0,0 +1,114 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/greatfocus/gf-document/lifecycle"
+	"github.com/greatfocus/gf-document/logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// reconnectDelay is how long a consumer waits before connecting to the broker again
+const reconnectDelay = 10 * time.Second
+
+// Queues are the queues the service consumes
+var Queues = []string{"post.event.approved", "post.event.delete"}
+
+// EventsListerner starts the consumers of the events the service acts on, they
+// run until ctx is done
+func (t *Tasks) EventsListerner(ctx context.Context) {
+	t.listen(ctx, Queues[0], t.approveDocument)
+	t.listen(ctx, Queues[1], t.deleteDocument)
+}
+
+// WaitConsumers blocks until the consumers acknowledged their current message after ctx is done
+func (t *Tasks) WaitConsumers() {
+	t.consumers.Wait()
+}
+
+// listen consumes the queue, connecting again whenever the connection is lost
+func (t *Tasks) listen(ctx context.Context, queue string, handler func(ctx context.Context, d amqp.Delivery) error) {
+	url := os.Getenv("RABBITMQ_URL")
+	if url == "" {
+		t.server.Logger.WithField("queue", queue).Info("consumer disabled, RABBITMQ_URL is not set")
+		return
+	}
+
+	handle := consume(queue, handler)
+	t.consumers.Add(1)
+	go func() {
+		defer t.consumers.Done()
+		for {
+			if err := t.consumeQueue(ctx, url, queue, handle); err != nil {
+				t.server.Logger.WithError(err).WithField("queue", queue).Warn("consumer disconnected")
+			}
+			if lifecycle.Sleep(ctx, reconnectDelay) != nil {
+				return
+			}
+		}
+	}()
+}
+
+// consumeQueue handles the messages of the queue one at a time until ctx is
+// done or the connection is lost. A message is acknowledged once handled, so
+// stopping lets the current message finish then cancels the subscription and
+// the broker requeues whatever it delivered but was not acknowledged. A message
+// failing is requeued once, then moved to the dead letter queue.
+func (t *Tasks) consumeQueue(ctx context.Context, url string, queue string, handle func(d amqp.Delivery) error) error {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = channel.Close()
+	}()
+	if err := channel.Qos(1, 0, false); err != nil {
+		return err
+	}
+	if err := declare(channel, queue); err != nil {
+		return err
+	}
+
+	tag := fmt.Sprintf("%s-%s", t.server.Name, uuid.New().String())
+	msgs, err := channel.Consume(queue, tag, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	logging.From(ctx).WithField("queue", queue).Info("consumer started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return channel.Cancel(tag, false)
+		case d, ok := <-msgs:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			// messages of other applications go back to the queue
+			if d.AppId != t.server.Name {
+				_ = d.Nack(false, true)
+				continue
+			}
+			if err := handle(d); err != nil {
+				// a message failing again once requeued is set aside rather than retried forever
+				if !d.Redelivered || deadLetter(channel, queue, d, err) != nil {
+					_ = d.Nack(false, true)
+					continue
+				}
+			}
+			_ = d.Ack(false)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// deadLetterSuffix names the queue holding the failed messages of a queue
+const deadLetterSuffix = ".dead"
+
+// publishTimeout bounds the wait for the broker to confirm a message
+const publishTimeout = 10 * time.Second
+
+// Headers describing why a message was dead lettered
+const (
+	headerError    = "x-error"
+	headerFailedOn = "x-failed-on"
+	headerReplays  = "x-replays"
+)
+
+// DeadLetterQueue returns the name of the dead letter queue of queue
+func DeadLetterQueue(queue string) string {
+	return queue + deadLetterSuffix
+}
+
+// declare declares the queue and its dead letter queue, both durable, and puts
+// the channel in confirm mode for the messages moved between them
+func declare(channel *amqp.Channel, queue string) error {
+	if err := channel.Confirm(false); err != nil {
+		return err
+	}
+	if _, err := channel.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	_, err := channel.QueueDeclare(DeadLetterQueue(queue), true, false, false, false, nil)
+	return err
+}
+
+// deadLetter copies the delivery to the dead letter queue of queue with the
+// error that stopped it, the delivery may be acknowledged once this succeeded
+func deadLetter(channel *amqp.Channel, queue string, d amqp.Delivery, cause error) error {
+	headers := amqp.Table{}
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	headers[headerError] = cause.Error()
+	headers[headerFailedOn] = time.Now().UTC().Format(time.RFC3339)
+	return publish(channel, DeadLetterQueue(queue), d, headers)
+}
+
+// publish sends a copy of the delivery with headers to queue and waits for the
+// broker to confirm it, the channel must be in confirm mode
+func publish(channel *amqp.Channel, queue string, d amqp.Delivery, headers amqp.Table) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		CorrelationId:   d.CorrelationId,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	})
+	if err != nil {
+		return err
+	}
+	confirmed, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return errors.New("message not confirmed by the broker")
+	}
+	return nil
+}
+
+// ReplayDeadLetters moves up to limit messages from the dead letter queue of
+// queue back to queue until ctx is done and returns how many moved. A message leaves the dead
+// letter queue only once the broker confirmed its copy.
+func ReplayDeadLetters(ctx context.Context, url string, queue string, limit int) (int, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	channel, err := conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = channel.Close()
+	}()
+	if err := declare(channel, queue); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for count < limit && ctx.Err() == nil {
+		d, found, err := channel.Get(DeadLetterQueue(queue), false)
+		if err != nil || !found {
+			return count, err
+		}
+
+		headers := amqp.Table{}
+		for key, value := range d.Headers {
+			headers[key] = value
+		}
+		delete(headers, headerError)
+		delete(headers, headerFailedOn)
+		replays, _ := headers[headerReplays].(int32)
+		headers[headerReplays] = replays + 1
+
+		if err := publish(channel, queue, d, headers); err != nil {
+			_ = d.Nack(false, true)
+			return count, err
+		}
+		if err := d.Ack(false); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, ctx.Err()
+}
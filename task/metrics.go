@@ -0,0 +1,77 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	"github.com/greatfocus/gf-document/logging"
+	"github.com/greatfocus/gf-document/metrics"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Job and consumer metrics
+var (
+	jobsTotal = metrics.NewCounter("jobs_total",
+		"Job runs by type and outcome, done, retried or failed.", "type", "outcome")
+	jobDuration = metrics.NewHistogram("job_duration_seconds",
+		"Time taken by job runs per type.", metrics.DurationBuckets, "type")
+	messagesTotal = metrics.NewCounter("amqp_messages_total",
+		"AMQP messages handled per queue by result, processed or failed.", "queue", "result")
+)
+
+// jobOutcome names the status a job run left the job in
+func jobOutcome(status string, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case status == models.StageQueued:
+		return "retried"
+	case status == models.StageFailed:
+		return "failed"
+	default:
+		return "done"
+	}
+}
+
+// observeJob counts a job run
+func observeJob(job models.Job, status string, err error, started time.Time) {
+	jobsTotal.Inc(job.Type, jobOutcome(status, err))
+	jobDuration.Observe(time.Since(started).Seconds(), job.Type)
+}
+
+// consume wraps a consumer handler to trace, count and log the messages of its
+// queue. The span continues the trace context found in the message headers and
+// the logs carry the correlation id of the publisher, its x-request-id header or
+// else the correlation id property of the message.
+func consume(queue string, handler func(ctx context.Context, d amqp.Delivery) error) func(d amqp.Delivery) error {
+	return func(d amqp.Delivery) error {
+		ctx := context.Background()
+		if logging.ValidRequestID(d.CorrelationId) {
+			ctx = logging.WithRequestID(ctx, d.CorrelationId)
+		}
+		if d.Headers != nil {
+			ctx = tracing.Extract(ctx, tracing.TableCarrier(d.Headers))
+		}
+		ctx = logging.Extract(ctx, tracing.TableCarrier(d.Headers))
+		ctx, span := tracing.Start(ctx, queue+" process", tracing.KindConsumer,
+			tracing.String("messaging.system", "rabbitmq"), tracing.String("messaging.destination", queue),
+			tracing.String("messaging.message_id", d.MessageId))
+		defer span.End()
+
+		err := handler(ctx, d)
+		span.RecordError(err)
+		if err != nil {
+			messagesTotal.Inc(queue, "failed")
+			logging.From(ctx).WithError(err).WithFields(logrus.Fields{
+				"queue":      queue,
+				"message_id": d.MessageId,
+			}).Warn("message not processed")
+		} else {
+			messagesTotal.Inc(queue, "processed")
+		}
+		return err
+	}
+}
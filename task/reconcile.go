@@ -0,0 +1,49 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/services"
+)
+
+// reconcileTimeout bounds a walk of the files table and the upload volume
+const reconcileTimeout = 6 * time.Hour
+
+// defaultReconcileGrace keeps orphans stored within a day, uploads still
+// being recorded look the same
+const defaultReconcileGrace = 24 * time.Hour
+
+// ReconcileOptions returns the options of the scheduled reconcile, repairing
+// only with RECONCILE_REPAIR and deleting orphans older than
+// RECONCILE_GRACE_HOURS. Stored content is not hashed.
+func ReconcileOptions() models.ReconcileOptions {
+	options := models.ReconcileOptions{Grace: defaultReconcileGrace}
+	options.Repair, _ = strconv.ParseBool(os.Getenv("RECONCILE_REPAIR"))
+	hours, err := strconv.Atoi(os.Getenv("RECONCILE_GRACE_HOURS"))
+	if err == nil && hours > 0 {
+		options.Grace = time.Duration(hours) * time.Hour
+	}
+	return options
+}
+
+// Reconcile start the job to compare the files table with the upload volume
+func (t *Tasks) Reconcile() {
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	t.server.Logger.Info("Scheduler_Reconcile started")
+	ctx = services.WithAuditSource(ctx, "schedule:Reconcile")
+	report, err := t.fileService.Reconcile(ctx, t.server.JWT.Secret(), ReconcileOptions(), func(problem models.ReconcileProblem) error {
+		return nil
+	})
+	if err != nil {
+		t.server.Logger.Warn(fmt.Sprintf("Scheduler_Reconcile Error %v", err))
+	}
+	t.server.Logger.Info(fmt.Sprintf("Scheduler_Reconcile ended, %d files and %d blobs checked, %d problems, %d repaired, %d marked",
+		report.Files, report.Blobs, report.Found(), report.Repaired, report.Marked))
+}
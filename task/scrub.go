@@ -0,0 +1,87 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/lifecycle"
+	"github.com/greatfocus/gf-document/models"
+	"github.com/greatfocus/gf-document/repositories"
+	"github.com/greatfocus/gf-document/services"
+)
+
+// Pace of the scrubber when SCRUB_INTERVAL_DAYS and SCRUB_BYTES_PER_SECOND are not set
+const (
+	defaultScrubInterval = 30 * 24 * time.Hour
+	defaultScrubRate     = 8 << 20
+)
+
+// scrubIdle is how long the scrubber waits once no file is due, or another
+// instance is scrubbing, before looking again
+const scrubIdle = 10 * time.Minute
+
+// scrubLock names the lock letting one instance scrub at a time
+const scrubLock = "scrub"
+
+// scrubOptions reads the pace of the scrubber, every file is verified again
+// after SCRUB_INTERVAL_DAYS, 0 turning the scrubber off, reading at most
+// SCRUB_BYTES_PER_SECOND
+func scrubOptions() models.ScrubOptions {
+	options := models.ScrubOptions{Interval: defaultScrubInterval, BytesPerSecond: defaultScrubRate}
+	days, err := strconv.Atoi(os.Getenv("SCRUB_INTERVAL_DAYS"))
+	if err == nil && days >= 0 {
+		options.Interval = time.Duration(days) * 24 * time.Hour
+	}
+	rate, err := strconv.ParseInt(os.Getenv("SCRUB_BYTES_PER_SECOND"), 0, 64)
+	if err == nil && rate > 0 {
+		options.BytesPerSecond = rate
+	}
+	return options
+}
+
+// StartScrubber starts verifying stored content against the checksums until
+// ctx is done
+func (t *Tasks) StartScrubber(ctx context.Context) {
+	options := scrubOptions()
+	if options.Interval == 0 {
+		t.server.Logger.Info("Scrubber disabled")
+		return
+	}
+	t.scrubber.Add(1)
+	go func() {
+		defer t.scrubber.Done()
+		t.scrub(ctx, options)
+	}()
+	t.server.Logger.Info(fmt.Sprintf("Started scrubber, every %s at %d bytes per second", options.Interval, options.BytesPerSecond))
+}
+
+// WaitScrubber blocks until the scrubber stopped after ctx is done
+func (t *Tasks) WaitScrubber() {
+	t.scrubber.Wait()
+}
+
+// scrub verifies the due files while holding the scrub lock, then idles
+func (t *Tasks) scrub(ctx context.Context, options models.ScrubOptions) {
+	ctx = services.WithAuditSource(ctx, "scrubber")
+	for ctx.Err() == nil {
+		lock, err := repositories.TryLock(ctx, t.conn, scrubLock)
+		if err != nil && ctx.Err() == nil {
+			t.server.Logger.Warn(fmt.Sprintf("Scrubber Error locking %v", err))
+		}
+		if lock != nil {
+			report, err := t.fileService.Scrub(ctx, t.server.JWT.Secret(), options)
+			lock.Release()
+			if err != nil && ctx.Err() == nil {
+				t.server.Logger.Warn(fmt.Sprintf("Scrubber Error %v", err))
+			}
+			if report.Files > 0 {
+				t.server.Logger.Info(fmt.Sprintf("Scrubber verified %d files, %d bytes, %d corrupt, %d missing, %d restored",
+					report.Files, report.Bytes, report.Corrupt, report.Missing, report.Restored))
+			}
+		}
+		_ = lifecycle.Sleep(ctx, scrubIdle)
+	}
+}
@@ -2,15 +2,16 @@ package task
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"math"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/greatfocus/gf-document/models"
 	"github.com/greatfocus/gf-document/repositories"
 	"github.com/greatfocus/gf-document/services"
-	broker "github.com/greatfocus/gf-sframe/broker"
 	"github.com/greatfocus/gf-sframe/server"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -20,17 +21,22 @@ type Tasks struct {
 	fileRepository *repositories.FileRepository
 	fileService    *services.FileService
 	server         *server.Server
+	conn           *sql.DB
+	workers        sync.WaitGroup
+	consumers      sync.WaitGroup
+	scrubber       sync.WaitGroup
 }
 
 // Init required parameters
-func (t *Tasks) Init(s *server.Server) {
+func (t *Tasks) Init(s *server.Server, conn *sql.DB) {
 	t.fileRepository = &repositories.FileRepository{}
-	t.fileRepository.Init(s.Database, s.Cache)
+	t.fileRepository.Init(conn, s.Cache)
 
 	t.fileService = &services.FileService{}
-	t.fileService.Init(s.Database, s.Cache, s.JWT, s.Logger)
+	t.fileService.Init(conn, s.Cache, s.JWT)
 
 	t.server = s
+	t.conn = conn
 }
 
 // RemoveUnTemporaryFile start the job to remove temp files
@@ -39,6 +45,8 @@ func (t *Tasks) RemoveTemporaryFile() {
 	defer cancel()
 
 	t.server.Logger.Info("Scheduler_RemoveUnTemporaryFile started")
+	ctx = repositories.WithTenant(ctx, repositories.TenantAll)
+	ctx = services.WithAuditSource(ctx, "schedule:RemoveTemporaryFile")
 	msgs, err := t.fileRepository.GetFilesByStatus(ctx, t.server.JWT.Secret(), "temp")
 	if err != nil {
 		t.server.Logger.Warn("Scheduler_RemoveUnTemporaryFile Error fetching files")
@@ -57,36 +65,20 @@ func (t *Tasks) RemoveTemporaryFile() {
 	t.server.Logger.Info("Scheduler_RemoveUnTemporaryFile ended")
 }
 
-// RemoveUnTemporaryFile start the job to remove temp files
-func (t *Tasks) EventsListerner() {
-	// broker.Publish("post.event.delete", "ped", uuid.New().String(), []byte(`{"id":"c45d75d7-276f-4f53-bffb-2b1b5a7119e9", "refId": "c45d75d7-276f-4f53-bffb-2b1b5a7119e9"}`))
-	go func() {
-		approval := broker.ConsumerParam{
-			Handler:       t.approveDocument,
-			QueueName:     "post.event.approved",
-			AppId:         t.server.Name,
-			ConnectionStr: os.Getenv("RABBITMQ_URL"),
-		}
-		for {
-			time.Sleep(10 * time.Second)
-			broker.Consumer(approval)
-		}
-	}()
-	go func() {
-		for {
-			delete := broker.ConsumerParam{
-				Handler:       t.deleteDocument,
-				QueueName:     "post.event.delete",
-				AppId:         t.server.Name,
-				ConnectionStr: os.Getenv("RABBITMQ_URL"),
-			}
-			time.Sleep(10 * time.Second)
-			broker.Consumer(delete)
-		}
-	}()
+// ReindexFileNames start the job to refresh blind indexes after a key rotation
+func (t *Tasks) ReindexFileNames() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.server.Timeout)*time.Second)
+	defer cancel()
+
+	t.server.Logger.Info("Scheduler_ReindexFileNames started")
+	count, err := t.fileService.ReindexNames(ctx, t.server.JWT.Secret(), 100)
+	if err != nil {
+		t.server.Logger.Warn(fmt.Sprintf("Scheduler_ReindexFileNames Error %v", err))
+	}
+	t.server.Logger.Info(fmt.Sprintf("Scheduler_ReindexFileNames ended, %d files reindexed", count))
 }
 
-func (t *Tasks) approveDocument(d amqp.Delivery) error {
+func (t *Tasks) approveDocument(ctx context.Context, d amqp.Delivery) error {
 	if d.Body != nil {
 		// validate if json object
 		file := models.File{}
@@ -94,8 +86,9 @@ func (t *Tasks) approveDocument(d amqp.Delivery) error {
 		if err != nil {
 			return err
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.server.Timeout)*time.Second)
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(t.server.Timeout)*time.Second)
 		defer cancel()
+		ctx = services.WithAuditSource(ctx, "amqp:post.event.approved")
 
 		// validate payload rules
 		err = file.ValidateFile("update")
@@ -104,13 +97,13 @@ func (t *Tasks) approveDocument(d amqp.Delivery) error {
 		}
 
 		// create token
-		_, err = t.fileService.Update(ctx, t.server.JWT.Secret(), file)
+		_, err = t.fileService.Update(ctx, t.server.JWT.Secret(), models.SystemActor, file)
 		return err
 	}
 	return nil
 }
 
-func (t *Tasks) deleteDocument(d amqp.Delivery) error {
+func (t *Tasks) deleteDocument(ctx context.Context, d amqp.Delivery) error {
 	if d.Body != nil {
 		// validate if json object
 		file := models.File{}
@@ -118,10 +111,11 @@ func (t *Tasks) deleteDocument(d amqp.Delivery) error {
 		if err != nil {
 			return err
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.server.Timeout)*time.Second)
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(t.server.Timeout)*time.Second)
 		defer cancel()
+		ctx = services.WithAuditSource(ctx, "amqp:post.event.delete")
 
-		success, err := t.fileService.Delete(ctx, t.server.JWT.Secret(), file.ID)
+		success, err := t.fileService.Delete(ctx, t.server.JWT.Secret(), models.SystemActor, file.ID)
 		if !success || err != nil {
 			return err
 		}
@@ -0,0 +1,100 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/greatfocus/gf-document/services"
+)
+
+// defaultWorkers process the job queue when JOB_WORKERS is not set
+const defaultWorkers = 4
+
+// pollInterval is how long an idle worker waits before looking for due jobs again
+const pollInterval = 2 * time.Second
+
+// jobRetention is how long done jobs are kept
+const jobRetention = 7 * 24 * time.Hour
+
+// workerCount reads the size of the worker pool, JOB_WORKERS
+func workerCount() int {
+	count, err := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if err != nil || count < 0 {
+		return defaultWorkers
+	}
+	return count
+}
+
+// StartWorkers starts the pool processing the job queue until ctx is done
+func (t *Tasks) StartWorkers(ctx context.Context) {
+	count := workerCount()
+	for i := 0; i < count; i++ {
+		t.workers.Add(1)
+		go func(worker int) {
+			defer t.workers.Done()
+			t.work(ctx, worker)
+		}(i)
+	}
+	t.server.Logger.Info(fmt.Sprintf("Started %d job workers", count))
+}
+
+// WaitWorkers blocks until the workers finished their jobs after ctx is done
+func (t *Tasks) WaitWorkers() {
+	t.workers.Wait()
+}
+
+// work claims and runs jobs one at a time, sleeping while the queue is empty
+func (t *Tasks) work(ctx context.Context, worker int) {
+	for ctx.Err() == nil {
+		job, found, err := t.fileService.ClaimJob(ctx)
+		if err != nil {
+			t.server.Logger.Warn(fmt.Sprintf("Worker %d Error claiming job %v", worker, err))
+		}
+		if !found {
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		// a job runs to the end of its lease even when the workers are stopped
+		started := time.Now()
+		jobCtx, cancel := context.WithTimeout(context.Background(), services.JobLease)
+		status, err := t.fileService.RunJob(jobCtx, t.server.JWT.Secret(), job)
+		cancel()
+		observeJob(job, status, err, started)
+		if err != nil {
+			t.server.Logger.Error(fmt.Sprintf("Worker %d Error: job %s %v", worker, job.ID, err))
+		}
+	}
+}
+
+// PurgeJobs start the job to remove done jobs past their retention
+func (t *Tasks) PurgeJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.server.Timeout)*time.Second)
+	defer cancel()
+
+	t.server.Logger.Info("Scheduler_PurgeJobs started")
+	count, err := t.fileService.PurgeJobs(ctx, time.Now().Add(-jobRetention))
+	if err != nil {
+		t.server.Logger.Warn(fmt.Sprintf("Scheduler_PurgeJobs Error %v", err))
+	}
+	t.server.Logger.Info(fmt.Sprintf("Scheduler_PurgeJobs ended, %d jobs removed", count))
+}
+
+// ExpireJobs start the job to fail the jobs whose lease expired after their last attempt
+func (t *Tasks) ExpireJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.server.Timeout)*time.Second)
+	defer cancel()
+
+	t.server.Logger.Info("Scheduler_ExpireJobs started")
+	count, err := t.fileService.ExpireJobs(ctx)
+	if err != nil {
+		t.server.Logger.Warn(fmt.Sprintf("Scheduler_ExpireJobs Error %v", err))
+	}
+	t.server.Logger.Info(fmt.Sprintf("Scheduler_ExpireJobs ended, %d jobs failed", count))
+}
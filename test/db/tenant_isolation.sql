@@ -0,0 +1,59 @@
+-- Proves the row level security policies keep tenants apart.
+-- Run as the application role, superusers bypass row level security
+-- (repositories/tenant_test.go runs the same checks through inTenant):
+-- psql "$DATABASE_URL" -v ON_ERROR_STOP=1 -f test/db/tenant_isolation.sql
+BEGIN;
+
+SET LOCAL app.tenant_id = 'tenant-a';
+INSERT INTO files (id, name, extension, size, status, actorId, origin, tenantId)
+VALUES ('rls-test-a', PGP_SYM_ENCRYPT('rls-test-a.png', 'secret'), '.png', 1, 'new', 1, 'test', 'tenant-a');
+
+SET LOCAL app.tenant_id = 'tenant-b';
+INSERT INTO files (id, name, extension, size, status, actorId, origin, tenantId)
+VALUES ('rls-test-b', PGP_SYM_ENCRYPT('rls-test-b.png', 'secret'), '.png', 1, 'new', 2, 'test', 'tenant-b');
+
+-- tenant b cannot read, update or delete the file of tenant a
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM files WHERE id = 'rls-test-a') THEN
+		RAISE EXCEPTION 'tenant-b can read a file of tenant-a';
+	END IF;
+	UPDATE files SET status = 'approved' WHERE id = 'rls-test-a';
+	IF FOUND THEN
+		RAISE EXCEPTION 'tenant-b can update a file of tenant-a';
+	END IF;
+	DELETE FROM files WHERE id = 'rls-test-a';
+	IF FOUND THEN
+		RAISE EXCEPTION 'tenant-b can delete a file of tenant-a';
+	END IF;
+END $$;
+
+-- tenant b cannot create a file for tenant a
+DO $$
+BEGIN
+	INSERT INTO files (id, name, extension, size, status, actorId, origin, tenantId)
+	VALUES ('rls-test-c', PGP_SYM_ENCRYPT('rls-test-c.png', 'secret'), '.png', 1, 'new', 2, 'test', 'tenant-a');
+	RAISE EXCEPTION 'tenant-b can create a file for tenant-a';
+EXCEPTION WHEN insufficient_privilege THEN
+	NULL;
+END $$;
+
+-- a missing tenant sees nothing
+RESET app.tenant_id;
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM files WHERE id IN ('rls-test-a', 'rls-test-b')) THEN
+		RAISE EXCEPTION 'a request without tenant can read files';
+	END IF;
+END $$;
+
+-- system jobs see every tenant
+SET LOCAL app.tenant_id = '*';
+DO $$
+BEGIN
+	IF (SELECT count(1) FROM files WHERE id IN ('rls-test-a', 'rls-test-b')) <> 2 THEN
+		RAISE EXCEPTION 'system jobs cannot read every tenant';
+	END IF;
+END $$;
+
+ROLLBACK;
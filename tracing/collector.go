@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// CollectedSpan is a span as received by the Collector
+type CollectedSpan struct {
+	Service      string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Kind         int
+	Attributes   map[string]string
+	Error        string
+}
+
+// Collector is an in-process stand in for an OpenTelemetry collector. It accepts
+// OTLP/HTTP JSON trace exports, so pointing OTEL_EXPORTER_OTLP_TRACES_ENDPOINT at
+// an httptest server running it shows the spans a request produced.
+type Collector struct {
+	mutex sync.Mutex
+	spans []CollectedSpan
+}
+
+// ServeHTTP receives an export request
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	request := exportRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, resourceSpans := range request.ResourceSpans {
+		service := decodeAttributes(resourceSpans.Resource.Attributes)["service.name"]
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				c.spans = append(c.spans, CollectedSpan{
+					Service:      service,
+					TraceID:      span.TraceID,
+					SpanID:       span.SpanID,
+					ParentSpanID: span.ParentSpanID,
+					Name:         span.Name,
+					Kind:         span.Kind,
+					Attributes:   decodeAttributes(span.Attributes),
+					Error:        span.Status.Message,
+				})
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{}"))
+}
+
+// Spans returns the spans received so far
+func (c *Collector) Spans() []CollectedSpan {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]CollectedSpan{}, c.spans...)
+}
+
+// Reset forgets the spans received
+func (c *Collector) Reset() {
+	c.mutex.Lock()
+	c.spans = nil
+	c.mutex.Unlock()
+}
+
+// decodeAttributes flattens OTLP key values to strings
+func decodeAttributes(values []keyValue) map[string]string {
+	attributes := map[string]string{}
+	for _, value := range values {
+		switch {
+		case value.Value.StringValue != nil:
+			attributes[value.Key] = *value.Value.StringValue
+		case value.Value.IntValue != nil:
+			attributes[value.Key] = *value.Value.IntValue
+		case value.Value.DoubleValue != nil:
+			attributes[value.Key] = strconv.FormatFloat(*value.Value.DoubleValue, 'g', -1, 64)
+		case value.Value.BoolValue != nil:
+			attributes[value.Key] = strconv.FormatBool(*value.Value.BoolValue)
+		}
+	}
+	return attributes
+}
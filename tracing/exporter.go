@@ -0,0 +1,214 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greatfocus/gf-document/metrics"
+)
+
+// Batching of the exporter
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+// spansDropped counts spans that never reached the collector
+var spansDropped = metrics.NewCounter("spans_dropped_total",
+	"Spans not exported by reason, queue_full or export_failed.", "reason")
+
+// batchExporter queues ended spans and posts them in batches over OTLP/HTTP JSON
+type batchExporter struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	ratio    float64
+	client   *http.Client
+	queue    chan *Span
+	flush    chan chan struct{}
+	stopped  chan struct{}
+}
+
+// exporter is set up once by Init, before it spans are only propagated
+var exporter = &batchExporter{}
+
+// Init starts exporting the spans of the service to OTEL_EXPORTER_OTLP_TRACES_ENDPOINT,
+// or OTEL_EXPORTER_OTLP_ENDPOINT with /v1/traces appended, sending the
+// OTEL_EXPORTER_OTLP_HEADERS and keeping the OTEL_TRACES_SAMPLER_ARG ratio of
+// new traces. Without an endpoint spans are not recorded but incoming trace
+// context still reaches the messages sent on. Init is called once at startup.
+func Init(service string) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		endpoint = strings.TrimSuffix(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/") + "/v1/traces"
+	}
+	if endpoint == "" {
+		return
+	}
+	ratio, err := strconv.ParseFloat(os.Getenv("OTEL_TRACES_SAMPLER_ARG"), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		ratio = 1
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		service = name
+	}
+
+	exporter = &batchExporter{
+		endpoint: endpoint,
+		headers:  parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		service:  service,
+		ratio:    ratio,
+		client:   &http.Client{Timeout: exportTimeout},
+		queue:    make(chan *Span, queueSize),
+		flush:    make(chan chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go exporter.run()
+}
+
+// Shutdown exports the queued spans and stops the exporter
+func Shutdown(ctx context.Context) error {
+	if !exporter.enabled() {
+		return nil
+	}
+	done := make(chan struct{})
+	select {
+	case exporter.flush <- done:
+	case <-exporter.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseHeaders reads key=value pairs separated by commas
+func parseHeaders(value string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if found && strings.TrimSpace(key) != "" {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return headers
+}
+
+func (e *batchExporter) enabled() bool {
+	return e.queue != nil
+}
+
+// sampled decides if a new trace is recorded
+func sampled() bool {
+	if !exporter.enabled() || exporter.ratio == 0 {
+		return false
+	}
+	if exporter.ratio == 1 {
+		return true
+	}
+	ids.Lock()
+	defer ids.Unlock()
+	return ids.random.Float64() < exporter.ratio
+}
+
+// enqueue queues an ended span, dropping it when the queue is full rather than blocking
+func (e *batchExporter) enqueue(span *Span) {
+	if !e.enabled() {
+		return
+	}
+	select {
+	case e.queue <- span:
+	default:
+		spansDropped.Inc("queue_full")
+	}
+}
+
+// run exports a batch when it is full, on every flush interval and on shutdown
+func (e *batchExporter) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, batchSize)
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = batch[:0]
+		case done := <-e.flush:
+			for drained := false; !drained; {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+				default:
+					drained = true
+				}
+			}
+			e.export(batch)
+			close(e.stopped)
+			close(done)
+			return
+		}
+	}
+}
+
+// export posts a batch to the collector
+func (e *batchExporter) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	spans := make([]spanData, len(batch))
+	for i, span := range batch {
+		spans[i] = encodeSpan(span)
+	}
+	request := exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: encodeAttributes([]Attribute{String("service.name", e.service)})},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "github.com/greatfocus/gf-document/tracing"}, Spans: spans}},
+	}}}
+
+	if err := e.post(request); err != nil {
+		spansDropped.Add(float64(len(batch)), "export_failed")
+	}
+}
+
+func (e *batchExporter) post(request exportRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("collector responded %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import "strconv"
+
+// OTLP/JSON encoding of trace export requests. Ids are hex and 64 bit
+// integers are strings as the OTLP/JSON mapping of the protobuf asks.
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanData `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type spanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	TraceState        string     `json:"traceState,omitempty"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+// encodeAttributes maps attributes to OTLP key values
+func encodeAttributes(attributes []Attribute) []keyValue {
+	values := make([]keyValue, 0, len(attributes))
+	for _, attribute := range attributes {
+		value := anyValue{}
+		switch v := attribute.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			text := strconv.FormatInt(v, 10)
+			value.IntValue = &text
+		case int:
+			text := strconv.Itoa(v)
+			value.IntValue = &text
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			continue
+		}
+		values = append(values, keyValue{Key: attribute.Key, Value: value})
+	}
+	return values
+}
+
+// encodeSpan maps an ended span to OTLP
+func encodeSpan(span *Span) spanData {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	data := spanData{
+		TraceID:           span.context.TraceID.String(),
+		SpanID:            span.context.SpanID.String(),
+		TraceState:        span.context.TraceState,
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        encodeAttributes(span.attributes),
+		Status:            status{Code: span.status, Message: span.message},
+	}
+	if span.parent != (SpanID{}) {
+		data.ParentSpanID = span.parent.String()
+	}
+	return data
+}
@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// W3C trace context headers
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+// Carrier reads and writes the propagation fields of a message
+type Carrier interface {
+	Get(key string) string
+	Set(key string, value string)
+}
+
+// HeaderCarrier carries trace context in HTTP headers
+type HeaderCarrier http.Header
+
+// Get returns the header value
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+// Set sets the header value
+func (c HeaderCarrier) Set(key string, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// TableCarrier carries trace context in the headers of an AMQP message
+type TableCarrier amqp.Table
+
+// Get returns the header value when it is a string
+func (c TableCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+// Set sets the header value
+func (c TableCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+// MapCarrier carries trace context in a string map such as the payload of a job
+type MapCarrier map[string]string
+
+// Get returns the value
+func (c MapCarrier) Get(key string) string {
+	return c[key]
+}
+
+// Set sets the value
+func (c MapCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+// Inject writes the context of the current span of ctx to the carrier
+func Inject(ctx context.Context, carrier Carrier) {
+	current := spanContextFrom(ctx)
+	if !current.Valid() {
+		return
+	}
+	flags := "00"
+	if current.Sampled {
+		flags = "01"
+	}
+	carrier.Set(traceparentHeader, "00-"+current.TraceID.String()+"-"+current.SpanID.String()+"-"+flags)
+	if current.TraceState != "" {
+		carrier.Set(tracestateHeader, current.TraceState)
+	}
+}
+
+// Extract reads a remote parent from the carrier into ctx, invalid values are ignored
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	remote, ok := parseTraceparent(carrier.Get(traceparentHeader))
+	if !ok {
+		return ctx
+	}
+	remote.TraceState = carrier.Get(tracestateHeader)
+	return withRemote(ctx, remote)
+}
+
+// parseTraceparent reads version-traceid-parentid-flags. Versions after 00 may
+// append fields, which are ignored as the specification asks.
+func parseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	for _, part := range parts[:4] {
+		if strings.ToLower(part) != part {
+			return SpanContext{}, false
+		}
+	}
+
+	remote := SpanContext{Remote: true}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(remote.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(remote.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	remote.Sampled = flags[0]&1 == 1
+	return remote, remote.Valid()
+}
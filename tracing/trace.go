@@ -0,0 +1,219 @@
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Span kinds as numbered by OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+	KindProducer = 4
+	KindConsumer = 5
+)
+
+// Status codes as numbered by OTLP
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the lower case hex form used by traceparent and OTLP/JSON
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// String returns the lower case hex form used by traceparent and OTLP/JSON
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext is the part of a span that crosses process boundaries
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+	Remote     bool
+}
+
+// Valid checks the ids are set, W3C trace context forbids all zero ids
+func (c SpanContext) Valid() bool {
+	return c.TraceID != TraceID{} && c.SpanID != SpanID{}
+}
+
+// Attribute is a key and a string, int64, float64 or bool value
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String makes a string attribute
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int makes an integer attribute
+func Int(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool makes a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is a timed operation. Spans that are not sampled only carry their
+// context so it still reaches the services called. A nil Span is a no-op.
+type Span struct {
+	context    SpanContext
+	parent     SpanID
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	mutex      sync.Mutex
+	attributes []Attribute
+	status     int
+	message    string
+	ended      bool
+}
+
+// Context returns the span context to propagate
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil || !s.context.Sampled {
+		return
+	}
+	s.mutex.Lock()
+	s.attributes = append(s.attributes, attributes...)
+	s.mutex.Unlock()
+}
+
+// RecordError marks the span failed with the error, nil errors are ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil || !s.context.Sampled {
+		return
+	}
+	s.mutex.Lock()
+	s.status = statusError
+	s.message = err.Error()
+	s.mutex.Unlock()
+}
+
+// End finishes the span and queues it for export, later calls do nothing
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mutex.Unlock()
+	if s.context.Sampled {
+		exporter.enqueue(s)
+	}
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// remoteKey is the context key of a span context extracted from a carrier
+type remoteKey struct{}
+
+// SpanFrom returns the current span of ctx, nil when there is none
+func SpanFrom(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// spanContextFrom returns the context of the current span, or the remote parent
+func spanContextFrom(ctx context.Context) SpanContext {
+	if span := SpanFrom(ctx); span != nil {
+		return span.context
+	}
+	remote, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return remote
+}
+
+// withRemote sets the parent extracted from a carrier
+func withRemote(ctx context.Context, remote SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, remote)
+}
+
+// Start starts a child of the current or remote span of ctx, or the root of a
+// new trace, and returns ctx carrying it. The span must be ended.
+func Start(ctx context.Context, name string, kind int, attributes ...Attribute) (context.Context, *Span) {
+	parent := spanContextFrom(ctx)
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	span.context.SpanID = newSpanID()
+	if parent.Valid() {
+		span.context.TraceID = parent.TraceID
+		span.context.TraceState = parent.TraceState
+		span.context.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		span.context.TraceID = newTraceID()
+		span.context.Sampled = sampled()
+	}
+	span.SetAttributes(attributes...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// ids draws span and trace ids, seeded once from crypto/rand
+var ids = struct {
+	sync.Mutex
+	random *rand.Rand
+}{random: rand.New(rand.NewSource(seed()))}
+
+func seed() int64 {
+	var buffer [8]byte
+	if _, err := crand.Read(buffer[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(buffer[:]))
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	ids.Lock()
+	defer ids.Unlock()
+	for id == (TraceID{}) {
+		ids.random.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	ids.Lock()
+	defer ids.Unlock()
+	for id == (SpanID{}) {
+		ids.random.Read(id[:])
+	}
+	return id
+}
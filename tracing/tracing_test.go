@@ -0,0 +1,327 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// remoteParent is a sampled traceparent as sent by an upstream service
+const (
+	remoteTrace       = "4bf92f3577b34da6a3ce929d0e0e4736"
+	remoteSpan        = "00f067aa0ba902b7"
+	remoteTraceparent = "00-" + remoteTrace + "-" + remoteSpan + "-01"
+	remoteTracestate  = "congo=t61rcWkgMzE"
+)
+
+// collect exports to a Collector for the test and returns it with the raw
+// bodies of the export requests. The exporter is reset after the test.
+func collect(t *testing.T, env map[string]string) (*Collector, func() [][]byte) {
+	t.Helper()
+	collector := &Collector{}
+	var mutex sync.Mutex
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		bodies = append(bodies, body)
+		mutex.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		collector.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { exporter = &batchExporter{} })
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer secret")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	Init("gf-document")
+	return collector, func() [][]byte {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([][]byte{}, bodies...)
+	}
+}
+
+// shutdown flushes the exporter
+func shutdown(t *testing.T) {
+	t.Helper()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+}
+
+func TestPropagationRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(ctx context.Context) context.Context
+	}{
+		{
+			name: "http headers",
+			send: func(ctx context.Context) context.Context {
+				var received context.Context
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					received = Extract(context.Background(), HeaderCarrier(r.Header))
+				}))
+				defer server.Close()
+				req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				Inject(ctx, HeaderCarrier(req.Header))
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				return received
+			},
+		},
+		{
+			name: "amqp headers",
+			send: func(ctx context.Context) context.Context {
+				publishing := amqp.Publishing{Headers: amqp.Table{}}
+				Inject(ctx, TableCarrier(publishing.Headers))
+				delivery := amqp.Delivery{Headers: publishing.Headers}
+				return Extract(context.Background(), TableCarrier(delivery.Headers))
+			},
+		},
+		{
+			name: "job payload",
+			send: func(ctx context.Context) context.Context {
+				payload := map[string]string{}
+				Inject(ctx, MapCarrier(payload))
+				return Extract(context.Background(), MapCarrier(payload))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := Extract(context.Background(), MapCarrier{traceparentHeader: remoteTraceparent, tracestateHeader: remoteTracestate})
+			ctx, span := Start(ctx, "send", KindProducer)
+			defer span.End()
+
+			received := tt.send(ctx)
+			if received == nil {
+				t.Fatal("nothing received")
+			}
+			remote := spanContextFrom(received)
+			if !remote.Remote || remote.TraceID != span.Context().TraceID || remote.SpanID != span.Context().SpanID {
+				t.Fatalf("received %+v, want the context of %+v", remote, span.Context())
+			}
+			if !remote.Sampled || remote.TraceState != remoteTracestate {
+				t.Fatalf("received sampled %v tracestate %q, want true %q", remote.Sampled, remote.TraceState, remoteTracestate)
+			}
+
+			_, child := Start(received, "receive", KindConsumer)
+			defer child.End()
+			if child.Context().TraceID.String() != remoteTrace || child.parent != span.Context().SpanID {
+				t.Fatalf("child trace %s parent %s, want %s %s", child.Context().TraceID, child.parent, remoteTrace, span.Context().SpanID)
+			}
+		})
+	}
+}
+
+func TestInjectWithoutSpan(t *testing.T) {
+	carrier := MapCarrier{}
+	Inject(context.Background(), carrier)
+	if len(carrier) != 0 {
+		t.Fatalf("Inject() set %v, want nothing", carrier)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"sampled", remoteTraceparent, true},
+		{"not sampled", "00-" + remoteTrace + "-" + remoteSpan + "-00", true},
+		{"future version with more fields", "cc-" + remoteTrace + "-" + remoteSpan + "-01-what-the-future", true},
+		{"version 00 with more fields", remoteTraceparent + "-extra", false},
+		{"version ff", "ff-" + remoteTrace + "-" + remoteSpan + "-01", false},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + remoteSpan + "-01", false},
+		{"zero trace id", "00-00000000000000000000000000000000-" + remoteSpan + "-01", false},
+		{"zero span id", "00-" + remoteTrace + "-0000000000000000-01", false},
+		{"short trace id", "00-4bf92f3577b34da6-" + remoteSpan + "-01", false},
+		{"not hex", "00-" + remoteTrace + "-00f067aa0ba902bz-01", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, valid := parseTraceparent(tt.value)
+			if valid != tt.valid {
+				t.Fatalf("parseTraceparent(%q) valid = %v, want %v", tt.value, valid, tt.valid)
+			}
+			if valid && (remote.TraceID.String() != remoteTrace || remote.SpanID.String() != remoteSpan) {
+				t.Fatalf("parseTraceparent(%q) = %s %s", tt.value, remote.TraceID, remote.SpanID)
+			}
+		})
+	}
+}
+
+func TestStartParentAndChild(t *testing.T) {
+	collect(t, nil)
+	ctx, root := Start(context.Background(), "root", KindServer)
+	childCtx, child := Start(ctx, "child", KindInternal)
+	_, grandchild := Start(childCtx, "grandchild", KindClient)
+	_, sibling := Start(ctx, "sibling", KindInternal)
+
+	if !root.Context().Valid() || root.parent != (SpanID{}) {
+		t.Fatalf("root = %+v parent %s, want a valid root", root.Context(), root.parent)
+	}
+	if SpanFrom(childCtx) != child {
+		t.Fatal("SpanFrom() is not the child")
+	}
+	for _, span := range []*Span{child, grandchild, sibling} {
+		if span.Context().TraceID != root.Context().TraceID {
+			t.Fatalf("%s trace %s, want %s", span.name, span.Context().TraceID, root.Context().TraceID)
+		}
+		if !span.Context().Sampled {
+			t.Fatalf("%s not sampled", span.name)
+		}
+	}
+	if child.parent != root.Context().SpanID || sibling.parent != root.Context().SpanID {
+		t.Fatalf("child parent %s sibling parent %s, want %s", child.parent, sibling.parent, root.Context().SpanID)
+	}
+	if grandchild.parent != child.Context().SpanID {
+		t.Fatalf("grandchild parent %s, want %s", grandchild.parent, child.Context().SpanID)
+	}
+	if child.Context().SpanID == sibling.Context().SpanID || child.Context().SpanID == root.Context().SpanID {
+		t.Fatal("span ids repeat")
+	}
+
+	_, other := Start(context.Background(), "other", KindServer)
+	if other.Context().TraceID == root.Context().TraceID {
+		t.Fatal("a new root continued the trace")
+	}
+}
+
+func TestExport(t *testing.T) {
+	collector, bodies := collect(t, map[string]string{"OTEL_SERVICE_NAME": "documents"})
+	ctx := Extract(context.Background(), HeaderCarrier(http.Header{"Traceparent": {remoteTraceparent}}))
+	ctx, server := Start(ctx, "GET /document/file", KindServer, String("http.method", "GET"))
+	_, query := Start(ctx, "FileRepository.GetFile", KindClient, Int("db.rows", 3), Bool("cache.hit", false))
+	query.SetAttributes(Attribute{Key: "float", Value: 1.5}, Attribute{Key: "unsupported", Value: struct{}{}})
+	query.RecordError(errors.New("no rows"))
+	query.End()
+	query.End()
+	server.End()
+	shutdown(t)
+
+	spans := collector.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("collected %d spans, want 2: %+v", len(spans), spans)
+	}
+	got := map[string]CollectedSpan{}
+	for _, span := range spans {
+		if span.Service != "documents" || span.TraceID != remoteTrace {
+			t.Fatalf("span %s of %s in trace %s, want documents %s", span.Name, span.Service, span.TraceID, remoteTrace)
+		}
+		got[span.Name] = span
+	}
+	root, child := got["GET /document/file"], got["FileRepository.GetFile"]
+	if root.ParentSpanID != remoteSpan || root.Kind != KindServer || root.Attributes["http.method"] != "GET" || root.Error != "" {
+		t.Fatalf("server span = %+v", root)
+	}
+	if child.ParentSpanID != root.SpanID || child.Kind != KindClient || child.Error != "no rows" {
+		t.Fatalf("query span = %+v, want a failed child of %s", child, root.SpanID)
+	}
+	wantAttributes := map[string]string{"db.rows": "3", "cache.hit": "false", "float": "1.5"}
+	if len(child.Attributes) != len(wantAttributes) {
+		t.Fatalf("query attributes = %v, want %v", child.Attributes, wantAttributes)
+	}
+	for key, value := range wantAttributes {
+		if child.Attributes[key] != value {
+			t.Fatalf("query attributes = %v, want %v", child.Attributes, wantAttributes)
+		}
+	}
+
+	// the OTLP/JSON mapping writes 64 bit integers as strings
+	requests := bodies()
+	if len(requests) != 1 {
+		t.Fatalf("%d export requests, want 1", len(requests))
+	}
+	payload := struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Scope struct{ Name string }
+				Spans []map[string]json.RawMessage
+			}
+		}
+	}{}
+	if err := json.Unmarshal(requests[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	scope := payload.ResourceSpans[0].ScopeSpans[0]
+	if scope.Scope.Name != "github.com/greatfocus/gf-document/tracing" {
+		t.Fatalf("scope = %q", scope.Scope.Name)
+	}
+	for _, span := range scope.Spans {
+		for _, field := range []string{"startTimeUnixNano", "endTimeUnixNano"} {
+			var text string
+			if err := json.Unmarshal(span[field], &text); err != nil || text == "" {
+				t.Fatalf("%s = %s, want a string", field, span[field])
+			}
+		}
+	}
+	if !bytes.Contains(requests[0], []byte(`{"key":"db.rows","value":{"intValue":"3"}}`)) {
+		t.Fatalf("payload without a string intValue: %s", requests[0])
+	}
+	if !bytes.Contains(requests[0], []byte(`"status":{"code":2,"message":"no rows"}`)) {
+		t.Fatalf("payload without the error status: %s", requests[0])
+	}
+}
+
+func TestExportNotSampled(t *testing.T) {
+	collector, bodies := collect(t, map[string]string{"OTEL_TRACES_SAMPLER_ARG": "0"})
+	ctx, span := Start(context.Background(), "root", KindServer)
+	carrier := MapCarrier{}
+	Inject(ctx, carrier)
+	span.End()
+	shutdown(t)
+
+	if len(collector.Spans()) != 0 || len(bodies()) != 0 {
+		t.Fatalf("exported %d spans, want none", len(collector.Spans()))
+	}
+	want := "00-" + span.Context().TraceID.String() + "-" + span.Context().SpanID.String() + "-00"
+	if carrier[traceparentHeader] != want {
+		t.Fatalf("traceparent = %q, want %q", carrier[traceparentHeader], want)
+	}
+}
+
+func TestCollectorRejects(t *testing.T) {
+	collector := &Collector{}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/traces", nil),
+		httptest.NewRequest(http.MethodPost, "/v1/traces", bytes.NewBufferString("{")),
+	} {
+		recorder := httptest.NewRecorder()
+		collector.ServeHTTP(recorder, req)
+		if recorder.Code == http.StatusOK {
+			t.Fatalf("%s %s answered 200", req.Method, req.URL)
+		}
+	}
+	collector.Reset()
+	if len(collector.Spans()) != 0 {
+		t.Fatal("Reset() kept spans")
+	}
+}